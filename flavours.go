@@ -0,0 +1,182 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/go-enjin/be/pkg/cli/env"
+	"github.com/go-enjin/be/pkg/log"
+)
+
+// aptFlavour is one apt repository of the site, published from its own
+// repository path and served from /<name>, with package pages at
+// /dpkg-deb/<name>
+type aptFlavour struct {
+	Name string
+	// Codename is the primary distribution, the first of the Codenames
+	Codename  string
+	Codenames []string
+	// Suites are the suite aliases keyed by codename
+	Suites        map[string]string
+	Components    []string
+	Architectures []string
+
+	// RepositoryPath is the directory of the pool/ and dists/, defaulting to
+	// <AE_BASEPATH>/<name>
+	RepositoryPath string
+	// ArchivesPath is the directory of package files published by the repo
+	// builder, defaulting to <AE_ARCHIVES>/<name>
+	ArchivesPath string
+
+	GpgFile string
+	GpgKey  string
+	SignKey string
+
+	// BasePackages are the base system Packages index patterns keyed by
+	// codename
+	BasePackages map[string][]string
+}
+
+// Mount returns the url path the repository is served from
+func (flavour *aptFlavour) Mount() (mount string) {
+	mount = "/" + flavour.Name
+	return
+}
+
+// DebMount returns the url path of the package pages
+func (flavour *aptFlavour) DebMount() (mount string) {
+	mount = "/dpkg-deb/" + flavour.Name
+	return
+}
+
+// Context returns the flavour details for use in page templates, which
+// excludes the paths and signing keys
+func (flavour *aptFlavour) Context() (ctx map[string]interface{}) {
+	ctx = map[string]interface{}{
+		"Name":          flavour.Name,
+		"Codename":      flavour.Codename,
+		"Codenames":     flavour.Codenames,
+		"Components":    flavour.Components,
+		"Architectures": flavour.Architectures,
+		"Mount":         flavour.Mount(),
+		"DebMount":      flavour.DebMount(),
+	}
+	return
+}
+
+// flavourEnvKey returns the environment variable key with the flavour name
+// suffix, for example APT_CODENAMES_UBUNTU
+func flavourEnvKey(key, name string) (flavourKey string) {
+	flavourKey = key + "_" + strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+	return
+}
+
+// flavourEnv returns the value of the flavour specific environment variable,
+// or the fallback when not set
+func flavourEnv(key, name, fallback string) (value string) {
+	value = env.Get(flavourEnvKey(key, name), fallback)
+	return
+}
+
+// aptFlavours returns the flavours to serve, the UseAptFlavour is always first
+func aptFlavours() (flavours []*aptFlavour) {
+	var names []string
+	if UseAptFlavour != "" {
+		names = append(names, UseAptFlavour)
+	}
+	for _, name := range strings.Fields(UseAptFlavours) {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	for idx, name := range names {
+		flavours = append(flavours, newAptFlavour(name, idx == 0))
+	}
+	return
+}
+
+// newAptFlavour returns the named flavour configured with the <KEY>_<FLAVOUR>
+// environment variables. Components, architectures, paths and signing keys
+// fall back to the site settings. Only the primary flavour falls back to the
+// site codenames, suites and base packages as those name the distributions
+// of a specific flavour
+func newAptFlavour(name string, primary bool) (flavour *aptFlavour) {
+	var codename, codenames, suites string
+	if primary {
+		codename, codenames, suites = AptCodename, UseAptCodenames, UseAptSuites
+	}
+
+	flavour = &aptFlavour{
+		Name:           name,
+		Suites:         make(map[string]string),
+		Components:     strings.Fields(flavourEnv("APT_COMPONENTS", name, AptComponents)),
+		Architectures:  strings.Fields(flavourEnv("APT_ARCHITECTURES", name, AptArchitectures)),
+		RepositoryPath: flavourEnv("AE_REPOSITORY", name, UseBasePath+"/"+name),
+		ArchivesPath:   flavourEnv("AE_ARCHIVES", name, UseArchivesPath+"/"+name),
+		GpgFile:        flavourEnv("AE_GPG_FILE", name, UseGpgFile),
+		GpgKey:         flavourEnv("AE_GPG_KEY", name, UseGpgKey),
+		SignKey:        flavourEnv("AE_SIGN_KEY", name, UseSignKey),
+		BasePackages:   make(map[string][]string),
+	}
+
+	if codename = flavourEnv("APT_CODENAME", name, codename); codename != "" {
+		flavour.Codenames = append(flavour.Codenames, codename)
+	}
+	for _, codename = range strings.Fields(flavourEnv("APT_CODENAMES", name, codenames)) {
+		if !slices.Contains(flavour.Codenames, codename) {
+			flavour.Codenames = append(flavour.Codenames, codename)
+		}
+	}
+	if len(flavour.Codenames) == 0 {
+		log.FatalF("%v flavour has no codenames, set %v\n", name, flavourEnvKey("APT_CODENAMES", name))
+	}
+	flavour.Codename = flavour.Codenames[0]
+
+	for _, pair := range strings.Fields(flavourEnv("APT_SUITES", name, suites)) {
+		suite, target, ok := strings.Cut(pair, "=")
+		if !ok || suite == "" || target == "" {
+			log.FatalF("error parsing %v suite alias: %q, must be suite=codename\n", name, pair)
+		} else if !slices.Contains(flavour.Codenames, target) {
+			log.FatalF("error parsing %v suite alias: %q, unknown codename\n", name, pair)
+		}
+		flavour.Suites[target] = suite
+	}
+
+	// AE_BASE_PACKAGES_<FLAVOUR>_<CODENAME> specifies the base system of each
+	// codename, the primary flavour also uses AE_BASE_PACKAGES_<CODENAME> and
+	// AE_BASE_PACKAGES for the AptCodename
+	for _, codename = range flavour.Codenames {
+		var fallback string
+		if primary {
+			if codename == AptCodename {
+				fallback = UseBasePackages
+			}
+			fallback = env.Get("AE_BASE_PACKAGES_"+strings.ToUpper(codename), fallback)
+		}
+		key := flavourEnvKey("AE_BASE_PACKAGES", name+"_"+codename)
+		if patterns := strings.Fields(env.Get(key, fallback)); len(patterns) > 0 {
+			flavour.BasePackages[codename] = patterns
+		}
+	}
+	return
+}
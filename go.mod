@@ -5,11 +5,14 @@ go 1.21
 toolchain go1.21.0
 
 require (
+	github.com/ProtonMail/go-crypto v1.1.3
 	github.com/fvbommel/sortorder v1.1.0
 	github.com/go-enjin/apt-enjin-theme v0.5.6
 	github.com/go-enjin/be v0.5.6
 	github.com/go-enjin/golang-org-x-text v0.12.1-enjin.2
 	github.com/go-enjin/semantic-enjin-theme v0.5.6
+	github.com/klauspost/compress v1.17.4
+	github.com/ulikunitz/xz v0.5.11
 	github.com/urfave/cli/v2 v2.26.0
 )
 
@@ -43,6 +46,7 @@ require (
 	github.com/blevesearch/zapx/v14 v14.3.10 // indirect
 	github.com/blevesearch/zapx/v15 v15.3.13 // indirect
 	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/cloudflare/circl v1.3.7 // indirect
 	github.com/cpuguy83/go-md2man/v2 v2.0.2 // indirect
 	github.com/creack/pty v1.1.21 // indirect
 	github.com/dgraph-io/ristretto v0.1.1 // indirect
@@ -120,10 +124,10 @@ require (
 	github.com/yookoala/realpath v1.0.0 // indirect
 	github.com/yosssi/gohtml v0.0.0-20201013000340-ee4748c638f4 // indirect
 	go.etcd.io/bbolt v1.3.8 // indirect
-	golang.org/x/crypto v0.16.0 // indirect
+	golang.org/x/crypto v0.17.0 // indirect
 	golang.org/x/exp v0.0.0-20230315142452-642cacee5cc0 // indirect
 	golang.org/x/net v0.19.0 // indirect
-	golang.org/x/sys v0.15.0 // indirect
+	golang.org/x/sys v0.16.0 // indirect
 	golang.org/x/term v0.15.0 // indirect
 	golang.org/x/text v0.14.0 // indirect
 	google.golang.org/protobuf v1.31.0 // indirect
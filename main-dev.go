@@ -26,11 +26,6 @@ func init() {
 		MountLocalPath("/", "public").
 		Make()
 
-	fAptRepo = public.NewTagged("fs-public-apt-repo").
-		MountLocalPath("/"+UseAptFlavour, UseBasePath+"/"+UseAptFlavour).
-		SetRegexCacheControl("/dists/", "no-store").
-		Make()
-
 	fContent = content.New().
 		MountLocalPath("/", "content").
 		AddToIndexProviders("pages-pql").
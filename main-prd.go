@@ -34,11 +34,6 @@ func init() {
 		MountEmbedPath("/", "public", publicFs).
 		Make()
 
-	fAptRepo = public.NewTagged("fs-public-apt-repo").
-		MountLocalPath("/"+UseAptFlavour, UseBasePath+"/"+UseAptFlavour).
-		SetRegexCacheControl("/dists/", "no-store").
-		Make()
-
 	fContent = content.New().
 		MountEmbedPath("/", "content", contentFs).
 		AddToIndexProviders("pages-pql").
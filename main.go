@@ -17,6 +17,9 @@ package main
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/go-enjin/golang-org-x-text/language"
 
@@ -26,6 +29,7 @@ import (
 	"github.com/go-enjin/be"
 	"github.com/go-enjin/be/drivers/fts/bleve"
 	"github.com/go-enjin/be/drivers/kvs/gocache"
+	"github.com/go-enjin/be/features/fs/public"
 	"github.com/go-enjin/be/features/fs/themes"
 	"github.com/go-enjin/be/features/pages/pql"
 	"github.com/go-enjin/be/features/pages/robots"
@@ -36,6 +40,7 @@ import (
 	"github.com/go-enjin/be/pkg/log"
 	"github.com/go-enjin/be/presets/defaults"
 
+	"github.com/go-enjin/starter-apt-enjin/pkg/features/fs/locals/aptrepo"
 	"github.com/go-enjin/starter-apt-enjin/pkg/features/fs/locals/dpkgdeb"
 )
 
@@ -53,7 +58,10 @@ var (
 	SetupDebName       = ""
 	PkgSection         = ""
 	AptFlavour         = ""
+	AptFlavours        = ""
 	AptCodename        = ""
+	AptCodenames       = ""
+	AptSuites          = ""
 	AptComponents      = ""
 	AptArchitectures   = ""
 	AptPublicKeyFile   = ""
@@ -63,20 +71,132 @@ var (
 var (
 	UseBasePath   = env.Get("AE_BASEPATH", "apt-repository")
 	UseAptFlavour = env.Get("APT_FLAVOUR", AptFlavour)
+	// UseAptFlavours are the space separated names of any further flavours to
+	// serve alongside the UseAptFlavour, for example "ubuntu raspbian"
+	UseAptFlavours = env.Get("APT_FLAVOURS", AptFlavours)
+	// UseAptCodenames are the distributions to publish, defaulting to the
+	// AptCodename
+	UseAptCodenames = env.Get("APT_CODENAMES", AptCodenames)
+	// UseAptSuites are space separated suite=codename aliases, for example
+	// "stable=bookworm testing=trixie"
+	UseAptSuites = env.Get("APT_SUITES", AptSuites)
+
+	UseDpkgDebFallback = env.Get("AE_DPKG_DEB_FALLBACK", "false") == "true"
+	UseRescanInterval  = env.Get("AE_RESCAN_INTERVAL", "1m")
+	UseScanWorkers     = env.Get("AE_SCAN_WORKERS", "0")
+	UseBackgroundIndex = env.Get("AE_BACKGROUND_INDEXING", "false") == "true"
+	UseCachePath       = env.Get("AE_CACHE_PATH", UseBasePath+"/.cache/dpkg-deb")
+	UsePageCacheSize   = env.Get("AE_PAGE_CACHE_SIZE", "0")
+	UseApiPath         = env.Get("AE_API_PATH", "/api/v1")
+	UseFileSizeLimit   = env.Get("AE_FILE_SIZE_LIMIT", strconv.FormatInt(dpkgdeb.DefaultFileSizeLimit, 10))
+	UseBasePackages    = env.Get("AE_BASE_PACKAGES", "")
+	UseSourceKeyring   = env.Get("AE_SOURCE_KEYRING", "")
+
+	UseArchivesPath = env.Get("AE_ARCHIVES", "apt-archives")
+	UseRepoBuilder  = env.Get("AE_REPO_BUILDER", "false") == "true"
+	UseRetain       = env.Get("AE_RETAIN_VERSIONS", "0")
+
+	UseGpgFile = env.Get("AE_GPG_FILE", "")
+	UseGpgKey  = env.Get("AE_GPG_KEY", "")
+	UseSignKey = env.Get("AE_SIGN_KEY", "")
 
 	fThemes  feature.Feature
 	fPublic  feature.Feature
 	fContent feature.Feature
-	fAptRepo feature.Feature
+
+	gAptFlavours []*aptFlavour
 )
 
 func init() {
-	if AptFlavour == "" {
-		log.FatalF("build error: .AptFlavour is empty\n")
+	if gAptFlavours = aptFlavours(); len(gAptFlavours) == 0 {
+		log.FatalF("build error: .AptFlavour and .AptFlavours are empty\n")
 	}
 }
 
+func parseInt(name, value string) (i int) {
+	var err error
+	if i, err = strconv.Atoi(value); err != nil {
+		log.FatalF("error parsing %v integer: %q - %v\n", name, value, err)
+	}
+	return
+}
+
+func parseDuration(name, value string) (d time.Duration) {
+	var err error
+	if d, err = time.ParseDuration(value); err != nil {
+		log.FatalF("error parsing %v duration: %q - %v\n", name, value, err)
+	}
+	return
+}
+
 func main() {
+	primary := gAptFlavours[0]
+	var flavourContexts []map[string]interface{}
+	for _, flavour := range gAptFlavours {
+		flavourContexts = append(flavourContexts, flavour.Context())
+	}
+
+	fAptRepo := public.NewTagged("fs-public-apt-repo").
+		SetRegexCacheControl("/dists/", "no-store")
+
+	dpkgDeb := dpkgdeb.New().
+		SetDpkgDebFallback(UseDpkgDebFallback).
+		SetRescanInterval(parseDuration("AE_RESCAN_INTERVAL", UseRescanInterval)).
+		SetScanWorkers(parseInt("AE_SCAN_WORKERS", UseScanWorkers)).
+		SetBackgroundIndexing(UseBackgroundIndex).
+		SetCachePath(UseCachePath).
+		SetPageCacheSize(parseInt("AE_PAGE_CACHE_SIZE", UsePageCacheSize)).
+		SetApiPath(UseApiPath).
+		SetFileSizeLimit(int64(parseInt("AE_FILE_SIZE_LIMIT", UseFileSizeLimit))).
+		SetSourceKeyring(UseSourceKeyring)
+	if len(gAptFlavours) > 1 {
+		// the main menu switches between the flavours
+		dpkgDeb.SetMenu("main-menu")
+	}
+
+	var fRepoBuilders []feature.Feature
+	for _, flavour := range gAptFlavours {
+		if UseRepoBuilder {
+			// the repository of a new flavour is mounted before it is built
+			if err := os.MkdirAll(flavour.RepositoryPath, 0755); err != nil {
+				log.FatalF("error making %v repository path: %v\n", flavour.Name, err)
+			}
+		}
+
+		fAptRepo.MountLocalPath(flavour.Mount(), flavour.RepositoryPath)
+
+		dpkgDeb.
+			MountPath(flavour.DebMount(), flavour.RepositoryPath).
+			SetDownloadUrl(flavour.RepositoryPath, flavour.Mount())
+		for codename, patterns := range flavour.BasePackages {
+			dpkgDeb.AddBasePackages(flavour.RepositoryPath, codename, patterns...)
+		}
+
+		if !UseRepoBuilder {
+			continue
+		}
+		repoBuilder := aptrepo.NewTagged(feature.Tag("local-apt-repo-" + flavour.Name)).
+			SetArchivesPath(flavour.ArchivesPath).
+			SetRepositoryPath(flavour.RepositoryPath).
+			SetOrigin(SiteName).
+			SetLabel(SiteTag).
+			SetDescription(SiteTagLine).
+			SetCheckInterval(parseDuration("AE_RESCAN_INTERVAL", UseRescanInterval)).
+			SetRetainVersions(parseInt("AE_RETAIN_VERSIONS", UseRetain))
+		for _, codename := range flavour.Codenames {
+			repoBuilder.AddDistribution(codename, flavour.Components, flavour.Architectures)
+			if suite, ok := flavour.Suites[codename]; ok {
+				repoBuilder.SetSuite(codename, suite)
+			}
+		}
+		if flavour.GpgKey != "" {
+			repoBuilder.SetSigningKey([]byte(flavour.GpgKey), flavour.SignKey)
+		} else if flavour.GpgFile != "" {
+			repoBuilder.SetSigningKeyFile(flavour.GpgFile, flavour.SignKey)
+		}
+		fRepoBuilders = append(fRepoBuilders, repoBuilder.Make())
+	}
+
 	enjin := be.New().
 		SiteTag(SiteTag).
 		SiteName(SiteName).
@@ -92,10 +212,13 @@ func main() {
 		Set("SetupPackageUrl", SetupDebUrl).
 		Set("SetupPackageName", SetupDebName).
 		Set("PkgSection", PkgSection).
-		Set("AptFlavour", AptFlavour).
-		Set("AptCodename", AptCodename).
-		Set("AptComponents", AptComponents).
-		Set("AptArchitectures", AptArchitectures).
+		Set("AptFlavour", primary.Name).
+		Set("AptFlavours", flavourContexts).
+		Set("AptCodename", primary.Codename).
+		Set("AptCodenames", strings.Join(primary.Codenames, " ")).
+		Set("AptSuites", UseAptSuites).
+		Set("AptComponents", strings.Join(primary.Components, " ")).
+		Set("AptArchitectures", strings.Join(primary.Architectures, " ")).
 		Set("AptPublicKeyFile", AptPublicKeyFile).
 		Set("AptSourcesListFile", AptSourcesListFile).
 		AddPreset(defaults.New().Make()).
@@ -119,11 +242,10 @@ func main() {
 				Make()).
 			Make()).
 		AddFeature(fPublic).
-		AddFeature(fAptRepo).
+		AddFeature(fRepoBuilders...).
+		AddFeature(fAptRepo.Make()).
 		AddFeature(fContent).
-		AddFeature(dpkgdeb.New().
-			MountPath("/dpkg-deb/"+UseAptFlavour, UseBasePath+"/"+UseAptFlavour).
-			Make()).
+		AddFeature(dpkgDeb.Make()).
 		SetPublicAccess(
 			feature.NewAction("enjin", "view", "page"),
 			feature.NewAction("fs-content", "view", "page"),
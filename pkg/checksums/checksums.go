@@ -0,0 +1,64 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checksums provides single-pass computation of the digests used
+// throughout apt repository indices
+package checksums
+
+import (
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"io"
+	"os"
+)
+
+// Sums is the size and hex encoded digests of some content
+type Sums struct {
+	Size   int64  `json:"size"`
+	MD5    string `json:"md5"`
+	SHA1   string `json:"sha1"`
+	SHA256 string `json:"sha256"`
+	SHA512 string `json:"sha512"`
+}
+
+// HashReader reads all of r and returns the Sums of the content read
+func HashReader(r io.Reader) (sums *Sums, err error) {
+	hMD5, hSHA1, hSHA256, hSHA512 := md5.New(), sha1.New(), sha256.New(), sha512.New()
+	var size int64
+	if size, err = io.Copy(io.MultiWriter(hMD5, hSHA1, hSHA256, hSHA512), r); err != nil {
+		return
+	}
+	sums = &Sums{
+		Size:   size,
+		MD5:    hex.EncodeToString(hMD5.Sum(nil)),
+		SHA1:   hex.EncodeToString(hSHA1.Sum(nil)),
+		SHA256: hex.EncodeToString(hSHA256.Sum(nil)),
+		SHA512: hex.EncodeToString(hSHA512.Sum(nil)),
+	}
+	return
+}
+
+// HashFile returns the Sums of the file at the given path
+func HashFile(path string) (sums *Sums, err error) {
+	var fh *os.File
+	if fh, err = os.Open(path); err != nil {
+		return
+	}
+	defer fh.Close()
+	sums, err = HashReader(fh)
+	return
+}
@@ -0,0 +1,196 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package changelog provides parsing of debian/changelog files, as described
+// in deb-changelog(5)
+package changelog
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	rxHeader  = regexp.MustCompile(`^(\w[-+0-9a-z.]*) \(([^() \t]+)\)((?:\s+[-+0-9a-zA-Z./]+)+)\s*;(.*)$`)
+	rxTrailer = regexp.MustCompile(`^ -- (.*?)\s*<([^>]*)>\s*(.*?)\s*$`)
+)
+
+// DateLayouts are the accepted trailer date formats, in the order tried
+var DateLayouts = []string{
+	"Mon, _2 Jan 2006 15:04:05 -0700",
+	"Mon, 02 Jan 2006 15:04:05 -0700",
+	"Mon, _2 Jan 2006 15:04:05 -0700 (MST)",
+	"_2 Jan 2006 15:04:05 -0700",
+	"Mon _2 Jan 2006 15:04:05 -0700",
+}
+
+// Change is a single top-level change item and any nested sub-items
+type Change struct {
+	Text    string   `json:"text"`
+	Details []string `json:"details,omitempty"`
+}
+
+// Entry is a single version stanza of a changelog
+type Entry struct {
+	Package       string            `json:"package"`
+	Version       string            `json:"version"`
+	Distributions []string          `json:"distributions"`
+	Urgency       string            `json:"urgency,omitempty"`
+	Metadata      map[string]string `json:"metadata,omitempty"`
+	Changes       []*Change         `json:"changes"`
+	Maintainer    string            `json:"maintainer"`
+	Email         string            `json:"email"`
+	// Date is zero when the DateString could not be parsed
+	Date       time.Time `json:"date"`
+	DateString string    `json:"date_string"`
+}
+
+// Author returns the maintainer in "Name <email>" form
+func (e *Entry) Author() (author string) {
+	author = e.Maintainer
+	if e.Email != "" {
+		author = strings.TrimSpace(author + " <" + e.Email + ">")
+	}
+	return
+}
+
+// Header returns the first line of the entry
+func (e *Entry) Header() (header string) {
+	header = fmt.Sprintf("%s (%s) %s;", e.Package, e.Version, strings.Join(e.Distributions, " "))
+	var meta []string
+	if e.Urgency != "" {
+		meta = append(meta, "urgency="+e.Urgency)
+	}
+	keys := make([]string, 0, len(e.Metadata))
+	for key := range e.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		meta = append(meta, key+"="+e.Metadata[key])
+	}
+	if len(meta) > 0 {
+		header += " " + strings.Join(meta, ", ")
+	}
+	return
+}
+
+// ParseString is a convenience wrapper around Parse
+func ParseString(input string) (entries []*Entry, err error) {
+	entries, err = Parse(strings.NewReader(input))
+	return
+}
+
+// Parse reads all the changelog entries from the given reader, newest first.
+// Parsing is lenient: lines which are not part of an entry are ignored and
+// parsing stops at any "Local variables:" or "Old Changelog:" section
+func Parse(r io.Reader) (entries []*Entry, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var entry *Entry
+	var change *Change
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+
+		if m := rxHeader.FindStringSubmatch(line); m != nil {
+			entry = parseHeader(m)
+			entries = append(entries, entry)
+			change = nil
+			continue
+		} else if strings.HasPrefix(line, "Local variables:") || strings.HasPrefix(line, "Old Changelog:") {
+			break
+		} else if entry == nil || line == "" {
+			continue
+		}
+
+		if m := rxTrailer.FindStringSubmatch(line); m != nil {
+			entry.Maintainer, entry.Email, entry.DateString = m[1], m[2], m[3]
+			entry.Date = parseDate(entry.DateString)
+			entry, change = nil, nil
+			continue
+		}
+
+		if !strings.HasPrefix(line, "  ") {
+			continue
+		}
+		change = appendChangeLine(entry, change, line)
+	}
+
+	err = scanner.Err()
+	return
+}
+
+func parseHeader(m []string) (entry *Entry) {
+	entry = &Entry{
+		Package:       m[1],
+		Version:       m[2],
+		Distributions: strings.Fields(m[3]),
+	}
+	for _, pair := range strings.Split(m[4], ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+		if key = strings.ToLower(strings.TrimSpace(key)); key == "" {
+			continue
+		} else if key == "urgency" {
+			entry.Urgency = strings.TrimSpace(value)
+			continue
+		}
+		if entry.Metadata == nil {
+			entry.Metadata = make(map[string]string)
+		}
+		entry.Metadata[key] = strings.TrimSpace(value)
+	}
+	return
+}
+
+// appendChangeLine adds the change line to the entry, returning the current
+// top-level change. Lines indented by two spaces start a new change, deeper
+// indented bullets start a new detail and anything else continues the last
+// change or detail
+func appendChangeLine(entry *Entry, change *Change, line string) (current *Change) {
+	current = change
+	text := strings.TrimSpace(line)
+	indent := len(line) - len(strings.TrimLeft(line, " \t"))
+
+	if indent <= 2 || current == nil {
+		text = strings.TrimSpace(strings.TrimPrefix(text, "*"))
+		current = &Change{Text: text}
+		entry.Changes = append(entry.Changes, current)
+		return
+	}
+
+	if len(text) > 1 && strings.ContainsRune("-+*", rune(text[0])) && text[1] == ' ' {
+		current.Details = append(current.Details, strings.TrimSpace(text[1:]))
+	} else if last := len(current.Details) - 1; last >= 0 {
+		current.Details[last] += " " + text
+	} else {
+		current.Text += " " + text
+	}
+	return
+}
+
+func parseDate(value string) (date time.Time) {
+	for _, layout := range DateLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			date = parsed
+			return
+		}
+	}
+	return
+}
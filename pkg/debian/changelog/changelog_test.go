@@ -0,0 +1,130 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package changelog
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+const testChangelog = `hello (2.10-3) unstable experimental; urgency=medium, binary-only=yes
+
+  * New upstream release.
+    - Drop the patch applied upstream.
+    - Refresh the remaining patches, which
+      no longer apply cleanly.
+  * Update the watch file
+    to the new download location.
+
+ -- Jane Doe <jane@example.org>  Mon,  2 Jan 2023 15:04:05 +0000
+
+hello (2.10-2) UNRELEASED; URGENCY=low
+
+  [ John Doe ]
+  * Fix the build.
+
+ -- John Doe <john@example.org>  not a date
+
+Local variables:
+hello (2.10-1) unstable; urgency=low
+`
+
+func TestParse(t *testing.T) {
+	entries, err := ParseString(testChangelog)
+	if err != nil {
+		t.Fatalf("ParseString unexpected error: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("ParseString found %d entries, want 2", len(entries))
+	}
+
+	first, second := entries[0], entries[1]
+	tests := []struct {
+		got, want string
+	}{
+		{first.Package, "hello"},
+		{first.Version, "2.10-3"},
+		{fmt.Sprint(first.Distributions), "[unstable experimental]"},
+		{first.Urgency, "medium"},
+		{fmt.Sprint(first.Metadata), "map[binary-only:yes]"},
+		{first.Header(), "hello (2.10-3) unstable experimental; urgency=medium, binary-only=yes"},
+		{first.Author(), "Jane Doe <jane@example.org>"},
+		{first.Date.Format(time.RFC3339), "2023-01-02T15:04:05Z"},
+		{fmt.Sprint(len(first.Changes)), "2"},
+		{first.Changes[0].Text, "New upstream release."},
+		{fmt.Sprint(first.Changes[0].Details), "[Drop the patch applied upstream. Refresh the remaining patches, which no longer apply cleanly.]"},
+		{first.Changes[1].Text, "Update the watch file to the new download location."},
+		{second.Urgency, "low"},
+		{second.Header(), "hello (2.10-2) UNRELEASED; urgency=low"},
+		{second.Changes[0].Text, "[ John Doe ]"},
+		{second.Changes[1].Text, "Fix the build."},
+		{second.DateString, "not a date"},
+		{fmt.Sprint(second.Date.IsZero()), "true"},
+	}
+	for idx, test := range tests {
+		if test.got != test.want {
+			t.Errorf("entry test %d = %q, want %q", idx, test.got, test.want)
+		}
+	}
+}
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "[]"},
+		{"not a changelog\n", "[]"},
+		{"hello (1.0) unstable;\n", "[hello (1.0) unstable;]"},
+		{"hello (1:1.0~rc1+dfsg-1) stable-security; urgency=high\n", "[hello (1:1.0~rc1+dfsg-1) stable-security; urgency=high]"},
+		{"hello (1.0) unstable; urgency=low\r\n\r\nhello (0.9) unstable; urgency=low\r\n", "[hello (1.0) unstable; urgency=low hello (0.9) unstable; urgency=low]"},
+		{"hello 1.0 unstable; urgency=low\n", "[]"},
+		{"Old Changelog:\nhello (1.0) unstable; urgency=low\n", "[]"},
+	}
+	for _, test := range tests {
+		entries, err := ParseString(test.input)
+		if err != nil {
+			t.Errorf("ParseString(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		var headers []string
+		for _, entry := range entries {
+			headers = append(headers, entry.Header())
+		}
+		if got := fmt.Sprint(headers); got != test.want {
+			t.Errorf("ParseString(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseDate(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Mon,  2 Jan 2023 15:04:05 +0000", "2023-01-02T15:04:05Z"},
+		{"Mon, 02 Jan 2023 15:04:05 -0700", "2023-01-02T15:04:05-07:00"},
+		{"Mon, 2 Jan 2023 15:04:05 +0100 (CET)", "2023-01-02T15:04:05+01:00"},
+		{"2 Jan 2023 15:04:05 +0000", "2023-01-02T15:04:05Z"},
+		{"Mon 2 Jan 2023 15:04:05 +0000", "2023-01-02T15:04:05Z"},
+		{"2023-01-02", "0001-01-01T00:00:00Z"},
+	}
+	for _, test := range tests {
+		if got := parseDate(test.input).Format(time.RFC3339); got != test.want {
+			t.Errorf("parseDate(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
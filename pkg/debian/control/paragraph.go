@@ -0,0 +1,259 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package control provides a deb822 paragraph parser suitable for Debian
+// control files, Packages, Sources, Release and .dsc files
+package control
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/version"
+)
+
+// Field is a single named value within a Paragraph. Value holds the text
+// following the colon on the first line, with any continuation lines
+// appended verbatim, each preceded by a newline
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Paragraph is an ordered list of fields, field names are matched without
+// regard to case and may be repeated
+type Paragraph struct {
+	Fields []*Field
+}
+
+// NewParagraph constructs a new, empty Paragraph instance
+func NewParagraph() (p *Paragraph) {
+	p = &Paragraph{}
+	return
+}
+
+// Len returns the number of fields in the Paragraph
+func (p *Paragraph) Len() (count int) {
+	count = len(p.Fields)
+	return
+}
+
+// Names returns the field names in the order they were parsed, repeated names
+// are only listed once
+func (p *Paragraph) Names() (names []string) {
+	seen := make(map[string]struct{})
+	for _, field := range p.Fields {
+		key := strings.ToLower(field.Name)
+		if _, present := seen[key]; !present {
+			seen[key] = struct{}{}
+			names = append(names, field.Name)
+		}
+	}
+	return
+}
+
+// Has returns true if the named field is present
+func (p *Paragraph) Has(name string) (present bool) {
+	present = p.Field(name) != nil
+	return
+}
+
+// Field returns the first Field with the given name
+func (p *Paragraph) Field(name string) (field *Field) {
+	for _, f := range p.Fields {
+		if strings.EqualFold(f.Name, name) {
+			return f
+		}
+	}
+	return
+}
+
+// Value returns the raw value of the first field with the given name
+func (p *Paragraph) Value(name string) (value string) {
+	if field := p.Field(name); field != nil {
+		value = field.Value
+	}
+	return
+}
+
+// Values returns the raw values of all fields with the given name
+func (p *Paragraph) Values(name string) (values []string) {
+	for _, f := range p.Fields {
+		if strings.EqualFold(f.Name, name) {
+			values = append(values, f.Value)
+		}
+	}
+	return
+}
+
+// Folded returns the value of the named field with all continuation lines
+// joined with single spaces, suitable for fields like Depends and Uploaders
+func (p *Paragraph) Folded(name string) (value string) {
+	value = Fold(p.Value(name))
+	return
+}
+
+// Lines returns the continuation lines of the named field with their leading
+// whitespace removed, suitable for multiline fields like Conffiles and
+// Checksums-Sha256. An empty first line is omitted
+func (p *Paragraph) Lines(name string) (lines []string) {
+	value := p.Value(name)
+	for idx, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); idx == 0 && line == "" {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return
+}
+
+// Set replaces the value of the first field with the given name, or appends a
+// new field if not present
+func (p *Paragraph) Set(name, value string) {
+	if field := p.Field(name); field != nil {
+		field.Value = value
+		return
+	}
+	p.Add(name, value)
+}
+
+// Add appends a new field, regardless of any existing fields with the same
+// name
+func (p *Paragraph) Add(name, value string) {
+	p.Fields = append(p.Fields, &Field{Name: name, Value: value})
+}
+
+// Delete removes all fields with the given name
+func (p *Paragraph) Delete(name string) {
+	var fields []*Field
+	for _, f := range p.Fields {
+		if !strings.EqualFold(f.Name, name) {
+			fields = append(fields, f)
+		}
+	}
+	p.Fields = fields
+}
+
+// Copy returns a deep copy of this Paragraph
+func (p *Paragraph) Copy() (cloned *Paragraph) {
+	cloned = NewParagraph()
+	for _, f := range p.Fields {
+		cloned.Add(f.Name, f.Value)
+	}
+	return
+}
+
+// String returns the deb822 formatted text of this Paragraph, without the
+// trailing blank line separating paragraphs
+func (p *Paragraph) String() (text string) {
+	var buf strings.Builder
+	for _, f := range p.Fields {
+		buf.WriteString(f.Name + ":")
+		first, rest, multiline := strings.Cut(f.Value, "\n")
+		if first != "" {
+			buf.WriteString(" " + first)
+		}
+		buf.WriteString("\n")
+		if multiline {
+			for _, line := range strings.Split(rest, "\n") {
+				if !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") {
+					line = " " + line
+				}
+				buf.WriteString(line + "\n")
+			}
+		}
+	}
+	text = buf.String()
+	return
+}
+
+// Synopsis returns the first line of the Description field
+func (p *Paragraph) Synopsis() (synopsis string) {
+	synopsis, _, _ = strings.Cut(p.Value("Description"), "\n")
+	return
+}
+
+// LongDescription returns the continuation lines of the Description field,
+// verbatim
+func (p *Paragraph) LongDescription() (description string) {
+	_, description, _ = strings.Cut(p.Value("Description"), "\n")
+	return
+}
+
+// Maintainer returns the name and email address from the Maintainer field,
+// email is empty when the field has no address
+func (p *Paragraph) Maintainer() (name, email string) {
+	name, email = ParseContact(p.Folded("Maintainer"))
+	return
+}
+
+// Version returns the parsed Version field
+func (p *Paragraph) Version() (v version.Version, err error) {
+	if !p.Has("Version") {
+		err = fmt.Errorf("version field not found")
+		return
+	}
+	v, err = version.Parse(p.Value("Version"))
+	return
+}
+
+// InstalledSize returns the Installed-Size field, in kibibytes
+func (p *Paragraph) InstalledSize() (size int64, err error) {
+	if !p.Has("Installed-Size") {
+		err = fmt.Errorf("installed-size field not found")
+		return
+	}
+	size, err = strconv.ParseInt(strings.TrimSpace(p.Value("Installed-Size")), 10, 64)
+	return
+}
+
+// Depends returns the parsed Depends field
+func (p *Paragraph) Depends() (relations Relations, err error) {
+	relations, err = p.Relations("Depends")
+	return
+}
+
+// Relations returns the named field parsed as a list of package relationships
+func (p *Paragraph) Relations(name string) (relations Relations, err error) {
+	relations, err = ParseRelations(p.Folded(name))
+	return
+}
+
+// Fold joins all lines of the given value with single spaces
+func Fold(value string) (folded string) {
+	var parts []string
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			parts = append(parts, line)
+		}
+	}
+	folded = strings.Join(parts, " ")
+	return
+}
+
+// ParseContact splits an RFC-822 style "Name <email>" value, returning the
+// input as the name when there is no email address present
+func ParseContact(value string) (name, email string) {
+	value = strings.TrimSpace(value)
+	if start := strings.LastIndex(value, "<"); start >= 0 {
+		if end := strings.Index(value[start:], ">"); end > 0 {
+			name = strings.TrimSpace(value[:start])
+			email = strings.TrimSpace(value[start+1 : start+end])
+			return
+		}
+	}
+	name = value
+	return
+}
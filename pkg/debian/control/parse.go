@@ -0,0 +1,135 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const (
+	pgpSignedMessage = "-----BEGIN PGP SIGNED MESSAGE-----"
+	pgpSignature     = "-----BEGIN PGP SIGNATURE-----"
+)
+
+// Parse reads all deb822 paragraphs from the given reader. Comment lines
+// beginning with "#" are ignored and OpenPGP clearsigned input is unwrapped
+// without verification
+func Parse(r io.Reader) (paragraphs []*Paragraph, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	var current *Paragraph
+	var field *Field
+	var lineNo int
+	var signed, inHeaders bool
+
+	for scanner.Scan() {
+		lineNo += 1
+		line := strings.TrimRight(scanner.Text(), "\r")
+
+		if lineNo == 1 && line == pgpSignedMessage {
+			signed, inHeaders = true, true
+			continue
+		}
+		if signed {
+			if inHeaders {
+				// armor headers end with the first blank line
+				inHeaders = strings.TrimSpace(line) != ""
+				continue
+			}
+			if line == pgpSignature {
+				break
+			}
+			// undo dash-escaping
+			line = strings.TrimPrefix(line, "- ")
+		}
+
+		switch {
+		case strings.TrimSpace(line) == "":
+			if current != nil {
+				paragraphs = append(paragraphs, current)
+			}
+			current, field = nil, nil
+
+		case strings.HasPrefix(line, "#"):
+			continue
+
+		case line[0] == ' ' || line[0] == '\t':
+			if field == nil {
+				err = fmt.Errorf("line %d: continuation line without a field", lineNo)
+				return
+			}
+			field.Value += "\n" + line
+
+		default:
+			name, value, found := strings.Cut(line, ":")
+			if !found || name == "" || strings.ContainsAny(name, " \t") {
+				err = fmt.Errorf("line %d: malformed field: %q", lineNo, line)
+				return
+			}
+			if current == nil {
+				current = NewParagraph()
+			}
+			field = &Field{Name: name, Value: strings.TrimSpace(value)}
+			current.Fields = append(current.Fields, field)
+		}
+	}
+
+	if err = scanner.Err(); err != nil {
+		return
+	}
+	if current != nil {
+		paragraphs = append(paragraphs, current)
+	}
+	return
+}
+
+// ParseString is a convenience wrapper around Parse
+func ParseString(text string) (paragraphs []*Paragraph, err error) {
+	paragraphs, err = Parse(strings.NewReader(text))
+	return
+}
+
+// ParseParagraph parses the given text, which must contain exactly one
+// paragraph
+func ParseParagraph(text string) (p *Paragraph, err error) {
+	var paragraphs []*Paragraph
+	if paragraphs, err = ParseString(text); err != nil {
+		return
+	}
+	switch len(paragraphs) {
+	case 0:
+		err = fmt.Errorf("no paragraphs found")
+	case 1:
+		p = paragraphs[0]
+	default:
+		err = fmt.Errorf("expected one paragraph, found %d", len(paragraphs))
+	}
+	return
+}
+
+// Format returns the deb822 text of the given paragraphs, separated by blank
+// lines
+func Format(paragraphs ...*Paragraph) (text string) {
+	var parts []string
+	for _, p := range paragraphs {
+		parts = append(parts, p.String())
+	}
+	text = strings.Join(parts, "\n")
+	return
+}
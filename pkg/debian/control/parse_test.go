@@ -0,0 +1,187 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"fmt"
+	"testing"
+)
+
+const testControl = `# a comment before the paragraph
+Package: hello
+Version: 1:2.10-3
+Maintainer: Jane Doe <jane@example.org>
+Depends: libc6 (>= 2.34),
+ base-files
+# a comment between fields
+Conffiles:
+ /etc/hello.conf 0123456789abcdef0123456789abcdef
+ /etc/hello.d/extra.conf fedcba9876543210fedcba9876543210
+Description: example package
+ A longer description of the package.
+ .
+ With a second paragraph.
+`
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{"", nil},
+		{"\n\n", nil},
+		{testControl, []int{6}},
+		{"A: 1\n\nB: 2\nC: 3\n", []int{1, 2}},
+		{"A: 1\r\n\r\n\r\nB: 2\r\n", []int{1, 1}},
+		{"A: 1\n \t\nB: 2\n", []int{1, 1}},
+		{"-----BEGIN PGP SIGNED MESSAGE-----\nHash: SHA256\n\nA: 1\n- -B: 2\n-----BEGIN PGP SIGNATURE-----\n\nC: 3\n", []int{2}},
+	}
+	for _, test := range tests {
+		paragraphs, err := ParseString(test.input)
+		if err != nil {
+			t.Errorf("ParseString(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		var got []int
+		for _, p := range paragraphs {
+			got = append(got, p.Len())
+		}
+		if fmt.Sprint(got) != fmt.Sprint(test.want) {
+			t.Errorf("ParseString(%q) field counts = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, input := range []string{
+		" continued without a field\n",
+		"A: 1\n\n continued without a field\n",
+		"no colon\n",
+		": no name\n",
+		"Bad Name: value\n",
+	} {
+		if paragraphs, err := ParseString(input); err == nil {
+			t.Errorf("ParseString(%q) = %v, want error", input, paragraphs)
+		}
+	}
+}
+
+func TestParseParagraph(t *testing.T) {
+	tests := []struct {
+		input string
+		ok    bool
+	}{
+		{"A: 1\n", true},
+		{"\n\nA: 1\n\n", true},
+		{"", false},
+		{"A: 1\n\nB: 2\n", false},
+	}
+	for _, test := range tests {
+		if _, err := ParseParagraph(test.input); (err == nil) != test.ok {
+			t.Errorf("ParseParagraph(%q) error = %v, want ok %v", test.input, err, test.ok)
+		}
+	}
+}
+
+func TestParagraphFields(t *testing.T) {
+	p, err := ParseParagraph(testControl)
+	if err != nil {
+		t.Fatalf("ParseParagraph unexpected error: %v", err)
+	}
+	tests := []struct {
+		got, want string
+	}{
+		{p.Value("package"), "hello"},
+		{p.Value("DEPENDS"), "libc6 (>= 2.34),\n base-files"},
+		{p.Folded("Depends"), "libc6 (>= 2.34), base-files"},
+		{fmt.Sprint(p.Lines("Conffiles")), "[/etc/hello.conf 0123456789abcdef0123456789abcdef /etc/hello.d/extra.conf fedcba9876543210fedcba9876543210]"},
+		{p.Value("Conffiles"), "\n /etc/hello.conf 0123456789abcdef0123456789abcdef\n /etc/hello.d/extra.conf fedcba9876543210fedcba9876543210"},
+		{p.Synopsis(), "example package"},
+		{p.LongDescription(), " A longer description of the package.\n .\n With a second paragraph."},
+		{fmt.Sprint(p.Names()), "[Package Version Maintainer Depends Conffiles Description]"},
+		{p.Value("Missing"), ""},
+	}
+	for idx, test := range tests {
+		if test.got != test.want {
+			t.Errorf("field test %d = %q, want %q", idx, test.got, test.want)
+		}
+	}
+
+	if name, email := p.Maintainer(); name != "Jane Doe" || email != "jane@example.org" {
+		t.Errorf("Maintainer() = %q, %q", name, email)
+	}
+	if v, err := p.Version(); err != nil || v.String() != "1:2.10-3" {
+		t.Errorf("Version() = %v, %v", v, err)
+	}
+	if _, err := p.InstalledSize(); err == nil {
+		t.Errorf("InstalledSize() without the field expected an error")
+	}
+}
+
+func TestParagraphString(t *testing.T) {
+	// comments are dropped, everything else survives a round trip
+	paragraphs, err := ParseString(testControl + "\nPackage: other\n")
+	if err != nil {
+		t.Fatalf("ParseString unexpected error: %v", err)
+	}
+	text := Format(paragraphs...)
+	again, err := ParseString(text)
+	if err != nil {
+		t.Fatalf("ParseString(Format()) unexpected error: %v", err)
+	}
+	if got := Format(again...); got != text {
+		t.Errorf("Format round trip = %q, want %q", got, text)
+	}
+	if got := paragraphs[1].String(); got != "Package: other\n" {
+		t.Errorf("String() = %q", got)
+	}
+}
+
+func TestParagraphEdit(t *testing.T) {
+	p := NewParagraph()
+	p.Add("Tag", "one")
+	p.Add("tag", "two")
+	p.Set("Name", "first")
+	p.Set("NAME", "second")
+	cloned := p.Copy()
+	p.Delete("TAG")
+
+	if got := fmt.Sprint(cloned.Values("Tag")); got != "[one two]" {
+		t.Errorf("Values(Tag) = %v, want [one two]", got)
+	}
+	if p.Has("Tag") || p.Len() != 1 || p.Value("Name") != "second" {
+		t.Errorf("edited paragraph = %q", p.String())
+	}
+	if cloned.Len() != 3 {
+		t.Errorf("Copy() was modified by Delete: %q", cloned.String())
+	}
+}
+
+func TestParseContact(t *testing.T) {
+	tests := []struct {
+		input, name, email string
+	}{
+		{"Jane Doe <jane@example.org>", "Jane Doe", "jane@example.org"},
+		{"  <jane@example.org>  ", "", "jane@example.org"},
+		{"Jane Doe", "Jane Doe", ""},
+		{"Jane <Doe", "Jane <Doe", ""},
+		{"", "", ""},
+	}
+	for _, test := range tests {
+		if name, email := ParseContact(test.input); name != test.name || email != test.email {
+			t.Errorf("ParseContact(%q) = %q, %q, want %q, %q", test.input, name, email, test.name, test.email)
+		}
+	}
+}
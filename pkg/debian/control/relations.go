@@ -0,0 +1,175 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/version"
+)
+
+var (
+	rxRelation = regexp.MustCompile(`^([a-zA-Z0-9][-+.a-zA-Z0-9]*)(?::([a-z0-9]+))?\s*(?:\(\s*(<<|<=|=|>=|>>|<|>)\s*([^)\s<>=][^)\s]*)\s*\))?\s*(?:\[([^]]*)\])?\s*((?:<[^>]*>\s*)*)$`)
+)
+
+// Relation is a single package relationship, for example:
+//
+//	libc6:any (>= 2.31) [amd64 arm64] <!nocheck>
+type Relation struct {
+	Name          string   `json:"name"`
+	ArchQualifier string   `json:"arch_qualifier,omitempty"`
+	Operator      string   `json:"operator,omitempty"`
+	Version       string   `json:"version,omitempty"`
+	Architectures []string `json:"architectures,omitempty"`
+	Profiles      []string `json:"profiles,omitempty"`
+}
+
+// Alternatives is a list of relations separated by "|", any one of which
+// satisfies the requirement
+type Alternatives []*Relation
+
+// Relations is a list of comma separated requirements
+type Relations []Alternatives
+
+// ParseRelations parses the folded value of a relationship field such as
+// Depends, Build-Depends or Provides
+func ParseRelations(value string) (relations Relations, err error) {
+	value = Fold(value)
+	if value == "" {
+		return
+	}
+	for _, group := range strings.Split(value, ",") {
+		if group = strings.TrimSpace(group); group == "" {
+			continue
+		}
+		var alternatives Alternatives
+		for _, item := range strings.Split(group, "|") {
+			var relation *Relation
+			if relation, err = ParseRelation(item); err != nil {
+				return
+			}
+			alternatives = append(alternatives, relation)
+		}
+		relations = append(relations, alternatives)
+	}
+	return
+}
+
+// ParseRelation parses a single package relationship
+func ParseRelation(value string) (relation *Relation, err error) {
+	value = strings.TrimSpace(value)
+	m := rxRelation.FindStringSubmatch(value)
+	if m == nil {
+		err = fmt.Errorf("malformed package relationship: %q", value)
+		return
+	}
+	relation = &Relation{
+		Name:          m[1],
+		ArchQualifier: m[2],
+		Operator:      m[3],
+		Version:       m[4],
+		Architectures: strings.Fields(m[5]),
+	}
+	for _, profile := range strings.Split(m[6], ">") {
+		if profile = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(profile), "<")); profile != "" {
+			relation.Profiles = append(relation.Profiles, "<"+profile+">")
+		}
+	}
+	switch relation.Operator {
+	case "<":
+		relation.Operator = "<="
+	case ">":
+		relation.Operator = ">="
+	}
+	return
+}
+
+// Satisfies returns true if the given version meets the version constraint
+// of this Relation, any version satisfies a Relation without a constraint
+func (r *Relation) Satisfies(v string) (ok bool) {
+	if r.Operator == "" {
+		ok = true
+		return
+	}
+	result := version.Compare(v, r.Version)
+	switch r.Operator {
+	case "<<":
+		ok = result < 0
+	case "<=":
+		ok = result <= 0
+	case "=":
+		ok = result == 0
+	case ">=":
+		ok = result >= 0
+	case ">>":
+		ok = result > 0
+	}
+	return
+}
+
+// String returns the control file representation of this Relation
+func (r *Relation) String() (text string) {
+	text = r.Name
+	if r.ArchQualifier != "" {
+		text += ":" + r.ArchQualifier
+	}
+	if r.Operator != "" {
+		text += " (" + r.Operator + " " + r.Version + ")"
+	}
+	if len(r.Architectures) > 0 {
+		text += " [" + strings.Join(r.Architectures, " ") + "]"
+	}
+	if len(r.Profiles) > 0 {
+		text += " " + strings.Join(r.Profiles, " ")
+	}
+	return
+}
+
+// String returns the control file representation of these Alternatives
+func (a Alternatives) String() (text string) {
+	var parts []string
+	for _, r := range a {
+		parts = append(parts, r.String())
+	}
+	text = strings.Join(parts, " | ")
+	return
+}
+
+// String returns the control file representation of these Relations
+func (r Relations) String() (text string) {
+	var parts []string
+	for _, alternatives := range r {
+		parts = append(parts, alternatives.String())
+	}
+	text = strings.Join(parts, ", ")
+	return
+}
+
+// Names returns the unique package names referenced by these Relations, in
+// the order first seen
+func (r Relations) Names() (names []string) {
+	seen := make(map[string]struct{})
+	for _, alternatives := range r {
+		for _, relation := range alternatives {
+			if _, present := seen[relation.Name]; !present {
+				seen[relation.Name] = struct{}{}
+				names = append(names, relation.Name)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,93 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package control
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestParseRelations(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		names string
+	}{
+		{"", "", "[]"},
+		{"libc6", "libc6", "[libc6]"},
+		{"libc6 (>= 2.34), base-files", "libc6 (>= 2.34), base-files", "[libc6 base-files]"},
+		{"libc6 (>=2.34),\n base-files (<< 13)\n", "libc6 (>= 2.34), base-files (<< 13)", "[libc6 base-files]"},
+		{"default-mta | mail-transport-agent, default-mta", "default-mta | mail-transport-agent, default-mta", "[default-mta mail-transport-agent]"},
+		{"python3:any (> 3.9)", "python3:any (>= 3.9)", "[python3]"},
+		{"foo (< 2)", "foo (<= 2)", "[foo]"},
+		{"libfoo-dev [amd64 arm64] <!nocheck> <cross>", "libfoo-dev [amd64 arm64] <!nocheck> <cross>", "[libfoo-dev]"},
+		{"a,, b ,", "a, b", "[a b]"},
+	}
+	for _, test := range tests {
+		relations, err := ParseRelations(test.input)
+		if err != nil {
+			t.Errorf("ParseRelations(%q) unexpected error: %v", test.input, err)
+			continue
+		}
+		if got := relations.String(); got != test.want {
+			t.Errorf("ParseRelations(%q) = %q, want %q", test.input, got, test.want)
+		}
+		if got := fmt.Sprint(relations.Names()); got != test.names {
+			t.Errorf("ParseRelations(%q).Names() = %v, want %v", test.input, got, test.names)
+		}
+	}
+}
+
+func TestParseRelationErrors(t *testing.T) {
+	for _, input := range []string{
+		"-leading-dash",
+		"foo (>= )",
+		"foo (~ 1.0)",
+		"foo | , bar",
+		"foo [amd64",
+	} {
+		if relations, err := ParseRelations(input); err == nil {
+			t.Errorf("ParseRelations(%q) = %v, want error", input, relations)
+		}
+	}
+}
+
+func TestRelationSatisfies(t *testing.T) {
+	tests := []struct {
+		relation string
+		version  string
+		want     bool
+	}{
+		{"foo", "0", true},
+		{"foo (<< 2.0)", "1.9", true},
+		{"foo (<< 2.0)", "2.0", false},
+		{"foo (<= 2.0)", "2.0", true},
+		{"foo (= 1:2.0-1)", "1:2.0-1", true},
+		{"foo (= 1:2.0-1)", "2.0-1", false},
+		{"foo (>= 2.0)", "2.0~rc1", false},
+		{"foo (>> 2.0)", "2.0+b1", true},
+		{"foo (>> 2.0)", "2.0", false},
+	}
+	for _, test := range tests {
+		relation, err := ParseRelation(test.relation)
+		if err != nil {
+			t.Errorf("ParseRelation(%q) unexpected error: %v", test.relation, err)
+			continue
+		}
+		if got := relation.Satisfies(test.version); got != test.want {
+			t.Errorf("ParseRelation(%q).Satisfies(%q) = %v, want %v", test.relation, test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package copyright provides parsing of machine-readable debian/copyright
+// files, as described by the DEP-5 copyright-format 1.0 specification
+package copyright
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+var (
+	// ErrNotMachineReadable is returned when the first paragraph does not
+	// have a known Format field
+	ErrNotMachineReadable = errors.New("not a machine-readable copyright file")
+)
+
+// FormatUrls are the known Format field values, matched as prefixes without
+// regard to the scheme
+var FormatUrls = []string{
+	"www.debian.org/doc/packaging-manuals/copyright-format/1.0",
+	"dep.debian.net/deps/dep5",
+	"svn.debian.org/wsvn/dep/web/deps/dep5",
+}
+
+// License is a license short name, or expression of short names, with the
+// optional full text
+type License struct {
+	Name string `json:"name"`
+	Text string `json:"text,omitempty"`
+}
+
+// Files is a single Files paragraph
+type Files struct {
+	Patterns  []string `json:"patterns"`
+	Copyright []string `json:"copyright"`
+	License   License  `json:"license"`
+	Comment   string   `json:"comment,omitempty"`
+}
+
+// Copyright is a parsed machine-readable copyright file
+type Copyright struct {
+	Format          string   `json:"format"`
+	UpstreamName    string   `json:"upstream_name,omitempty"`
+	UpstreamContact []string `json:"upstream_contact,omitempty"`
+	Source          string   `json:"source,omitempty"`
+	Disclaimer      string   `json:"disclaimer,omitempty"`
+	Comment         string   `json:"comment,omitempty"`
+	// License is the optional header paragraph license of the package as a
+	// whole
+	License   *License `json:"license,omitempty"`
+	Copyright []string `json:"copyright,omitempty"`
+	Files     []*Files `json:"files"`
+	// Licenses are the stand-alone license paragraphs
+	Licenses []*License `json:"licenses,omitempty"`
+}
+
+// IsMachineReadable returns true if the given Format field value is one of
+// the known FormatUrls
+func IsMachineReadable(format string) (ok bool) {
+	format = strings.TrimSpace(format)
+	for _, prefix := range []string{"https://", "http://"} {
+		format = strings.TrimPrefix(format, prefix)
+	}
+	for _, known := range FormatUrls {
+		if ok = strings.HasPrefix(format, known); ok {
+			return
+		}
+	}
+	return
+}
+
+// ParseString is a convenience wrapper around Parse
+func ParseString(input string) (c *Copyright, err error) {
+	c, err = Parse(strings.NewReader(input))
+	return
+}
+
+// Parse reads a machine-readable copyright file from the given reader,
+// returning ErrNotMachineReadable for any other copyright file
+func Parse(r io.Reader) (c *Copyright, err error) {
+	var data []byte
+	if data, err = io.ReadAll(r); err != nil {
+		return
+	}
+	var paragraphs []*control.Paragraph
+	if paragraphs, err = control.ParseString(string(data)); err != nil {
+		// only report the syntax error for files claiming to be in the format
+		first, _, _ := strings.Cut(string(data), "\n")
+		if name, value, _ := strings.Cut(first, ":"); !strings.EqualFold(name, "Format") || !IsMachineReadable(value) {
+			err = ErrNotMachineReadable
+		}
+		return
+	} else if len(paragraphs) == 0 || !IsMachineReadable(paragraphs[0].Value("Format")) {
+		err = ErrNotMachineReadable
+		return
+	}
+
+	header := paragraphs[0]
+	c = &Copyright{
+		Format:          strings.TrimSpace(header.Value("Format")),
+		UpstreamName:    control.Fold(header.Value("Upstream-Name")),
+		UpstreamContact: lines(header.Value("Upstream-Contact")),
+		Source:          strings.TrimSpace(header.Value("Source")),
+		Disclaimer:      text(header.Value("Disclaimer")),
+		Comment:         text(header.Value("Comment")),
+		Copyright:       lines(header.Value("Copyright")),
+	}
+	if header.Has("License") {
+		c.License = parseLicense(header.Value("License"))
+	}
+
+	for idx, p := range paragraphs[1:] {
+		switch {
+		case p.Has("Files"):
+			if !p.Has("License") {
+				err = fmt.Errorf("paragraph %d: files paragraph missing license", idx+2)
+				return
+			}
+			c.Files = append(c.Files, &Files{
+				Patterns:  strings.Fields(p.Value("Files")),
+				Copyright: lines(p.Value("Copyright")),
+				License:   *parseLicense(p.Value("License")),
+				Comment:   text(p.Value("Comment")),
+			})
+		case p.Has("License"):
+			c.Licenses = append(c.Licenses, parseLicense(p.Value("License")))
+		default:
+			err = fmt.Errorf("paragraph %d: neither a files nor a license paragraph", idx+2)
+			return
+		}
+	}
+	return
+}
+
+// Summary returns the unique license names of the header and files
+// paragraphs, in the order first seen
+func (c *Copyright) Summary() (names []string) {
+	seen := make(map[string]struct{})
+	add := func(name string) {
+		if _, present := seen[name]; !present && name != "" {
+			seen[name] = struct{}{}
+			names = append(names, name)
+		}
+	}
+	if c.License != nil {
+		add(c.License.Name)
+	}
+	for _, files := range c.Files {
+		add(files.License.Name)
+	}
+	return
+}
+
+// LicenseText returns the full text of the named license, looking at the
+// stand-alone license paragraphs before any inline license texts
+func (c *Copyright) LicenseText(name string) (text string) {
+	for _, license := range c.Licenses {
+		if license.Name == name && license.Text != "" {
+			return license.Text
+		}
+	}
+	if c.License != nil && c.License.Name == name && c.License.Text != "" {
+		return c.License.Text
+	}
+	for _, files := range c.Files {
+		if files.License.Name == name && files.License.Text != "" {
+			return files.License.Text
+		}
+	}
+	return
+}
+
+func parseLicense(value string) (license *License) {
+	name, body, _ := strings.Cut(value, "\n")
+	license = &License{Name: strings.TrimSpace(name), Text: text(body)}
+	return
+}
+
+// lines returns the trimmed, non-empty lines of the given field value
+func lines(value string) (list []string) {
+	for _, line := range strings.Split(value, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			list = append(list, line)
+		}
+	}
+	return
+}
+
+// text returns the given field value as formatted text, removing the single
+// leading space of each line and replacing "." lines with blank lines
+func text(value string) (formatted string) {
+	var output []string
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimPrefix(strings.TrimPrefix(line, " "), "\t")
+		if strings.TrimSpace(line) == "." {
+			line = ""
+		}
+		output = append(output, strings.TrimRight(line, " \t"))
+	}
+	formatted = strings.Trim(strings.Join(output, "\n"), "\n")
+	return
+}
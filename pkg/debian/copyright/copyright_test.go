@@ -0,0 +1,141 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package copyright
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+const testCopyright = `Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/
+Upstream-Name: hello
+Upstream-Contact: Jane Doe <jane@example.org>
+ John Doe <john@example.org>
+Source: https://example.org/hello
+Comment: Repacked to remove
+ .
+ the non-free documentation.
+
+Files: *
+Copyright: 2020-2023 Jane Doe <jane@example.org>
+           2021 John Doe
+License: GPL-3+
+
+Files: debian/* docs/*.md
+Copyright: 2023 Debian Maintainer
+License: MIT
+ Permission is hereby granted, free of charge.
+ .
+ The above copyright notice shall be included.
+
+Files: lib/*
+Copyright: 2023 Jane Doe
+License: GPL-3+
+
+License: GPL-3+
+ This program is free software.
+ .
+ See /usr/share/common-licenses/GPL-3.
+`
+
+func TestParse(t *testing.T) {
+	c, err := ParseString(testCopyright)
+	if err != nil {
+		t.Fatalf("ParseString unexpected error: %v", err)
+	}
+	tests := []struct {
+		got, want string
+	}{
+		{c.UpstreamName, "hello"},
+		{fmt.Sprint(c.UpstreamContact), "[Jane Doe <jane@example.org> John Doe <john@example.org>]"},
+		{c.Source, "https://example.org/hello"},
+		{c.Comment, "Repacked to remove\n\nthe non-free documentation."},
+		{fmt.Sprint(c.License == nil), "true"},
+		{fmt.Sprint(len(c.Files)), "3"},
+		{fmt.Sprint(c.Files[0].Copyright), "[2020-2023 Jane Doe <jane@example.org> 2021 John Doe]"},
+		{fmt.Sprint(c.Files[1].Patterns), "[debian/* docs/*.md]"},
+		{c.Files[1].License.Name, "MIT"},
+		{fmt.Sprint(len(c.Licenses)), "1"},
+		{fmt.Sprint(c.Summary()), "[GPL-3+ MIT]"},
+		{c.LicenseText("GPL-3+"), "This program is free software.\n\nSee /usr/share/common-licenses/GPL-3."},
+		{c.LicenseText("MIT"), "Permission is hereby granted, free of charge.\n\nThe above copyright notice shall be included."},
+		{c.LicenseText("BSD-3-clause"), ""},
+	}
+	for idx, test := range tests {
+		if test.got != test.want {
+			t.Errorf("copyright test %d = %q, want %q", idx, test.got, test.want)
+		}
+	}
+}
+
+func TestParseHeaderLicense(t *testing.T) {
+	c, err := ParseString("Format: http://dep.debian.net/deps/dep5\nLicense: Apache-2.0\n Licensed under the Apache License.\nCopyright: 2023 Jane Doe\n")
+	if err != nil {
+		t.Fatalf("ParseString unexpected error: %v", err)
+	}
+	if c.License == nil || c.License.Name != "Apache-2.0" || len(c.Files) != 0 {
+		t.Fatalf("ParseString header license = %v, files = %v", c.License, c.Files)
+	}
+	if got := fmt.Sprint(c.Summary(), c.Copyright); got != "[Apache-2.0] [2023 Jane Doe]" {
+		t.Errorf("Summary and Copyright = %v", got)
+	}
+	if got := c.LicenseText("Apache-2.0"); got != "Licensed under the Apache License." {
+		t.Errorf("LicenseText(Apache-2.0) = %q", got)
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	const format = "Format: https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/\n"
+	tests := []struct {
+		input    string
+		readable bool
+	}{
+		{"", false},
+		{"This package was debianized by Jane Doe.\n\nIt was downloaded from example.org.\n", false},
+		{"Format: https://example.org/unknown-format\n\nFiles: *\nLicense: MIT\n", false},
+		{"Upstream-Name: hello\n", false},
+		{format + "\nFiles: *\nCopyright: 2023 Jane Doe\n", true},
+		{format + "\nCopyright: 2023 Jane Doe\n", true},
+		{format + "\n continued without a field\n", true},
+	}
+	for _, test := range tests {
+		c, err := ParseString(test.input)
+		if err == nil {
+			t.Errorf("ParseString(%q) = %v, want error", test.input, c)
+		} else if readable := !errors.Is(err, ErrNotMachineReadable); readable != test.readable {
+			t.Errorf("ParseString(%q) error = %v, want machine-readable %v", test.input, err, test.readable)
+		}
+	}
+}
+
+func TestIsMachineReadable(t *testing.T) {
+	tests := []struct {
+		format string
+		want   bool
+	}{
+		{"https://www.debian.org/doc/packaging-manuals/copyright-format/1.0/", true},
+		{" http://www.debian.org/doc/packaging-manuals/copyright-format/1.0 ", true},
+		{"http://dep.debian.net/deps/dep5/", true},
+		{"http://svn.debian.org/wsvn/dep/web/deps/dep5.mdwn?op=file&rev=174", true},
+		{"https://example.org/copyright-format", false},
+		{"", false},
+	}
+	for _, test := range tests {
+		if got := IsMachineReadable(test.format); got != test.want {
+			t.Errorf("IsMachineReadable(%q) = %v, want %v", test.format, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	arMagic      = "!<arch>\n"
+	arHeaderSize = 60
+)
+
+// arHeader describes a single member of a common ar(1) archive
+type arHeader struct {
+	Name string
+	Size int64
+}
+
+// arReader is a minimal reader for the common ar(1) archive format used by
+// Debian binary packages
+type arReader struct {
+	r         io.Reader
+	remaining int64
+	padding   int64
+}
+
+func newArReader(r io.Reader) (ar *arReader, err error) {
+	magic := make([]byte, len(arMagic))
+	if _, err = io.ReadFull(r, magic); err != nil {
+		err = fmt.Errorf("error reading ar magic: %w", err)
+		return
+	}
+	if string(magic) != arMagic {
+		err = fmt.Errorf("not an ar archive")
+		return
+	}
+	ar = &arReader{r: r}
+	return
+}
+
+// Next advances to the next member of the archive, returning io.EOF when
+// there are no more members. A truncated member, or member header, returns
+// io.ErrUnexpectedEOF
+func (ar *arReader) Next() (hdr *arHeader, err error) {
+	if ar.remaining > 0 {
+		if _, err = io.CopyN(io.Discard, ar.r, ar.remaining); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return
+		}
+	}
+	if ar.padding > 0 {
+		// a missing final padding byte is tolerated as the end of the archive
+		if _, err = io.CopyN(io.Discard, ar.r, ar.padding); err != nil {
+			return
+		}
+	}
+	ar.remaining, ar.padding = 0, 0
+
+	buf := make([]byte, arHeaderSize)
+	if _, err = io.ReadFull(ar.r, buf); err != nil {
+		// io.ReadFull only returns io.EOF when no bytes were read
+		return
+	}
+	if string(buf[58:60]) != "`\n" {
+		err = fmt.Errorf("malformed ar member header")
+		return
+	}
+
+	var size int64
+	if size, err = strconv.ParseInt(strings.TrimSpace(string(buf[48:58])), 10, 64); err != nil {
+		err = fmt.Errorf("malformed ar member size: %w", err)
+		return
+	}
+
+	name := strings.TrimSpace(string(buf[0:16]))
+	// GNU ar terminates member names with a slash
+	name = strings.TrimSuffix(name, "/")
+
+	hdr = &arHeader{Name: name, Size: size}
+	ar.remaining = size
+	ar.padding = size % 2
+	return
+}
+
+// Read reads from the current archive member
+func (ar *arReader) Read(p []byte) (n int, err error) {
+	if ar.remaining <= 0 {
+		return 0, io.EOF
+	}
+	if int64(len(p)) > ar.remaining {
+		p = p[:ar.remaining]
+	}
+	n, err = ar.r.Read(p)
+	ar.remaining -= int64(n)
+	if err == io.EOF && ar.remaining > 0 {
+		err = io.ErrUnexpectedEOF
+	}
+	return
+}
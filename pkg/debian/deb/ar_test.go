@@ -0,0 +1,101 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// readAr returns the names and contents of the members of the ar archive,
+// reading only the first skip bytes of each member, and the error which ended
+// reading
+func readAr(data []byte, skip int) (members []string, err error) {
+	var ar *arReader
+	if ar, err = newArReader(bytes.NewReader(data)); err != nil {
+		return
+	}
+	for {
+		var hdr *arHeader
+		if hdr, err = ar.Next(); err != nil {
+			return
+		}
+		var r io.Reader = ar
+		if skip > 0 {
+			r = io.LimitReader(ar, int64(skip))
+		}
+		var body []byte
+		if body, err = io.ReadAll(r); err != nil {
+			return
+		}
+		members = append(members, fmt.Sprintf("%s:%d:%s", hdr.Name, hdr.Size, body))
+	}
+}
+
+func TestArReader(t *testing.T) {
+	odd := testMember{Name: "odd", Data: []byte("abc")}
+	even := testMember{Name: "even", Data: []byte("abcd")}
+	empty := testMember{Name: "empty"}
+	archive := makeAr(odd, even)
+
+	tests := []struct {
+		name string
+		data []byte
+		skip int
+		want string
+		err  error
+	}{
+		{"members", archive, 0, "[odd:3:abc even:4:abcd]", io.EOF},
+		{"partially read members", archive, 1, "[odd:3:a even:4:a]", io.EOF},
+		{"empty member", makeAr(empty, odd), 0, "[empty:0: odd:3:abc]", io.EOF},
+		{"no members", []byte(arMagic), 0, "[]", io.EOF},
+		{"missing final padding", archive[:len(makeAr(odd))-1], 0, "[odd:3:abc]", io.EOF},
+		{"truncated member header", archive[:len(makeAr(odd))+10], 0, "[odd:3:abc]", io.ErrUnexpectedEOF},
+		{"truncated member", archive[:len(archive)-1], 0, "[odd:3:abc]", io.ErrUnexpectedEOF},
+		{"truncated skipped member", archive[:len(archive)-1], 1, "[odd:3:a even:4:a]", io.ErrUnexpectedEOF},
+		{"truncated padding", archive[:len(makeAr(odd))-2], 0, "[]", io.ErrUnexpectedEOF},
+	}
+	for _, test := range tests {
+		members, err := readAr(test.data, test.skip)
+		if !errors.Is(err, test.err) {
+			t.Errorf("%v: error = %v, want %v", test.name, err, test.err)
+		}
+		if got := fmt.Sprint(members); got != test.want {
+			t.Errorf("%v: members = %v, want %v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestArReaderErrors(t *testing.T) {
+	valid := makeAr(testMember{Name: "member", Data: []byte("data")})
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated magic", []byte("!<ar")},
+		{"wrong magic", []byte("!<arch>X")},
+		{"malformed header", append([]byte(arMagic), []byte(strings.Repeat(" ", arHeaderSize))...)},
+		{"malformed size", bytes.Replace(valid, []byte("4         `"), []byte("x         `"), 1)},
+	}
+	for _, test := range tests {
+		if members, err := readAr(test.data, 0); err == nil || err == io.EOF {
+			t.Errorf("%v: members = %v, want an error", test.name, members)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deb provides a native reader for Debian binary package files
+package deb
+
+import (
+	"archive/tar"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrStopWalk = errors.New("stop walk")
+	// ErrCorruptData is wrapped by the errors returned by Read and ReadWalk
+	// when the data archive is missing or cannot be read to the end, in which
+	// case the Package is returned with the Contents read so far
+	ErrCorruptData = errors.New("corrupt data archive")
+)
+
+// File describes a single member of either the control or data archives
+type File struct {
+	Name     string
+	Linkname string
+	Type     byte
+	Mode     os.FileMode
+	Uname    string
+	Gname    string
+	Uid      int
+	Gid      int
+	Size     int64
+	ModTime  time.Time
+
+	// Data is only populated for control archive members
+	Data []byte
+}
+
+// IsDir returns true if this File is a directory
+func (f *File) IsDir() (ok bool) {
+	ok = f.Mode.IsDir()
+	return
+}
+
+// IsRegular returns true if this File is a regular file
+func (f *File) IsRegular() (ok bool) {
+	ok = f.Mode.IsRegular()
+	return
+}
+
+// IsSymlink returns true if this File is a symbolic link
+func (f *File) IsSymlink() (ok bool) {
+	ok = f.Mode&os.ModeSymlink != 0
+	return
+}
+
+// Package is the parsed representation of a Debian binary package file
+type Package struct {
+	// Path is the filesystem path to the .deb file
+	Path string
+	// Size is the total size of the .deb file
+	Size int64
+	// Format is the contents of the debian-binary member
+	Format string
+	// ControlSize is the size of the control archive member
+	ControlSize int64
+	// ControlFiles are the members of the control archive, with Data
+	ControlFiles []*File
+	// Contents are the members of the data archive, without Data
+	Contents []*File
+}
+
+// WalkDataFn is called for each member of a package's data archive, return
+// ErrStopWalk to stop walking without error
+type WalkDataFn func(hdr *tar.Header, r io.Reader) (err error)
+
+func newFile(hdr *tar.Header) (file *File) {
+	file = &File{
+		Name:     hdr.Name,
+		Linkname: hdr.Linkname,
+		Type:     hdr.Typeflag,
+		Mode:     hdr.FileInfo().Mode(),
+		Uname:    hdr.Uname,
+		Gname:    hdr.Gname,
+		Uid:      hdr.Uid,
+		Gid:      hdr.Gid,
+		Size:     hdr.Size,
+		ModTime:  hdr.ModTime,
+	}
+	return
+}
+
+// Read opens the .deb file at the given path and parses the debian-binary,
+// control and data archive members
+func Read(path string) (pkg *Package, err error) {
+	pkg, err = read(path, true, nil)
+	return
+}
+
+// ReadControl is like Read except that the data archive is not read and the
+// Package.Contents are left empty
+func ReadControl(path string) (pkg *Package, err error) {
+	pkg, err = read(path, false, nil)
+	return
+}
+
+// ReadWalk is like Read and also calls fn for each member of the data
+// archive, in a single pass. When fn returns ErrStopWalk, it is not called
+// again though the Package.Contents are still completed
+func ReadWalk(path string, fn WalkDataFn) (pkg *Package, err error) {
+	pkg, err = read(path, true, fn)
+	return
+}
+
+func read(path string, withContents bool, fn WalkDataFn) (pkg *Package, err error) {
+	var fh *os.File
+	if fh, err = os.Open(path); err != nil {
+		return
+	}
+	defer fh.Close()
+
+	var stat os.FileInfo
+	if stat, err = fh.Stat(); err != nil {
+		return
+	}
+
+	pkg = &Package{
+		Path: path,
+		Size: stat.Size(),
+	}
+
+	var ar *arReader
+	if ar, err = newArReader(fh); err != nil {
+		return
+	}
+
+	var foundControl, foundData bool
+	for {
+		var hdr *arHeader
+		if hdr, err = ar.Next(); err == io.EOF {
+			err = nil
+			break
+		} else if err != nil {
+			err = fmt.Errorf("error reading ar member: %w", err)
+			return
+		}
+
+		switch {
+		case hdr.Name == "debian-binary":
+			var data []byte
+			if data, err = io.ReadAll(ar); err != nil {
+				return
+			}
+			pkg.Format = strings.TrimSpace(string(data))
+
+		case isControlMember(hdr.Name):
+			foundControl = true
+			pkg.ControlSize = hdr.Size
+			if pkg.ControlFiles, err = readControlArchive(hdr.Name, ar); err != nil {
+				err = fmt.Errorf("error reading %v: %w", hdr.Name, err)
+				return
+			}
+
+		case isDataMember(hdr.Name):
+			foundData = true
+			if !withContents {
+				continue
+			}
+			err = walkTarArchive(hdr.Name, ar, func(th *tar.Header, r io.Reader) (err error) {
+				pkg.Contents = append(pkg.Contents, newFile(th))
+				if fn != nil {
+					if err = fn(th, r); errors.Is(err, ErrStopWalk) {
+						fn, err = nil, nil
+					}
+				}
+				return
+			})
+			if err != nil {
+				err = fmt.Errorf("%w: error reading %v: %w", ErrCorruptData, hdr.Name, err)
+				return
+			}
+		}
+	}
+
+	if pkg.Format == "" {
+		err = fmt.Errorf("debian-binary member not found")
+	} else if !foundControl {
+		err = fmt.Errorf("control archive member not found")
+	} else if !foundData {
+		err = fmt.Errorf("%w: data archive member not found", ErrCorruptData)
+	}
+	return
+}
+
+// WalkData opens the .deb file at the given path and calls fn for each member
+// of the data archive
+func WalkData(path string, fn WalkDataFn) (err error) {
+	var fh *os.File
+	if fh, err = os.Open(path); err != nil {
+		return
+	}
+	defer fh.Close()
+
+	var ar *arReader
+	if ar, err = newArReader(fh); err != nil {
+		return
+	}
+
+	for {
+		var hdr *arHeader
+		if hdr, err = ar.Next(); err == io.EOF {
+			err = fmt.Errorf("data archive member not found")
+			return
+		} else if err != nil {
+			return
+		}
+		if isDataMember(hdr.Name) {
+			err = walkTarArchive(hdr.Name, ar, fn)
+			return
+		}
+	}
+}
+
+// ReadDataFile returns the contents of the named member of the data archive,
+// reading at most limit bytes when limit is greater than zero
+func ReadDataFile(path, name string, limit int64) (data []byte, err error) {
+	name = CleanName(name)
+	var found bool
+	err = WalkData(path, func(hdr *tar.Header, r io.Reader) (err error) {
+		if CleanName(hdr.Name) != name {
+			return
+		}
+		found = true
+		if limit > 0 {
+			r = io.LimitReader(r, limit)
+		}
+		if data, err = io.ReadAll(r); err == nil {
+			err = ErrStopWalk
+		}
+		return
+	})
+	if err == nil && !found {
+		err = os.ErrNotExist
+	}
+	return
+}
+
+// CleanName normalizes tar member names, removing any leading "./" or "/"
+func CleanName(name string) (clean string) {
+	clean = strings.TrimPrefix(filepath.Clean("/"+name), "/")
+	return
+}
+
+// ControlFile returns the named control archive member
+func (p *Package) ControlFile(name string) (file *File) {
+	for _, cf := range p.ControlFiles {
+		if CleanName(cf.Name) == name {
+			return cf
+		}
+	}
+	return
+}
+
+// Control returns the contents of the control file
+func (p *Package) Control() (data []byte) {
+	if cf := p.ControlFile("control"); cf != nil {
+		data = cf.Data
+	}
+	return
+}
+
+func readControlArchive(name string, r io.Reader) (files []*File, err error) {
+	err = walkTarArchive(name, r, func(hdr *tar.Header, r io.Reader) (err error) {
+		file := newFile(hdr)
+		if file.IsDir() {
+			return
+		}
+		if file.IsRegular() {
+			if file.Data, err = io.ReadAll(r); err != nil {
+				return
+			}
+		}
+		files = append(files, file)
+		return
+	})
+	sort.Slice(files, func(i, j int) (less bool) {
+		less = CleanName(files[i].Name) < CleanName(files[j].Name)
+		return
+	})
+	return
+}
+
+func walkTarArchive(name string, r io.Reader, fn WalkDataFn) (err error) {
+	var tr *tar.Reader
+	var closer func()
+	if tr, closer, err = newTarReader(name, r); err != nil {
+		return
+	}
+	defer closer()
+
+	for {
+		var hdr *tar.Header
+		if hdr, err = tr.Next(); err == io.EOF {
+			err = nil
+			return
+		} else if err != nil {
+			return
+		}
+		if err = fn(hdr, tr); err != nil {
+			if errors.Is(err, ErrStopWalk) {
+				err = nil
+			}
+			return
+		}
+	}
+}
@@ -0,0 +1,325 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// testEntry is a member of a test tar archive, a regular file unless the
+// Typeflag is set
+type testEntry struct {
+	Name     string
+	Body     string
+	Linkname string
+	Typeflag byte
+}
+
+var (
+	testModTime = time.Date(2023, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	testControl = "Package: hello\nVersion: 1.0-1\nArchitecture: all\nMaintainer: Jane Doe <jane@example.org>\nDescription: greets\n the world\n"
+
+	testControlEntries = []testEntry{
+		{Name: "./", Typeflag: tar.TypeDir},
+		{Name: "./control", Body: testControl},
+		{Name: "./md5sums", Body: "b1946ac92492d2347c6235b4d2611184  usr/share/hello/hello.txt\n"},
+		{Name: "./postinst", Body: "#!/bin/sh\nset -e\n"},
+	}
+
+	testDataEntries = []testEntry{
+		{Name: "./", Typeflag: tar.TypeDir},
+		{Name: "./usr/", Typeflag: tar.TypeDir},
+		{Name: "./usr/share/", Typeflag: tar.TypeDir},
+		{Name: "./usr/share/hello/", Typeflag: tar.TypeDir},
+		{Name: "./usr/share/hello/hello.txt", Body: "hello\n"},
+		{Name: "./usr/share/hello/again.txt", Linkname: "./usr/share/hello/hello.txt", Typeflag: tar.TypeLink},
+		{Name: "./usr/share/hello/link.txt", Linkname: "hello.txt", Typeflag: tar.TypeSymlink},
+	}
+)
+
+// makeTar returns the tar archive of the given entries
+func makeTar(t *testing.T, entries []testEntry) (data []byte) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, entry := range entries {
+		hdr := &tar.Header{
+			Name:     entry.Name,
+			Linkname: entry.Linkname,
+			Typeflag: entry.Typeflag,
+			Mode:     0644,
+			Uname:    "root",
+			Gname:    "root",
+			ModTime:  testModTime,
+		}
+		switch entry.Typeflag {
+		case tar.TypeDir:
+			hdr.Mode = 0755
+		case tar.TypeReg, 0:
+			hdr.Typeflag = tar.TypeReg
+			hdr.Size = int64(len(entry.Body))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatalf("error writing tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(entry.Body)); err != nil {
+			t.Fatalf("error writing tar body: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("error closing tar: %v", err)
+	}
+	data = buf.Bytes()
+	return
+}
+
+// compress returns the data compressed for the extension of the given name
+func compress(t *testing.T, name string, data []byte) (compressed []byte) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	var err error
+	switch filepath.Ext(name) {
+	case ".tar":
+		return data
+	case ".gz":
+		w = gzip.NewWriter(&buf)
+	case ".xz":
+		w, err = xz.NewWriter(&buf)
+	case ".lzma":
+		w, err = lzma.NewWriter(&buf)
+	case ".zst":
+		w, err = zstd.NewWriter(&buf)
+	default:
+		t.Fatalf("unsupported test compression: %v", name)
+	}
+	if err != nil {
+		t.Fatalf("error compressing %v: %v", name, err)
+	}
+	if _, err = w.Write(data); err != nil {
+		t.Fatalf("error compressing %v: %v", name, err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("error compressing %v: %v", name, err)
+	}
+	compressed = buf.Bytes()
+	return
+}
+
+// testMember is a member of a test ar archive
+type testMember struct {
+	Name string
+	Data []byte
+}
+
+// makeAr returns the ar archive of the given members, with GNU style member
+// names terminated by a slash
+func makeAr(members ...testMember) (data []byte) {
+	var buf bytes.Buffer
+	buf.WriteString(arMagic)
+	for _, member := range members {
+		_, _ = fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8s%-10d`\n", member.Name+"/", testModTime.Unix(), 0, 0, "100644", len(member.Data))
+		buf.Write(member.Data)
+		if len(member.Data)%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	data = buf.Bytes()
+	return
+}
+
+// makeDeb returns a package with the test control and data entries, using
+// the given control and data archive member names
+func makeDeb(t *testing.T, control, data string) (deb []byte) {
+	deb = makeAr(
+		testMember{Name: "debian-binary", Data: []byte("2.0\n")},
+		testMember{Name: control, Data: compress(t, control, makeTar(t, testControlEntries))},
+		testMember{Name: data, Data: compress(t, data, makeTar(t, testDataEntries))},
+	)
+	return
+}
+
+// writeDeb writes the package data to a temporary file, returning the path
+func writeDeb(t *testing.T, data []byte) (path string) {
+	path = filepath.Join(t.TempDir(), "hello_1.0-1_all.deb")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("error writing test package: %v", err)
+	}
+	return
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		control, data string
+	}{
+		{"control.tar", "data.tar"},
+		{"control.tar.gz", "data.tar.gz"},
+		{"control.tar.xz", "data.tar.xz"},
+		{"control.tar.zst", "data.tar.zst"},
+		{"control.tar.gz", "data.tar.lzma"},
+		{"control.tar.xz", "data.tar.zst"},
+	}
+	for _, test := range tests {
+		path := writeDeb(t, makeDeb(t, test.control, test.data))
+		pkg, err := Read(path)
+		if err != nil {
+			t.Errorf("Read(%v, %v) unexpected error: %v", test.control, test.data, err)
+			continue
+		}
+		if pkg.Format != "2.0" {
+			t.Errorf("Read(%v, %v) Format = %q, want %q", test.control, test.data, pkg.Format, "2.0")
+		}
+		if got := string(pkg.Control()); got != testControl {
+			t.Errorf("Read(%v, %v) Control = %q, want %q", test.control, test.data, got, testControl)
+		}
+		var names []string
+		for _, file := range pkg.ControlFiles {
+			names = append(names, CleanName(file.Name))
+		}
+		if got, want := fmt.Sprint(names), "[control md5sums postinst]"; got != want {
+			t.Errorf("Read(%v, %v) ControlFiles = %v, want %v", test.control, test.data, got, want)
+		}
+		if len(pkg.Contents) != len(testDataEntries) {
+			t.Errorf("Read(%v, %v) found %d Contents, want %d", test.control, test.data, len(pkg.Contents), len(testDataEntries))
+			continue
+		}
+		for idx, file := range pkg.Contents {
+			if file.Name != testDataEntries[idx].Name || file.Linkname != testDataEntries[idx].Linkname {
+				t.Errorf("Read(%v, %v) Contents[%d] = %q -> %q, want %q -> %q", test.control, test.data, idx,
+					file.Name, file.Linkname, testDataEntries[idx].Name, testDataEntries[idx].Linkname)
+			}
+		}
+		if file := pkg.Contents[5]; file.Type != tar.TypeLink || file.IsSymlink() {
+			t.Errorf("Read(%v, %v) hardlink Type = %q, IsSymlink = %v", test.control, test.data, file.Type, file.IsSymlink())
+		}
+		if file := pkg.Contents[6]; !file.IsSymlink() {
+			t.Errorf("Read(%v, %v) symlink IsSymlink = false", test.control, test.data)
+		}
+	}
+}
+
+func TestReadControl(t *testing.T) {
+	path := writeDeb(t, makeDeb(t, "control.tar.xz", "data.tar.xz"))
+	pkg, err := ReadControl(path)
+	if err != nil {
+		t.Fatalf("ReadControl unexpected error: %v", err)
+	}
+	if len(pkg.Contents) != 0 {
+		t.Errorf("ReadControl found %d Contents, want none", len(pkg.Contents))
+	}
+	if file := pkg.ControlFile("postinst"); file == nil || string(file.Data) != "#!/bin/sh\nset -e\n" {
+		t.Errorf("ReadControl postinst = %+v", file)
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	control := testMember{Name: "control.tar.gz", Data: compress(t, "control.tar.gz", makeTar(t, testControlEntries))}
+	data := testMember{Name: "data.tar.xz", Data: compress(t, "data.tar.xz", makeTar(t, testDataEntries))}
+	binary := testMember{Name: "debian-binary", Data: []byte("2.0\n")}
+	complete := makeAr(binary, control, data)
+
+	// errAny matches any ReadControl error
+	errAny := errors.New("any error")
+	tests := []struct {
+		name    string
+		data    []byte
+		corrupt bool
+		// control is the ReadControl error, nil when it must succeed
+		control error
+	}{
+		{"truncated data member", complete[:len(complete)-100], true, io.ErrUnexpectedEOF},
+		{"truncated member header", complete[:len(makeAr(binary, control))+30], false, io.ErrUnexpectedEOF},
+		{"missing data member", makeAr(binary, control), true, ErrCorruptData},
+		{"missing control member", makeAr(binary, data), false, errAny},
+		{"missing debian-binary", makeAr(control, data), false, errAny},
+		{"corrupt data member", makeAr(binary, control, testMember{Name: "data.tar.xz", Data: []byte("not xz")}), true, nil},
+	}
+	for _, test := range tests {
+		path := writeDeb(t, test.data)
+		if _, err := Read(path); err == nil {
+			t.Errorf("Read(%v) expected an error", test.name)
+		} else if got := errors.Is(err, ErrCorruptData); got != test.corrupt {
+			t.Errorf("Read(%v) error %q is ErrCorruptData = %v, want %v", test.name, err, got, test.corrupt)
+		}
+		_, err := ReadControl(path)
+		switch {
+		case test.control == nil && err != nil:
+			t.Errorf("ReadControl(%v) unexpected error: %v", test.name, err)
+		case test.control == errAny && err == nil:
+			t.Errorf("ReadControl(%v) expected an error", test.name)
+		case test.control != nil && test.control != errAny && !errors.Is(err, test.control):
+			t.Errorf("ReadControl(%v) error = %v, want %v", test.name, err, test.control)
+		}
+	}
+
+	if _, err := Read(writeDeb(t, []byte("!<arch>"))); err == nil {
+		t.Errorf("Read(truncated magic) expected an error")
+	}
+	if _, err := Read(writeDeb(t, []byte("not an archive\n"))); err == nil {
+		t.Errorf("Read(not an archive) expected an error")
+	}
+}
+
+func TestReadDataFile(t *testing.T) {
+	path := writeDeb(t, makeDeb(t, "control.tar.gz", "data.tar.zst"))
+	tests := []struct {
+		name  string
+		limit int64
+		want  string
+		err   error
+	}{
+		{"usr/share/hello/hello.txt", 0, "hello\n", nil},
+		{"./usr/share/hello/hello.txt", 0, "hello\n", nil},
+		{"/usr/share/hello/hello.txt", 3, "hel", nil},
+		{"usr/share/hello/missing.txt", 0, "", os.ErrNotExist},
+	}
+	for _, test := range tests {
+		data, err := ReadDataFile(path, test.name, test.limit)
+		if !errors.Is(err, test.err) {
+			t.Errorf("ReadDataFile(%q) error = %v, want %v", test.name, err, test.err)
+		} else if string(data) != test.want {
+			t.Errorf("ReadDataFile(%q) = %q, want %q", test.name, data, test.want)
+		}
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	tests := []struct {
+		input, want string
+	}{
+		{"./usr/bin/hello", "usr/bin/hello"},
+		{"/usr/bin/hello", "usr/bin/hello"},
+		{"usr//bin/../bin/hello", "usr/bin/hello"},
+		{"./", ""},
+		{"../../etc/passwd", "etc/passwd"},
+	}
+	for _, test := range tests {
+		if got := CleanName(test.input); got != test.want {
+			t.Errorf("CleanName(%q) = %q, want %q", test.input, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var rxContentsLine = regexp.MustCompile(`^([-dlhcbp][-rwxsStT]{9})\s+([^/\s]+)/(\S+)\s+(\d+)\s+(\d{4}-\d{2}-\d{2} \d{2}:\d{2})\s(.+)$`)
+
+// FormatInfo returns the package details in the same format as the output of
+// `dpkg-deb --info`
+func (p *Package) FormatInfo() (output string) {
+	var buf strings.Builder
+	buf.WriteString(fmt.Sprintf(" new Debian package, version %s.\n", p.Format))
+	buf.WriteString(fmt.Sprintf(" size %d bytes: control archive=%d bytes.\n", p.Size, p.ControlSize))
+	for _, cf := range p.ControlFiles {
+		if !cf.IsRegular() {
+			continue
+		}
+		var exec rune = ' '
+		if cf.Mode&0111 != 0 {
+			exec = '*'
+		}
+		var interpreter string
+		if bytes.HasPrefix(cf.Data, []byte("#!")) {
+			interpreter, _, _ = strings.Cut(string(cf.Data), "\n")
+		}
+		buf.WriteString(fmt.Sprintf(
+			" %7d bytes, %5d lines   %c  %-20s %s\n",
+			cf.Size, bytes.Count(cf.Data, []byte("\n")), exec, CleanName(cf.Name), interpreter,
+		))
+	}
+	for _, line := range strings.SplitAfter(string(p.Control()), "\n") {
+		if line != "" {
+			buf.WriteString(" " + line)
+		}
+	}
+	output = buf.String()
+	return
+}
+
+// FormatContents returns the data archive listing in the same format as the
+// output of `dpkg-deb --contents`
+func (p *Package) FormatContents() (output string) {
+	var buf strings.Builder
+	for _, file := range p.Contents {
+		buf.WriteString(file.String())
+		buf.WriteString("\n")
+	}
+	output = buf.String()
+	return
+}
+
+// ParseContents parses a data archive listing in the format returned by
+// FormatContents and `dpkg-deb --contents`. Modification times are in the
+// local timezone, at minute precision
+func ParseContents(output string) (files []*File, err error) {
+	for idx, line := range strings.Split(output, "\n") {
+		if line = strings.TrimRight(line, "\r"); line == "" {
+			continue
+		}
+		m := rxContentsLine.FindStringSubmatch(line)
+		if m == nil {
+			err = fmt.Errorf("line %d: malformed contents line: %q", idx+1, line)
+			return
+		}
+		file := &File{Uname: m[2], Gname: m[3]}
+		file.Type, file.Mode = ParseModeString(m[1])
+		file.Size, _ = strconv.ParseInt(m[4], 10, 64)
+		if file.ModTime, err = time.ParseInLocation("2006-01-02 15:04", m[5], time.Local); err != nil {
+			err = fmt.Errorf("line %d: %v", idx+1, err)
+			return
+		}
+		file.Name = m[6]
+		switch file.Type {
+		case tar.TypeSymlink:
+			file.Name, file.Linkname, _ = strings.Cut(file.Name, " -> ")
+		case tar.TypeLink:
+			file.Name, file.Linkname, _ = strings.Cut(file.Name, " link to ")
+		}
+		files = append(files, file)
+	}
+	return
+}
+
+// String returns the tar(1) verbose listing line for this File
+func (f *File) String() (line string) {
+	line = fmt.Sprintf(
+		"%s %s/%s %9d %s %s",
+		ModeString(f.Type, f.Mode), f.Uname, f.Gname, f.Size,
+		f.ModTime.Local().Format("2006-01-02 15:04"),
+		f.Name,
+	)
+	switch f.Type {
+	case tar.TypeSymlink:
+		line += " -> " + f.Linkname
+	case tar.TypeLink:
+		line += " link to " + f.Linkname
+	}
+	return
+}
+
+// ModeString returns the tar(1) style permissions string for the given tar
+// type flag and file mode
+func ModeString(typeflag byte, mode os.FileMode) (perms string) {
+	var kind byte
+	switch typeflag {
+	case tar.TypeDir:
+		kind = 'd'
+	case tar.TypeSymlink:
+		kind = 'l'
+	case tar.TypeLink:
+		kind = 'h'
+	case tar.TypeChar:
+		kind = 'c'
+	case tar.TypeBlock:
+		kind = 'b'
+	case tar.TypeFifo:
+		kind = 'p'
+	default:
+		kind = '-'
+	}
+
+	buf := []byte{kind, '-', '-', '-', '-', '-', '-', '-', '-', '-'}
+	const rwx = "rwxrwxrwx"
+	for i := 0; i < 9; i++ {
+		if mode&(1<<uint(8-i)) != 0 {
+			buf[i+1] = rwx[i]
+		}
+	}
+
+	special := func(idx int, set bool, lower, upper byte) {
+		if set {
+			if buf[idx] == 'x' {
+				buf[idx] = lower
+			} else {
+				buf[idx] = upper
+			}
+		}
+	}
+	special(3, mode&os.ModeSetuid != 0, 's', 'S')
+	special(6, mode&os.ModeSetgid != 0, 's', 'S')
+	special(9, mode&os.ModeSticky != 0, 't', 'T')
+
+	perms = string(buf)
+	return
+}
+
+// ParseModeString is the inverse of ModeString
+func ParseModeString(perms string) (typeflag byte, mode os.FileMode) {
+	if len(perms) != 10 {
+		return
+	}
+	switch perms[0] {
+	case 'd':
+		typeflag, mode = tar.TypeDir, os.ModeDir
+	case 'l':
+		typeflag, mode = tar.TypeSymlink, os.ModeSymlink
+	case 'h':
+		typeflag = tar.TypeLink
+	case 'c':
+		typeflag, mode = tar.TypeChar, os.ModeDevice|os.ModeCharDevice
+	case 'b':
+		typeflag, mode = tar.TypeBlock, os.ModeDevice
+	case 'p':
+		typeflag, mode = tar.TypeFifo, os.ModeNamedPipe
+	default:
+		typeflag = tar.TypeReg
+	}
+	for i := 0; i < 9; i++ {
+		if c := perms[i+1]; c != '-' && c != 'S' && c != 'T' {
+			mode |= 1 << uint(8-i)
+		}
+	}
+	switch perms[3] {
+	case 's', 'S':
+		mode |= os.ModeSetuid
+	}
+	switch perms[6] {
+	case 's', 'S':
+		mode |= os.ModeSetgid
+	}
+	switch perms[9] {
+	case 't', 'T':
+		mode |= os.ModeSticky
+	}
+	return
+}
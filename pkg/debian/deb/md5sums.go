@@ -0,0 +1,120 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+var rxMd5sumsLine = regexp.MustCompile(`^([0-9a-fA-F]{32})\s+\*?(.+)$`)
+
+// ParseMd5sums parses the md5sums control file, returning the lowercase hex
+// digests keyed by cleaned file name
+func ParseMd5sums(data []byte) (sums map[string]string, err error) {
+	sums = make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		m := rxMd5sumsLine.FindStringSubmatch(line)
+		if m == nil {
+			err = fmt.Errorf("md5sums line %d: invalid format: %q", number, line)
+			return
+		}
+		sums[CleanName(m[2])] = strings.ToLower(m[1])
+	}
+	err = scanner.Err()
+	return
+}
+
+// DataHasher computes the md5 digests of the regular files within a data
+// archive while it is walked
+type DataHasher struct {
+	// Sums are the lowercase hex digests keyed by cleaned file name
+	Sums map[string]string
+}
+
+func NewDataHasher() (h *DataHasher) {
+	h = &DataHasher{Sums: make(map[string]string)}
+	return
+}
+
+// Wrap returns a WalkDataFn which hashes each regular file, and records each
+// hardlink with the digest of its target, while also passing it to fn, which
+// may be nil. Any content fn does not read is still hashed and when fn returns
+// ErrStopWalk, it is not called again though hashing continues
+func (h *DataHasher) Wrap(fn WalkDataFn) WalkDataFn {
+	call := func(hdr *tar.Header, r io.Reader) (err error) {
+		if fn != nil {
+			if err = fn(hdr, r); errors.Is(err, ErrStopWalk) {
+				fn, err = nil, nil
+			}
+		}
+		return
+	}
+	return func(hdr *tar.Header, r io.Reader) (err error) {
+		if hdr.Typeflag == tar.TypeLink {
+			// hardlinks share the content of an earlier entry in the archive
+			if sum, present := h.Sums[CleanName(hdr.Linkname)]; present {
+				h.Sums[CleanName(hdr.Name)] = sum
+			}
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			err = call(hdr, r)
+			return
+		}
+		hash := md5.New()
+		tee := io.TeeReader(r, hash)
+		if err = call(hdr, tee); err != nil {
+			return
+		}
+		if _, err = io.Copy(io.Discard, tee); err != nil {
+			return
+		}
+		h.Sums[CleanName(hdr.Name)] = hex.EncodeToString(hash.Sum(nil))
+		return
+	}
+}
+
+// Verify compares the hashed files with the expected md5sums, returning a
+// description of each file which is missing or has a different digest. Files
+// not listed in md5sums, such as conffiles, are not checked
+func (h *DataHasher) Verify(expected map[string]string) (problems []string) {
+	names := make([]string, 0, len(expected))
+	for name := range expected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if actual, present := h.Sums[name]; !present {
+			problems = append(problems, fmt.Sprintf("%s: listed in md5sums but not found in the data archive", name))
+		} else if actual != expected[name] {
+			problems = append(problems, fmt.Sprintf("%s: md5sum mismatch, expected %s got %s", name, expected[name], actual))
+		}
+	}
+	return
+}
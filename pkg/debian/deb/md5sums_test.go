@@ -0,0 +1,132 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"archive/tar"
+	"fmt"
+	"io"
+	"testing"
+)
+
+const (
+	// md5 digests of "hello\n" and "world\n"
+	testHelloSum = "b1946ac92492d2347c6235b4d2611184"
+	testWorldSum = "591785b794601e212b260e25925636fd"
+)
+
+func TestParseMd5sums(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", "map[]"},
+		{testHelloSum + "  usr/share/hello.txt\n", "map[usr/share/hello.txt:" + testHelloSum + "]"},
+		{"B1946AC92492D2347C6235B4D2611184 *./usr/share/hello.txt\r\n\n", "map[usr/share/hello.txt:" + testHelloSum + "]"},
+		{testHelloSum + "  usr/share/with space.txt\n", "map[usr/share/with space.txt:" + testHelloSum + "]"},
+	}
+	for _, test := range tests {
+		sums, err := ParseMd5sums([]byte(test.input))
+		if err != nil {
+			t.Errorf("ParseMd5sums(%q) unexpected error: %v", test.input, err)
+		} else if got := fmt.Sprint(sums); got != test.want {
+			t.Errorf("ParseMd5sums(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+
+	for _, input := range []string{"not a digest  usr/file\n", testHelloSum + "\n", "b1946ac9  usr/file\n"} {
+		if sums, err := ParseMd5sums([]byte(input)); err == nil {
+			t.Errorf("ParseMd5sums(%q) = %v, want error", input, sums)
+		}
+	}
+}
+
+func TestDataHasher(t *testing.T) {
+	entries := []testEntry{
+		{Name: "./", Typeflag: tar.TypeDir},
+		{Name: "./usr/share/hello.txt", Body: "hello\n"},
+		{Name: "./usr/share/world.txt", Body: "world\n"},
+		{Name: "./usr/share/again.txt", Linkname: "./usr/share/hello.txt", Typeflag: tar.TypeLink},
+		{Name: "./usr/share/link.txt", Linkname: "hello.txt", Typeflag: tar.TypeSymlink},
+	}
+	path := writeDeb(t, makeAr(
+		testMember{Name: "debian-binary", Data: []byte("2.0\n")},
+		testMember{Name: "control.tar.gz", Data: compress(t, "control.tar.gz", makeTar(t, testControlEntries))},
+		testMember{Name: "data.tar.xz", Data: compress(t, "data.tar.xz", makeTar(t, entries))},
+	))
+
+	tests := []struct {
+		name     string
+		expected map[string]string
+		problems int
+	}{
+		{"all listed", map[string]string{
+			"usr/share/hello.txt": testHelloSum,
+			"usr/share/world.txt": testWorldSum,
+			"usr/share/again.txt": testHelloSum,
+		}, 0},
+		{"unlisted files are not checked", map[string]string{"usr/share/world.txt": testWorldSum}, 0},
+		{"hardlink mismatch", map[string]string{"usr/share/again.txt": testWorldSum}, 1},
+		{"missing and mismatched", map[string]string{
+			"usr/share/missing.txt": testHelloSum,
+			"usr/share/hello.txt":   testWorldSum,
+		}, 2},
+		{"symlinks are not hashed", map[string]string{"usr/share/link.txt": testHelloSum}, 1},
+	}
+
+	// the walk func reads only part of each file, the rest is still hashed
+	var walked []string
+	hasher := NewDataHasher()
+	if err := WalkData(path, hasher.Wrap(func(hdr *tar.Header, r io.Reader) (err error) {
+		walked = append(walked, hdr.Name)
+		_, err = io.ReadFull(r, make([]byte, 1))
+		if hdr.Typeflag != tar.TypeReg {
+			err = nil
+		}
+		return
+	})); err != nil {
+		t.Fatalf("WalkData unexpected error: %v", err)
+	}
+	if len(walked) != len(entries) {
+		t.Errorf("DataHasher.Wrap walked %v, want all %d entries", walked, len(entries))
+	}
+	for _, test := range tests {
+		if problems := hasher.Verify(test.expected); len(problems) != test.problems {
+			t.Errorf("%v: Verify = %v, want %d problems", test.name, problems, test.problems)
+		}
+	}
+}
+
+func TestDataHasherStopWalk(t *testing.T) {
+	path := writeDeb(t, makeDeb(t, "control.tar.gz", "data.tar.gz"))
+	var calls int
+	hasher := NewDataHasher()
+	if err := WalkData(path, hasher.Wrap(func(hdr *tar.Header, r io.Reader) (err error) {
+		calls += 1
+		err = ErrStopWalk
+		return
+	})); err != nil {
+		t.Fatalf("WalkData unexpected error: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("DataHasher.Wrap called fn %d times after ErrStopWalk, want 1", calls)
+	}
+	if problems := hasher.Verify(map[string]string{
+		"usr/share/hello/hello.txt": testHelloSum,
+		"usr/share/hello/again.txt": testHelloSum,
+	}); len(problems) > 0 {
+		t.Errorf("DataHasher.Verify after ErrStopWalk = %v", problems)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"archive/tar"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+	"github.com/ulikunitz/xz/lzma"
+)
+
+// newTarReader wraps the given ar member reader with the decompressor
+// indicated by the member's name, returning a tar.Reader and a close func
+// which must be called when done reading
+func newTarReader(name string, r io.Reader) (tr *tar.Reader, closer func(), err error) {
+	closer = func() {}
+	var src io.Reader
+	switch ext := filepath.Ext(name); ext {
+	case ".tar":
+		src = r
+	case ".gz":
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(r); err != nil {
+			return
+		}
+		closer = func() { _ = gr.Close() }
+		src = gr
+	case ".xz":
+		if src, err = xz.NewReader(r); err != nil {
+			return
+		}
+	case ".lzma":
+		if src, err = lzma.NewReader(r); err != nil {
+			return
+		}
+	case ".zst":
+		var zr *zstd.Decoder
+		if zr, err = zstd.NewReader(r); err != nil {
+			return
+		}
+		closer = zr.Close
+		src = zr
+	case ".bz2":
+		src = bzip2.NewReader(r)
+	default:
+		err = fmt.Errorf("unsupported archive compression: %v", name)
+		return
+	}
+	tr = tar.NewReader(src)
+	return
+}
+
+func isControlMember(name string) (ok bool) {
+	ok = name == "control.tar" || strings.HasPrefix(name, "control.tar.")
+	return
+}
+
+func isDataMember(name string) (ok bool) {
+	ok = name == "data.tar" || strings.HasPrefix(name, "data.tar.")
+	return
+}
@@ -0,0 +1,109 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"testing"
+)
+
+func TestWalkTarArchive(t *testing.T) {
+	archive := makeTar(t, testDataEntries)
+	for _, name := range []string{"data.tar", "data.tar.gz", "data.tar.xz", "data.tar.lzma", "data.tar.zst"} {
+		var names []string
+		var body string
+		err := walkTarArchive(name, bytes.NewReader(compress(t, name, archive)), func(hdr *tar.Header, r io.Reader) (err error) {
+			names = append(names, hdr.Name)
+			if hdr.Typeflag == tar.TypeReg {
+				var data []byte
+				data, err = io.ReadAll(r)
+				body += string(data)
+			}
+			return
+		})
+		if err != nil {
+			t.Errorf("walkTarArchive(%v) unexpected error: %v", name, err)
+			continue
+		}
+		if len(names) != len(testDataEntries) || body != "hello\n" {
+			t.Errorf("walkTarArchive(%v) = %v with body %q", name, names, body)
+		}
+	}
+}
+
+func TestWalkTarArchiveErrors(t *testing.T) {
+	archive := makeTar(t, testDataEntries)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"data.tar.bz3", archive},
+		{"data.tar.gz", []byte("not gzip")},
+		{"data.tar.xz", []byte("not xz")},
+		{"data.tar.zst", []byte("not zstd")},
+		{"data.tar.gz", compress(t, "data.tar.gz", archive)[:100]},
+		{"data.tar.xz", compress(t, "data.tar.xz", archive)[:100]},
+		{"data.tar.zst", compress(t, "data.tar.zst", archive)[:100]},
+		{"data.tar", archive[:700]},
+	}
+	for _, test := range tests {
+		err := walkTarArchive(test.name, bytes.NewReader(test.data), func(hdr *tar.Header, r io.Reader) (err error) {
+			_, err = io.Copy(io.Discard, r)
+			return
+		})
+		if err == nil {
+			t.Errorf("walkTarArchive(%v, %d bytes) expected an error", test.name, len(test.data))
+		}
+	}
+}
+
+func TestWalkTarArchiveStop(t *testing.T) {
+	var names []string
+	err := walkTarArchive("data.tar", bytes.NewReader(makeTar(t, testDataEntries)), func(hdr *tar.Header, r io.Reader) (err error) {
+		names = append(names, hdr.Name)
+		if len(names) == 2 {
+			err = ErrStopWalk
+		}
+		return
+	})
+	if err != nil || len(names) != 2 {
+		t.Errorf("walkTarArchive with ErrStopWalk = %v, %v", names, err)
+	}
+}
+
+func TestArchiveMembers(t *testing.T) {
+	tests := []struct {
+		name          string
+		control, data bool
+	}{
+		{"control.tar", true, false},
+		{"control.tar.zst", true, false},
+		{"data.tar", false, true},
+		{"data.tar.xz", false, true},
+		{"control", false, false},
+		{"data.tarball", false, false},
+		{"debian-binary", false, false},
+	}
+	for _, test := range tests {
+		if got := isControlMember(test.name); got != test.control {
+			t.Errorf("isControlMember(%q) = %v, want %v", test.name, got, test.control)
+		}
+		if got := isDataMember(test.name); got != test.data {
+			t.Errorf("isDataMember(%q) = %v, want %v", test.name, got, test.data)
+		}
+	}
+}
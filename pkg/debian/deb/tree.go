@@ -0,0 +1,134 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deb
+
+import (
+	"sort"
+	"strings"
+)
+
+// Node is a single entry of a contents tree
+type Node struct {
+	// Name is the base name of the entry, empty for the root
+	Name string
+	// Path is the cleaned path of the entry, empty for the root
+	Path string
+	// File is the archive member, nil for the root and any directories which
+	// are implied by the paths of their children
+	File *File
+	// Children are the directory entries, directories first and then by name
+	Children []*Node
+	// Size is the file size or, for directories, the total size of all the
+	// regular files within
+	Size int64
+	// Count is the number of non-directory entries within a directory
+	Count int
+}
+
+// IsDir returns true if this Node is the root or a directory
+func (n *Node) IsDir() (ok bool) {
+	ok = n.File == nil || n.File.IsDir()
+	return
+}
+
+// Walk calls fn for each node below this one in depth-first order, with the
+// depth of the node relative to this one starting at zero
+func (n *Node) Walk(fn func(node *Node, depth int)) {
+	var walk func(node *Node, depth int)
+	walk = func(node *Node, depth int) {
+		for _, child := range node.Children {
+			fn(child, depth)
+			walk(child, depth+1)
+		}
+	}
+	walk(n, 0)
+}
+
+// Dirs returns the number of directories below this Node
+func (n *Node) Dirs() (count int) {
+	n.Walk(func(node *Node, _ int) {
+		if node.IsDir() {
+			count += 1
+		}
+	})
+	return
+}
+
+// NewTree returns the root Node of the directory tree of the given files
+func NewTree(files []*File) (root *Node) {
+	root = &Node{}
+	dirs := map[string]*Node{"": root}
+
+	var lookup func(path string) (dir *Node)
+	lookup = func(path string) (dir *Node) {
+		if dir = dirs[path]; dir != nil {
+			return
+		}
+		parent, name := "", path
+		if idx := strings.LastIndex(path, "/"); idx >= 0 {
+			parent, name = path[:idx], path[idx+1:]
+		}
+		dir = &Node{Name: name, Path: path}
+		dirs[path] = dir
+		p := lookup(parent)
+		p.Children = append(p.Children, dir)
+		return
+	}
+
+	for _, file := range files {
+		path := CleanName(file.Name)
+		if path == "" || path == "." {
+			continue
+		}
+		if file.IsDir() {
+			lookup(path).File = file
+			continue
+		}
+		parent, name := "", path
+		if idx := strings.LastIndex(path, "/"); idx >= 0 {
+			parent, name = path[:idx], path[idx+1:]
+		}
+		var size int64
+		if file.IsRegular() {
+			size = file.Size
+		}
+		dir := lookup(parent)
+		dir.Children = append(dir.Children, &Node{Name: name, Path: path, File: file, Size: size})
+	}
+
+	var total func(node *Node)
+	total = func(node *Node) {
+		for _, child := range node.Children {
+			if child.IsDir() {
+				total(child)
+				node.Count += child.Count
+			} else {
+				node.Count += 1
+			}
+			node.Size += child.Size
+		}
+		sort.SliceStable(node.Children, func(i, j int) (less bool) {
+			a, b := node.Children[i], node.Children[j]
+			if a.IsDir() != b.IsDir() {
+				less = a.IsDir()
+				return
+			}
+			less = a.Name < b.Name
+			return
+		})
+	}
+	total(root)
+	return
+}
@@ -0,0 +1,119 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dsc reads Debian source control (.dsc) files and verifies their
+// OpenPGP signatures
+package dsc
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+// File is a file of the source package listed in the .dsc
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	MD5    string `json:"md5,omitempty"`
+	SHA1   string `json:"sha1,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// Source is a parsed .dsc file
+type Source struct {
+	// Control is the .dsc paragraph, without any signature
+	Control *control.Paragraph
+	// Files are the files listed by the Files and Checksums-* fields, in
+	// Files order
+	Files []*File
+	// Signed is true if the .dsc is OpenPGP clearsigned
+	Signed bool
+}
+
+// Parse returns the Source described by the .dsc file data
+func Parse(data []byte) (src *Source, err error) {
+	var paragraphs []*control.Paragraph
+	if paragraphs, err = control.ParseString(string(data)); err != nil {
+		return
+	} else if len(paragraphs) == 0 {
+		err = fmt.Errorf("no paragraphs found")
+		return
+	}
+	src = &Source{
+		Control: paragraphs[0],
+		Signed:  strings.HasPrefix(string(data), "-----BEGIN PGP SIGNED MESSAGE-----"),
+	}
+	if src.Name() == "" || src.Version() == "" {
+		err = fmt.Errorf("missing Source or Version field")
+		return
+	}
+
+	lookup := make(map[string]*File)
+	for _, field := range []string{"Files", "Checksums-Sha1", "Checksums-Sha256"} {
+		for _, line := range src.Control.Lines(field) {
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			size, ee := strconv.ParseInt(fields[1], 10, 64)
+			if ee != nil {
+				err = fmt.Errorf("error parsing %v size: %q - %w", field, line, ee)
+				return
+			}
+			file, present := lookup[fields[2]]
+			if !present {
+				file = &File{Name: fields[2], Size: size}
+				lookup[file.Name] = file
+				src.Files = append(src.Files, file)
+			} else if file.Size != size {
+				err = fmt.Errorf("%v size differs for %v: %d != %d", field, file.Name, size, file.Size)
+				return
+			}
+			switch field {
+			case "Files":
+				file.MD5 = fields[0]
+			case "Checksums-Sha1":
+				file.SHA1 = fields[0]
+			case "Checksums-Sha256":
+				file.SHA256 = fields[0]
+			}
+		}
+	}
+	return
+}
+
+// Name returns the source package name
+func (src *Source) Name() (name string) {
+	name = src.Control.Value("Source")
+	return
+}
+
+// Version returns the source package version
+func (src *Source) Version() (version string) {
+	version = src.Control.Value("Version")
+	return
+}
+
+// Binaries returns the names of the binary packages built from the source
+func (src *Source) Binaries() (names []string) {
+	for _, name := range strings.Split(src.Control.Folded("Binary"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return
+}
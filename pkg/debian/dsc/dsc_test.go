@@ -0,0 +1,208 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dsc
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+)
+
+const testDsc = `Format: 3.0 (quilt)
+Source: hello
+Binary: hello, hello-doc,
+ hello-dbg
+Version: 2.10-3
+Maintainer: Jane Doe <jane@example.org>
+Checksums-Sha256:
+ 9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08 100 hello_2.10.orig.tar.gz
+ 60303ae22b998861bce3b28f33eec1be758a213c86c93c076dbe9f558c11c752 20 hello_2.10-3.debian.tar.xz
+Files:
+ d41d8cd98f00b204e9800998ecf8427e 100 hello_2.10.orig.tar.gz
+ 0cc175b9c0f1b6a831c399e269772661 20 hello_2.10-3.debian.tar.xz
+`
+
+// testEntity returns a new signing key with the given user id
+func testEntity(t *testing.T, name, email string) (entity *openpgp.Entity) {
+	var err error
+	if entity, err = openpgp.NewEntity(name, "", email, nil); err != nil {
+		t.Fatalf("openpgp.NewEntity unexpected error: %v", err)
+	}
+	return
+}
+
+// clearsignDsc returns the text clearsigned by the given key
+func clearsignDsc(t *testing.T, entity *openpgp.Entity, text string) (signed []byte) {
+	var buf bytes.Buffer
+	w, err := clearsign.Encode(&buf, entity.PrivateKey, nil)
+	if err != nil {
+		t.Fatalf("clearsign.Encode unexpected error: %v", err)
+	}
+	if _, err = w.Write([]byte(text)); err != nil {
+		t.Fatalf("clearsign write unexpected error: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("clearsign close unexpected error: %v", err)
+	}
+	signed = buf.Bytes()
+	return
+}
+
+// armoredKey returns the armored public key of the given entity
+func armoredKey(t *testing.T, entity *openpgp.Entity) (data []byte) {
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		t.Fatalf("armor.Encode unexpected error: %v", err)
+	}
+	if err = entity.Serialize(w); err != nil {
+		t.Fatalf("entity.Serialize unexpected error: %v", err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatalf("armor close unexpected error: %v", err)
+	}
+	data = buf.Bytes()
+	return
+}
+
+func TestParse(t *testing.T) {
+	signer := testEntity(t, "Jane Doe", "jane@example.org")
+	tests := []struct {
+		name   string
+		data   []byte
+		signed bool
+	}{
+		{"plain", []byte(testDsc), false},
+		{"clearsigned", clearsignDsc(t, signer, testDsc), true},
+	}
+	for _, test := range tests {
+		src, err := Parse(test.data)
+		if err != nil {
+			t.Errorf("%v: Parse unexpected error: %v", test.name, err)
+			continue
+		}
+		if src.Signed != test.signed {
+			t.Errorf("%v: Signed = %v, want %v", test.name, src.Signed, test.signed)
+		}
+		if src.Name() != "hello" || src.Version() != "2.10-3" {
+			t.Errorf("%v: Name, Version = %q, %q", test.name, src.Name(), src.Version())
+		}
+		if got := fmt.Sprint(src.Binaries()); got != "[hello hello-doc hello-dbg]" {
+			t.Errorf("%v: Binaries() = %v", test.name, got)
+		}
+		var files []string
+		for _, file := range src.Files {
+			files = append(files, fmt.Sprintf("%s:%d:%s:%v:%s", file.Name, file.Size, file.MD5, file.SHA1 != "", file.SHA256[:8]))
+		}
+		if got := strings.Join(files, " "); got != "hello_2.10.orig.tar.gz:100:d41d8cd98f00b204e9800998ecf8427e:false:9f86d081 hello_2.10-3.debian.tar.xz:20:0cc175b9c0f1b6a831c399e269772661:false:60303ae2" {
+			t.Errorf("%v: Files = %v", test.name, got)
+		}
+		if src.Control.Has("Hash") {
+			t.Errorf("%v: armor headers parsed as fields", test.name)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"empty", ""},
+		{"missing version", "Source: hello\n"},
+		{"missing source", "Version: 1.0\n"},
+		{"malformed", "Source hello\n"},
+		{"bad size", "Source: hello\nVersion: 1.0\nFiles:\n d41d8cd98f00b204e9800998ecf8427e ten hello.tar.gz\n"},
+		{"size mismatch", "Source: hello\nVersion: 1.0\nFiles:\n d41d8cd98f00b204e9800998ecf8427e 10 hello.tar.gz\nChecksums-Sha1:\n da39a3ee5e6b4b0d3255bfef95601890afd80709 11 hello.tar.gz\n"},
+	}
+	for _, test := range tests {
+		if src, err := Parse([]byte(test.data)); err == nil {
+			t.Errorf("%v: Parse = %v, want error", test.name, src)
+		}
+	}
+}
+
+func TestVerify(t *testing.T) {
+	signer := testEntity(t, "Jane Doe", "jane@example.org")
+	other := testEntity(t, "John Doe", "john@example.org")
+	signed := clearsignDsc(t, signer, testDsc)
+	tampered := bytes.Replace(signed, []byte("Version: 2.10-3"), []byte("Version: 2.10-4"), 1)
+
+	keyring, err := ReadKeyRing(armoredKey(t, signer))
+	if err != nil {
+		t.Fatalf("ReadKeyRing unexpected error: %v", err)
+	}
+	otherKeyring := openpgp.EntityList{other}
+
+	tests := []struct {
+		name    string
+		data    []byte
+		keyring openpgp.KeyRing
+		status  SignatureStatus
+	}{
+		{"unsigned", []byte(testDsc), keyring, Unsigned},
+		{"unsigned without keyring", []byte(testDsc), nil, Unsigned},
+		{"without keyring", signed, nil, Unverified},
+		{"valid", signed, keyring, Valid},
+		{"unknown key", signed, otherKeyring, UnknownKey},
+		{"tampered", tampered, keyring, Invalid},
+	}
+	for _, test := range tests {
+		sig := Verify(test.data, test.keyring)
+		if sig.Status != test.status {
+			t.Errorf("%v: Verify status = %v (%v), want %v", test.name, sig.Status, sig.Error, test.status)
+			continue
+		}
+		if test.status == Invalid && sig.Error == "" {
+			t.Errorf("%v: Verify is invalid without an error", test.name)
+		}
+		if test.status == Valid {
+			if want := "Jane Doe <jane@example.org>"; sig.Signer != want {
+				t.Errorf("%v: Signer = %q, want %q", test.name, sig.Signer, want)
+			}
+			if want := fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint); sig.Fingerprint != want {
+				t.Errorf("%v: Fingerprint = %q, want %q", test.name, sig.Fingerprint, want)
+			}
+		}
+	}
+}
+
+func TestReadKeyRing(t *testing.T) {
+	entity := testEntity(t, "Jane Doe", "jane@example.org")
+	var binary bytes.Buffer
+	if err := entity.Serialize(&binary); err != nil {
+		t.Fatalf("entity.Serialize unexpected error: %v", err)
+	}
+	tests := []struct {
+		name string
+		data []byte
+		ok   bool
+	}{
+		{"armored", armoredKey(t, entity), true},
+		{"binary", binary.Bytes(), true},
+		{"garbage", []byte("not a keyring"), false},
+	}
+	for _, test := range tests {
+		keyring, err := ReadKeyRing(test.data)
+		if ok := err == nil && len(keyring) == 1; ok != test.ok {
+			t.Errorf("%v: ReadKeyRing = %v, %v, want ok %v", test.name, keyring, err, test.ok)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dsc
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	pgperrors "github.com/ProtonMail/go-crypto/openpgp/errors"
+)
+
+// SignatureStatus is the outcome of verifying a .dsc signature
+type SignatureStatus string
+
+const (
+	// Unsigned is a .dsc without an OpenPGP clearsignature
+	Unsigned SignatureStatus = "unsigned"
+	// Unverified is a signed .dsc checked without a keyring
+	Unverified SignatureStatus = "unverified"
+	// Valid is a good signature by a key within the keyring
+	Valid SignatureStatus = "valid"
+	// UnknownKey is a signature by a key not within the keyring
+	UnknownKey SignatureStatus = "unknown-key"
+	// Invalid is a bad or unreadable signature
+	Invalid SignatureStatus = "invalid"
+)
+
+// Signature is the result of verifying a .dsc signature
+type Signature struct {
+	Status SignatureStatus `json:"status"`
+	// Signer is the primary user id of the signing key
+	Signer string `json:"signer,omitempty"`
+	// Fingerprint is the fingerprint of the signing key
+	Fingerprint string `json:"fingerprint,omitempty"`
+	// Error describes why an Invalid signature failed
+	Error string `json:"error,omitempty"`
+}
+
+// ReadKeyRing parses the armored, or binary, OpenPGP keyring data
+func ReadKeyRing(data []byte) (keyring openpgp.EntityList, err error) {
+	if keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data)); err != nil {
+		if keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data)); err != nil {
+			err = fmt.Errorf("error reading keyring: %w", err)
+		}
+	}
+	return
+}
+
+// Verify checks the clearsignature of the .dsc file data against the keyring,
+// a nil keyring only checks if the data is signed
+func Verify(data []byte, keyring openpgp.KeyRing) (sig *Signature) {
+	block, _ := clearsign.Decode(data)
+	if block == nil {
+		sig = &Signature{Status: Unsigned}
+		return
+	}
+	if keyring == nil {
+		sig = &Signature{Status: Unverified}
+		return
+	}
+
+	signer, err := openpgp.CheckDetachedSignature(keyring, bytes.NewReader(block.Bytes), block.ArmoredSignature.Body, nil)
+	switch {
+	case errors.Is(err, pgperrors.ErrUnknownIssuer):
+		sig = &Signature{Status: UnknownKey}
+	case err != nil:
+		sig = &Signature{Status: Invalid, Error: err.Error()}
+	default:
+		sig = &Signature{Status: Valid, Fingerprint: fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)}
+		// the primary user id, otherwise the first in name order
+		for name, identity := range signer.Identities {
+			if identity.SelfSignature != nil && identity.SelfSignature.IsPrimaryId != nil && *identity.SelfSignature.IsPrimaryId {
+				sig.Signer = name
+				break
+			}
+			if sig.Signer == "" || name < sig.Signer {
+				sig.Signer = name
+			}
+		}
+	}
+	return
+}
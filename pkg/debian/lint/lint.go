@@ -0,0 +1,135 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint provides a small, pluggable policy checker for Debian binary
+// packages, in the spirit of lintian
+package lint
+
+import (
+	"sort"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+// Severity is the importance of a Finding
+type Severity string
+
+const (
+	Error   Severity = "error"
+	Warning Severity = "warning"
+	Info    Severity = "info"
+)
+
+// Rank returns the ordering of the Severity, lower is more severe and unknown
+// severities rank after Info
+func (s Severity) Rank() (rank int) {
+	switch s {
+	case Error:
+		rank = 0
+	case Warning:
+		rank = 1
+	case Info:
+		rank = 2
+	default:
+		rank = 3
+	}
+	return
+}
+
+// ParseSeverity returns the named Severity, ok is false for unknown names
+func ParseSeverity(name string) (s Severity, ok bool) {
+	switch s = Severity(name); s {
+	case Error, Warning, Info:
+		ok = true
+	}
+	return
+}
+
+// Finding is a single problem reported by a Rule
+type Finding struct {
+	Rule     string   `json:"rule"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message"`
+}
+
+// Package is the content of a Debian binary package available to Rules
+type Package struct {
+	// Filename is the path of the package file within the repository
+	Filename string
+	// Control is the control file paragraph
+	Control *control.Paragraph
+	// ControlFiles are the control archive members, with Data
+	ControlFiles []*deb.File
+	// Files are the data archive members, without Data
+	Files []*deb.File
+}
+
+// Rule is a single policy check
+type Rule interface {
+	// Name returns the unique tag of the Rule, included with each Finding
+	Name() string
+	// Check returns the findings of the Rule for the given package
+	Check(pkg *Package) (findings []*Finding)
+}
+
+// CheckFn returns a message for each problem found with the given package
+type CheckFn func(pkg *Package) (messages []string)
+
+type rule struct {
+	name     string
+	severity Severity
+	check    CheckFn
+}
+
+// NewRule returns a Rule reporting each message returned by check as a
+// Finding with the given name and severity
+func NewRule(name string, severity Severity, check CheckFn) Rule {
+	return &rule{name: name, severity: severity, check: check}
+}
+
+func (r *rule) Name() string {
+	return r.name
+}
+
+func (r *rule) Check(pkg *Package) (findings []*Finding) {
+	for _, message := range r.check(pkg) {
+		findings = append(findings, &Finding{Rule: r.name, Severity: r.severity, Message: message})
+	}
+	return
+}
+
+// Run checks the package with each of the rules, returning all findings
+// ordered by severity and then in rule order
+func Run(pkg *Package, rules ...Rule) (findings []*Finding) {
+	for _, r := range rules {
+		findings = append(findings, r.Check(pkg)...)
+	}
+	sort.SliceStable(findings, func(i, j int) (less bool) {
+		less = findings[i].Severity.Rank() < findings[j].Severity.Rank()
+		return
+	})
+	return
+}
+
+// Worst returns the most severe Severity of the findings, empty when there
+// are no findings
+func Worst(findings []*Finding) (s Severity) {
+	for _, finding := range findings {
+		if s == "" || finding.Severity.Rank() < s.Rank() {
+			s = finding.Severity
+		}
+	}
+	return
+}
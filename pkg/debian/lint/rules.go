@@ -0,0 +1,178 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+var (
+	// MaxInstalledSize is the largest Installed-Size, in KiB, accepted by the
+	// installed-size-excessive rule
+	MaxInstalledSize int64 = 1024 * 1024
+	// MaxDescriptionWidth is the widest Description line, in characters,
+	// accepted by the description-line-too-long rule
+	MaxDescriptionWidth = 80
+)
+
+var (
+	// Sections are the archive sections of the Debian Policy Manual, which may
+	// be prefixed with any of the Areas
+	Sections = []string{
+		"admin", "cli-mono", "comm", "database", "debian-installer", "debug",
+		"devel", "doc", "editors", "education", "electronics", "embedded",
+		"fonts", "games", "gnome", "gnu-r", "gnustep", "golang", "graphics",
+		"hamradio", "haskell", "httpd", "interpreters", "introspection",
+		"java", "javascript", "kde", "kernel", "libdevel", "libs", "lisp",
+		"localization", "mail", "math", "metapackages", "misc", "net", "news",
+		"ocaml", "oldlibs", "otherosfs", "perl", "php", "python", "ruby",
+		"rust", "science", "shells", "sound", "tasks", "tex", "text", "utils",
+		"vcs", "video", "web", "x11", "xfce", "zope",
+	}
+	// Areas are the archive areas other than main
+	Areas = []string{"contrib", "non-free", "non-free-firmware"}
+	// FhsDirs are the top-level directories packages may install files into
+	FhsDirs = []string{
+		"bin", "boot", "etc", "lib", "lib32", "lib64", "libx32", "opt",
+		"sbin", "srv", "usr", "var",
+	}
+	// FhsUsrDirs are the usr/ directories packages may install files into
+	FhsUsrDirs = []string{
+		"bin", "games", "include", "lib", "lib32", "lib64", "libexec",
+		"libx32", "sbin", "share", "src",
+	}
+)
+
+// DefaultRules returns a new list of the built-in rules
+func DefaultRules() (rules []Rule) {
+	rules = []Rule{
+		NewRule("maintainer-email-missing", Error, checkMaintainerEmail),
+		NewRule("section-unknown", Warning, checkSection),
+		NewRule("homepage-missing", Info, checkHomepage),
+		NewRule("installed-size-excessive", Warning, checkInstalledSize),
+		NewRule("file-world-writable", Error, checkWorldWritable),
+		NewRule("file-setuid-setgid", Warning, checkSetuid),
+		NewRule("file-outside-fhs", Warning, checkFhs),
+		NewRule("description-line-too-long", Info, checkDescriptionWidth),
+	}
+	return
+}
+
+func checkMaintainerEmail(pkg *Package) (messages []string) {
+	if _, email := pkg.Control.Maintainer(); email == "" {
+		messages = append(messages, fmt.Sprintf("Maintainer has no email address: %q", pkg.Control.Folded("Maintainer")))
+	}
+	return
+}
+
+func checkSection(pkg *Package) (messages []string) {
+	value := pkg.Control.Value("Section")
+	if value == "" {
+		messages = append(messages, "Section is missing")
+		return
+	}
+	section := value
+	if area, name, found := strings.Cut(value, "/"); found && slices.Contains(Areas, area) {
+		section = name
+	}
+	if !slices.Contains(Sections, section) {
+		messages = append(messages, fmt.Sprintf("Section is not a Debian archive section: %q", value))
+	}
+	return
+}
+
+func checkHomepage(pkg *Package) (messages []string) {
+	if pkg.Control.Value("Homepage") == "" {
+		messages = append(messages, "Homepage is missing")
+	}
+	return
+}
+
+func checkInstalledSize(pkg *Package) (messages []string) {
+	if !pkg.Control.Has("Installed-Size") {
+		return
+	}
+	if size, err := pkg.Control.InstalledSize(); err != nil {
+		messages = append(messages, fmt.Sprintf("Installed-Size is invalid: %v", err))
+	} else if size > MaxInstalledSize {
+		messages = append(messages, fmt.Sprintf("Installed-Size of %d KiB exceeds %d KiB", size, MaxInstalledSize))
+	}
+	return
+}
+
+func checkWorldWritable(pkg *Package) (messages []string) {
+	for _, file := range pkg.Files {
+		if file.IsSymlink() || file.Mode.Perm()&0o002 == 0 {
+			continue
+		}
+		if file.IsDir() && file.Mode&os.ModeSticky != 0 {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("/%s is world-writable: %s", deb.CleanName(file.Name), deb.ModeString(file.Type, file.Mode)))
+	}
+	return
+}
+
+func checkSetuid(pkg *Package) (messages []string) {
+	for _, file := range pkg.Files {
+		if !file.IsRegular() || file.Mode&(os.ModeSetuid|os.ModeSetgid) == 0 {
+			continue
+		}
+		messages = append(messages, fmt.Sprintf("/%s is setuid or setgid %s/%s: %s", deb.CleanName(file.Name), file.Uname, file.Gname, deb.ModeString(file.Type, file.Mode)))
+	}
+	return
+}
+
+func checkFhs(pkg *Package) (messages []string) {
+	var outside []string
+	for _, file := range pkg.Files {
+		name := deb.CleanName(file.Name)
+		if name == "" || name == "." {
+			continue
+		}
+		parts := strings.SplitN(name, "/", 3)
+		var dir string
+		switch {
+		case !slices.Contains(FhsDirs, parts[0]):
+			dir = parts[0]
+		case parts[0] != "usr" || len(parts) < 2:
+		case len(parts) == 2 && file.IsDir() && slices.Contains(FhsUsrDirs, parts[1]):
+		case !slices.Contains(FhsUsrDirs, parts[1]):
+			dir = "usr/" + parts[1]
+		}
+		if dir != "" && !slices.Contains(outside, dir) {
+			outside = append(outside, dir)
+		}
+	}
+	for _, dir := range outside {
+		messages = append(messages, fmt.Sprintf("files installed outside of the FHS directories: /%s", dir))
+	}
+	return
+}
+
+func checkDescriptionWidth(pkg *Package) (messages []string) {
+	for idx, line := range strings.Split(pkg.Control.Value("Description"), "\n") {
+		if width := utf8.RuneCountInString(line); width > MaxDescriptionWidth {
+			messages = append(messages, fmt.Sprintf("Description line %d is %d characters wide, over %d", idx+1, width, MaxDescriptionWidth))
+		}
+	}
+	return
+}
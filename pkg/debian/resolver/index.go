@@ -0,0 +1,76 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/ulikunitz/xz"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+// ReadIndex returns the packages listed in the Packages index file, which
+// may be gzip or xz compressed. Malformed entries are skipped and returned as
+// problems
+func ReadIndex(path string, base bool) (packages []*Package, problems []string, err error) {
+	var fh *os.File
+	if fh, err = os.Open(path); err != nil {
+		return
+	}
+	defer func() { _ = fh.Close() }()
+
+	var r io.Reader = fh
+	switch filepath.Ext(path) {
+	case ".gz":
+		var gr *gzip.Reader
+		if gr, err = gzip.NewReader(fh); err != nil {
+			err = fmt.Errorf("error reading gzip index: %w", err)
+			return
+		}
+		defer func() { _ = gr.Close() }()
+		r = gr
+	case ".xz":
+		if r, err = xz.NewReader(fh); err != nil {
+			err = fmt.Errorf("error reading xz index: %w", err)
+			return
+		}
+	}
+
+	var paragraphs []*control.Paragraph
+	if paragraphs, err = control.Parse(r); err != nil {
+		err = fmt.Errorf("error parsing index: %w", err)
+		return
+	}
+	packages, problems = NewPackages(paragraphs, base)
+	return
+}
+
+// NewPackages returns the packages described by the Packages index
+// paragraphs. Malformed entries are skipped and returned as problems
+func NewPackages(paragraphs []*control.Paragraph, base bool) (packages []*Package, problems []string) {
+	for _, paragraph := range paragraphs {
+		if pkg, err := NewPackage(paragraph, base); err != nil {
+			problems = append(problems, err.Error())
+		} else {
+			packages = append(packages, pkg)
+		}
+	}
+	return
+}
@@ -0,0 +1,107 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ulikunitz/xz"
+)
+
+const testIndex = `Package: hello
+Version: 2.10-3
+Architecture: amd64
+
+Package: broken
+Architecture: amd64
+
+Package: hello-data
+Version: 2.10-3
+Architecture: all
+`
+
+// writeIndex writes the index text to name within a temporary directory,
+// compressed according to the name extension
+func writeIndex(t *testing.T, name, text string) (path string) {
+	var buf bytes.Buffer
+	switch filepath.Ext(name) {
+	case ".gz":
+		w := gzip.NewWriter(&buf)
+		_, _ = w.Write([]byte(text))
+		_ = w.Close()
+	case ".xz":
+		w, err := xz.NewWriter(&buf)
+		if err != nil {
+			t.Fatalf("xz.NewWriter unexpected error: %v", err)
+		}
+		_, _ = w.Write([]byte(text))
+		_ = w.Close()
+	default:
+		buf.WriteString(text)
+	}
+	path = writeFile(t, name, buf.Bytes())
+	return
+}
+
+// writeFile writes the data to name within a temporary directory
+func writeFile(t *testing.T, name string, data []byte) (path string) {
+	path = filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatalf("os.WriteFile unexpected error: %v", err)
+	}
+	return
+}
+
+func TestReadIndex(t *testing.T) {
+	for _, name := range []string{"Packages", "Packages.gz", "Packages.xz"} {
+		packages, problems, err := ReadIndex(writeIndex(t, name, testIndex), true)
+		if err != nil {
+			t.Errorf("ReadIndex(%v) unexpected error: %v", name, err)
+			continue
+		}
+		var found []string
+		for _, pkg := range packages {
+			found = append(found, fmt.Sprintf("%v/%v", pkg.Name, pkg.Base))
+		}
+		if got := fmt.Sprint(found); got != "[hello/true hello-data/true]" {
+			t.Errorf("ReadIndex(%v) = %v", name, got)
+		}
+		if len(problems) != 1 {
+			t.Errorf("ReadIndex(%v) problems = %v, want 1", name, problems)
+		}
+	}
+}
+
+func TestReadIndexErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing", filepath.Join(t.TempDir(), "Packages")},
+		{"not gzip", writeFile(t, "Packages.gz", []byte("not gzip"))},
+		{"not xz", writeFile(t, "Packages.xz", []byte("not xz"))},
+		{"malformed", writeIndex(t, "Packages.gz", "Package hello\n")},
+	}
+	for _, test := range tests {
+		if packages, _, err := ReadIndex(test.path, false); err == nil {
+			t.Errorf("%v: ReadIndex = %v, want error", test.name, packages)
+		}
+	}
+}
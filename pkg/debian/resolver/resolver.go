@@ -0,0 +1,257 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package resolver checks the installability of Debian binary packages
+// against the other packages available for the same architecture
+package resolver
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+// DependencyFields are the relationship fields which must be satisfied for a
+// package to be installable
+var DependencyFields = []string{"Pre-Depends", "Depends"}
+
+// Package is a single Packages index entry
+type Package struct {
+	Name         string
+	Version      string
+	Architecture string
+	// Filename is the path of the package file within the repository
+	Filename string
+	// Base is true for packages of the base system, which are assumed to be
+	// installable and are not reported on
+	Base bool
+
+	relations map[string]control.Relations
+	provides  control.Relations
+}
+
+// NewPackage returns the Package described by the Packages index paragraph
+func NewPackage(paragraph *control.Paragraph, base bool) (pkg *Package, err error) {
+	pkg = &Package{
+		Name:         paragraph.Value("Package"),
+		Version:      paragraph.Value("Version"),
+		Architecture: paragraph.Value("Architecture"),
+		Filename:     paragraph.Value("Filename"),
+		Base:         base,
+		relations:    make(map[string]control.Relations),
+	}
+	if pkg.Name == "" || pkg.Version == "" || pkg.Architecture == "" {
+		err = fmt.Errorf("missing Package, Version or Architecture field")
+		return
+	}
+	for _, field := range DependencyFields {
+		if pkg.relations[field], err = paragraph.Relations(field); err != nil {
+			err = fmt.Errorf("error parsing %v of %v: %w", field, pkg.Name, err)
+			return
+		}
+	}
+	if pkg.provides, err = paragraph.Relations("Provides"); err != nil {
+		err = fmt.Errorf("error parsing Provides of %v: %w", pkg.Name, err)
+	}
+	return
+}
+
+// String returns the name, version and architecture of the package
+func (pkg *Package) String() (text string) {
+	text = pkg.Name + " " + pkg.Version + " " + pkg.Architecture
+	return
+}
+
+// Result is an uninstallable package and the reasons why
+type Result struct {
+	Package  *Package
+	Problems []string
+}
+
+// Check returns the packages of the given architecture, other than those of
+// the base system, which can not be installed using only the given packages.
+// When closed is false, dependencies on names which no package of any
+// architecture has or provides are assumed to be met by a system outside the
+// given packages. Conflicts and Breaks are not considered. Results are in the
+// given package order
+func Check(arch string, packages []*Package, closed bool) (results []*Result) {
+	u := newUniverse(arch, packages)
+
+	broken := make(map[*Package][]string)
+	for changed := true; changed; {
+		changed = false
+		for _, pkg := range u.packages {
+			if pkg.Base {
+				continue
+			}
+			if _, present := broken[pkg]; present {
+				continue
+			}
+			if problems := u.unmet(pkg, broken, closed); len(problems) > 0 {
+				broken[pkg] = problems
+				changed = true
+			}
+		}
+	}
+
+	for _, pkg := range u.packages {
+		if problems, present := broken[pkg]; present {
+			results = append(results, &Result{Package: pkg, Problems: problems})
+		}
+	}
+	return
+}
+
+type provider struct {
+	pkg      *Package
+	relation *control.Relation
+}
+
+// universe is the set of packages installable on one architecture
+type universe struct {
+	arch      string
+	packages  []*Package
+	byName    map[string][]*Package
+	providers map[string][]*provider
+	// names are all the package names had or provided by any architecture
+	names map[string]struct{}
+}
+
+func newUniverse(arch string, packages []*Package) (u *universe) {
+	u = &universe{
+		arch:      arch,
+		byName:    make(map[string][]*Package),
+		providers: make(map[string][]*provider),
+		names:     make(map[string]struct{}),
+	}
+	for _, pkg := range packages {
+		u.names[pkg.Name] = struct{}{}
+		for _, alternatives := range pkg.provides {
+			for _, relation := range alternatives {
+				u.names[relation.Name] = struct{}{}
+			}
+		}
+		if pkg.Architecture != arch && pkg.Architecture != "all" {
+			continue
+		}
+		u.packages = append(u.packages, pkg)
+		u.byName[pkg.Name] = append(u.byName[pkg.Name], pkg)
+		for _, alternatives := range pkg.provides {
+			for _, relation := range alternatives {
+				u.providers[relation.Name] = append(u.providers[relation.Name], &provider{pkg: pkg, relation: relation})
+			}
+		}
+	}
+	return
+}
+
+// candidates returns the packages which satisfy the relation, installable or
+// not
+func (u *universe) candidates(relation *control.Relation) (found []*Package) {
+	for _, pkg := range u.byName[relation.Name] {
+		if relation.Satisfies(pkg.Version) {
+			found = append(found, pkg)
+		}
+	}
+	for _, p := range u.providers[relation.Name] {
+		// versioned relations are only satisfied by versioned provides
+		if relation.Operator == "" || (p.relation.Operator == "=" && relation.Satisfies(p.relation.Version)) {
+			found = append(found, p.pkg)
+		}
+	}
+	return
+}
+
+// known returns true if any package of any architecture has or provides the
+// name
+func (u *universe) known(name string) (ok bool) {
+	_, ok = u.names[name]
+	return
+}
+
+// unmet returns a problem for each dependency of the package which is not
+// satisfied by an installable package
+func (u *universe) unmet(pkg *Package, broken map[*Package][]string, closed bool) (problems []string) {
+	for _, field := range DependencyFields {
+		for _, alternatives := range pkg.relations[field] {
+			var satisfied bool
+			var uninstallable []string
+			var unavailable []string
+			for _, relation := range alternatives {
+				if !closed && !u.known(relation.Name) {
+					satisfied = true
+					break
+				}
+				found := u.candidates(relation)
+				for _, candidate := range found {
+					if _, present := broken[candidate]; !present {
+						satisfied = true
+						break
+					}
+					uninstallable = append(uninstallable, candidate.String())
+				}
+				if satisfied {
+					break
+				}
+				if len(found) == 0 {
+					unavailable = append(unavailable, u.describe(relation))
+				}
+			}
+			if satisfied {
+				continue
+			}
+			problem := fmt.Sprintf("%s: %s is not satisfiable", field, alternatives.String())
+			var reasons []string
+			if len(unavailable) > 0 {
+				reasons = append(reasons, strings.Join(unavailable, ", "))
+			}
+			if len(uninstallable) > 0 {
+				reasons = append(reasons, "uninstallable "+strings.Join(uninstallable, ", "))
+			}
+			if len(reasons) > 0 {
+				problem += " (" + strings.Join(reasons, "; ") + ")"
+			}
+			problems = append(problems, problem)
+		}
+	}
+	return
+}
+
+// describe explains why no package satisfies the relation
+func (u *universe) describe(relation *control.Relation) (text string) {
+	var versions []string
+	for _, pkg := range u.byName[relation.Name] {
+		if !slices.Contains(versions, pkg.Version) {
+			versions = append(versions, pkg.Version)
+		}
+	}
+	if len(versions) == 0 {
+		var providers []string
+		for _, p := range u.providers[relation.Name] {
+			if !slices.Contains(providers, p.pkg.Name) {
+				providers = append(providers, p.pkg.Name)
+			}
+		}
+		if len(providers) > 0 {
+			text = relation.Name + " is only provided without a satisfying version by " + strings.Join(providers, ", ")
+			return
+		}
+		text = relation.Name + " is not available for " + u.arch
+		return
+	}
+	text = relation.Name + " is only available as " + strings.Join(versions, ", ")
+	return
+}
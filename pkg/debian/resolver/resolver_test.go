@@ -0,0 +1,183 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package resolver
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+// testPackages parses the Packages index text, failing on any problem
+func testPackages(t *testing.T, text string, base bool) (packages []*Package) {
+	paragraphs, err := control.ParseString(text)
+	if err != nil {
+		t.Fatalf("control.ParseString unexpected error: %v", err)
+	}
+	var problems []string
+	if packages, problems = NewPackages(paragraphs, base); len(problems) > 0 {
+		t.Fatalf("NewPackages problems: %v", problems)
+	}
+	return
+}
+
+// formatResults returns one "name: problem; problem" line per result
+func formatResults(results []*Result) (text string) {
+	var lines []string
+	for _, result := range results {
+		lines = append(lines, result.Package.Name+": "+strings.Join(result.Problems, "; "))
+	}
+	text = strings.Join(lines, "\n")
+	return
+}
+
+const testBase = `Package: libc6
+Version: 2.36-9
+Architecture: amd64
+
+Package: libc6
+Version: 2.36-9
+Architecture: arm64
+`
+
+const testRepository = `Package: hello
+Version: 2.10-3
+Architecture: amd64
+Depends: libc6 (>= 2.34), hello-data (= 2.10-3)
+
+Package: hello-data
+Version: 2.10-3
+Architecture: all
+
+Package: mailer
+Version: 1.0
+Architecture: amd64
+Depends: default-mta | mail-transport-agent
+
+Package: tiny-mta
+Version: 1.0
+Architecture: amd64
+Provides: mail-transport-agent
+
+Package: needs-new-libc
+Version: 1.0
+Architecture: amd64
+Pre-Depends: libc6 (>= 2.40)
+
+Package: needs-broken
+Version: 1.0
+Architecture: amd64
+Depends: needs-new-libc | hello (>= 3)
+
+Package: needs-chain
+Version: 1.0
+Architecture: all
+Depends: needs-broken
+
+Package: needs-arm
+Version: 1.0
+Architecture: amd64
+Depends: arm-only
+
+Package: arm-only
+Version: 1.0
+Architecture: arm64
+
+Package: needs-versioned-provide
+Version: 1.0
+Architecture: amd64
+Depends: virtual-one (>= 1), virtual-two (>= 2)
+
+Package: provider
+Version: 1.0
+Architecture: amd64
+Provides: virtual-one (= 1.5), virtual-two
+
+Package: needs-external
+Version: 1.0
+Architecture: amd64
+Depends: python3 (>= 3.11)
+`
+
+func TestCheck(t *testing.T) {
+	packages := append(testPackages(t, testBase, true), testPackages(t, testRepository, false)...)
+
+	tests := []struct {
+		name   string
+		arch   string
+		closed bool
+		want   string
+	}{
+		{"amd64", "amd64", false, strings.Join([]string{
+			"needs-new-libc: Pre-Depends: libc6 (>= 2.40) is not satisfiable (libc6 is only available as 2.36-9)",
+			"needs-broken: Depends: needs-new-libc | hello (>= 3) is not satisfiable (hello is only available as 2.10-3; uninstallable needs-new-libc 1.0 amd64)",
+			"needs-chain: Depends: needs-broken is not satisfiable (uninstallable needs-broken 1.0 amd64)",
+			"needs-arm: Depends: arm-only is not satisfiable (arm-only is not available for amd64)",
+			"needs-versioned-provide: Depends: virtual-two (>= 2) is not satisfiable (virtual-two is only provided without a satisfying version by provider)",
+		}, "\n")},
+		{"amd64 closed", "amd64", true, strings.Join([]string{
+			"needs-new-libc: Pre-Depends: libc6 (>= 2.40) is not satisfiable (libc6 is only available as 2.36-9)",
+			"needs-broken: Depends: needs-new-libc | hello (>= 3) is not satisfiable (hello is only available as 2.10-3; uninstallable needs-new-libc 1.0 amd64)",
+			"needs-chain: Depends: needs-broken is not satisfiable (uninstallable needs-broken 1.0 amd64)",
+			"needs-arm: Depends: arm-only is not satisfiable (arm-only is not available for amd64)",
+			"needs-versioned-provide: Depends: virtual-two (>= 2) is not satisfiable (virtual-two is only provided without a satisfying version by provider)",
+			"needs-external: Depends: python3 (>= 3.11) is not satisfiable (python3 is not available for amd64)",
+		}, "\n")},
+		{"arm64", "arm64", false, "needs-chain: Depends: needs-broken is not satisfiable (needs-broken is not available for arm64)"},
+		{"unknown architecture", "riscv64", false, "needs-chain: Depends: needs-broken is not satisfiable (needs-broken is not available for riscv64)"},
+	}
+	for _, test := range tests {
+		if got := formatResults(Check(test.arch, packages, test.closed)); got != test.want {
+			t.Errorf("%v: Check results:\n%v\nwant:\n%v", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCheckBase(t *testing.T) {
+	// base packages are never reported, even when their own dependencies
+	// are unmet
+	packages := testPackages(t, "Package: base\nVersion: 1\nArchitecture: amd64\nDepends: missing\n", true)
+	if results := Check("amd64", packages, true); len(results) > 0 {
+		t.Errorf("Check reported base packages: %v", formatResults(results))
+	}
+}
+
+func TestNewPackage(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"Package: hello\nVersion: 1.0\nArchitecture: all\nFilename: pool/main/h/hello/hello_1.0_all.deb\n", "hello 1.0 all", true},
+		{"Package: hello\nVersion: 1.0\nArchitecture: amd64\nDepends: libc6,\n libfoo (>= 1) | libbar\n", "hello 1.0 amd64", true},
+		{"Package: hello\nVersion: 1.0\n", "", false},
+		{"Version: 1.0\nArchitecture: all\n", "", false},
+		{"Package: hello\nVersion: 1.0\nArchitecture: all\nDepends: libc6 (>= )\n", "", false},
+		{"Package: hello\nVersion: 1.0\nArchitecture: all\nProvides: -bad\n", "", false},
+	}
+	for _, test := range tests {
+		paragraph, err := control.ParseParagraph(test.input)
+		if err != nil {
+			t.Fatalf("control.ParseParagraph(%q) unexpected error: %v", test.input, err)
+		}
+		pkg, err := NewPackage(paragraph, false)
+		if (err == nil) != test.ok {
+			t.Errorf("NewPackage(%q) error = %v, want ok %v", test.input, err, test.ok)
+		} else if test.ok && pkg.String() != test.want {
+			t.Errorf("NewPackage(%q) = %q, want %q", test.input, pkg.String(), test.want)
+		}
+	}
+}
@@ -0,0 +1,53 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"sort"
+)
+
+// Versions implements sort.Interface for a list of Version, in ascending
+// order
+type Versions []Version
+
+func (v Versions) Len() int           { return len(v) }
+func (v Versions) Less(i, j int) bool { return v[i].Compare(v[j]) < 0 }
+func (v Versions) Swap(i, j int)      { v[i], v[j] = v[j], v[i] }
+
+// Sort sorts the given version strings in ascending order, see Compare
+func Sort(versions []string) {
+	sort.SliceStable(versions, func(i, j int) (less bool) {
+		less = Compare(versions[i], versions[j]) < 0
+		return
+	})
+}
+
+// SortDescending sorts the given version strings newest first, see Compare
+func SortDescending(versions []string) {
+	sort.SliceStable(versions, func(i, j int) (less bool) {
+		less = Compare(versions[i], versions[j]) > 0
+		return
+	})
+}
+
+// Latest returns the newest of the given version strings, see Compare
+func Latest(versions ...string) (latest string) {
+	for idx, v := range versions {
+		if idx == 0 || Compare(v, latest) > 0 {
+			latest = v
+		}
+	}
+	return
+}
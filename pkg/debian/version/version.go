@@ -0,0 +1,254 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version provides parsing of Debian package version strings
+package version
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Version is a parsed Debian package version string of the form:
+//
+//	[epoch:]upstream_version[-debian_revision]
+type Version struct {
+	Epoch    int
+	Upstream string
+	Revision string
+}
+
+// Parse returns the Version represented by the given input string. As with
+// dpkg, the characters used are not validated here, see Validate
+func Parse(input string) (v Version, err error) {
+	input = strings.TrimSpace(input)
+	if input == "" {
+		err = fmt.Errorf("version string is empty")
+		return
+	} else if strings.ContainsAny(input, " \t\r\n") {
+		err = fmt.Errorf("version string has embedded spaces: %q", input)
+		return
+	}
+
+	if epoch, rest, found := strings.Cut(input, ":"); found {
+		if epoch == "" {
+			err = fmt.Errorf("epoch in version is empty: %q", input)
+			return
+		} else if v.Epoch, err = strconv.Atoi(epoch); err != nil || v.Epoch < 0 {
+			err = fmt.Errorf("invalid epoch in version: %q", input)
+			return
+		}
+		input = rest
+	}
+
+	if idx := strings.LastIndex(input, "-"); idx >= 0 {
+		v.Upstream = input[:idx]
+		v.Revision = input[idx+1:]
+		if v.Revision == "" {
+			err = fmt.Errorf("revision number is empty: %q", input)
+			return
+		}
+	} else {
+		v.Upstream = input
+	}
+
+	if v.Upstream == "" {
+		err = fmt.Errorf("upstream version is empty")
+	}
+	return
+}
+
+// MustParse is a convenience wrapper around Parse which panics on error
+func MustParse(input string) (v Version) {
+	var err error
+	if v, err = Parse(input); err != nil {
+		panic(err)
+	}
+	return
+}
+
+// Validate checks the version against the Debian policy, which dpkg only
+// warns about: the upstream version must start with a digit and only use
+// alphanumerics and the characters . + ~ - (and : without an epoch), the
+// revision only alphanumerics and . + ~
+func (v Version) Validate() (err error) {
+	if v.Upstream == "" {
+		err = fmt.Errorf("upstream version is empty")
+		return
+	} else if !isDigit(v.Upstream[0]) {
+		err = fmt.Errorf("upstream version does not start with a digit: %q", v.Upstream)
+		return
+	}
+	for _, c := range []byte(v.Upstream) {
+		if !isAlnum(c) && !strings.ContainsRune(".+~-:", rune(c)) {
+			err = fmt.Errorf("invalid character in upstream version: %q", v.Upstream)
+			return
+		}
+	}
+	for _, c := range []byte(v.Revision) {
+		if !isAlnum(c) && !strings.ContainsRune(".+~", rune(c)) {
+			err = fmt.Errorf("invalid character in revision number: %q", v.Revision)
+			return
+		}
+	}
+	return
+}
+
+// String returns the Debian version string for this Version
+func (v Version) String() (version string) {
+	if v.Epoch > 0 {
+		version = strconv.Itoa(v.Epoch) + ":"
+	}
+	version += v.Upstream
+	if v.Revision != "" {
+		version += "-" + v.Revision
+	}
+	return
+}
+
+// Compare returns -1, 0 or +1 if v sorts before, the same as or after other,
+// using the dpkg version comparison algorithm
+func (v Version) Compare(other Version) (result int) {
+	if v.Epoch != other.Epoch {
+		if v.Epoch < other.Epoch {
+			return -1
+		}
+		return 1
+	}
+	if result = compareString(v.Upstream, other.Upstream); result != 0 {
+		return
+	}
+	result = compareString(v.Revision, other.Revision)
+	return
+}
+
+// Less returns true if v sorts before other
+func (v Version) Less(other Version) (less bool) {
+	less = v.Compare(other) < 0
+	return
+}
+
+// Equal returns true if v and other are equivalent versions, for example
+// "0:1.0-0" and "1.0-0"
+func (v Version) Equal(other Version) (equal bool) {
+	equal = v.Compare(other) == 0
+	return
+}
+
+// Compare parses and compares the version strings a and b, see
+// Version.Compare. Strings which fail to parse are compared verbatim with
+// the same algorithm and sort before any valid version
+func Compare(a, b string) (result int) {
+	va, errA := Parse(a)
+	vb, errB := Parse(b)
+	switch {
+	case errA == nil && errB == nil:
+		result = va.Compare(vb)
+	case errA != nil && errB != nil:
+		result = compareString(a, b)
+	case errA != nil:
+		result = -1
+	default:
+		result = 1
+	}
+	return
+}
+
+// order returns the dpkg sorting weight of the non-digit character c, where
+// zero is the end of the string
+func order(c byte) (weight int) {
+	switch {
+	case c >= '0' && c <= '9':
+		weight = 0
+	case c >= 'A' && c <= 'Z', c >= 'a' && c <= 'z':
+		weight = int(c)
+	case c == '~':
+		weight = -1
+	case c != 0:
+		weight = int(c) + 256
+	}
+	return
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isAlnum(c byte) bool {
+	return isDigit(c) || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z')
+}
+
+// compareString implements dpkg's verrevcmp, comparing alternating non-digit
+// and digit runs of the given version parts
+func compareString(a, b string) (result int) {
+	at := func(s string, i int) (c byte) {
+		if i < len(s) {
+			c = s[i]
+		}
+		return
+	}
+
+	var i, j int
+	for i < len(a) || j < len(b) {
+		firstDiff := 0
+
+		for (i < len(a) && !isDigit(a[i])) || (j < len(b) && !isDigit(b[j])) {
+			ac, bc := order(at(a, i)), order(at(b, j))
+			if i < len(a) && isDigit(a[i]) {
+				ac = 0
+			}
+			if j < len(b) && isDigit(b[j]) {
+				bc = 0
+			}
+			if ac != bc {
+				return sign(ac - bc)
+			}
+			i, j = i+1, j+1
+		}
+
+		for i < len(a) && a[i] == '0' {
+			i++
+		}
+		for j < len(b) && b[j] == '0' {
+			j++
+		}
+		for i < len(a) && isDigit(a[i]) && j < len(b) && isDigit(b[j]) {
+			if firstDiff == 0 {
+				firstDiff = int(a[i]) - int(b[j])
+			}
+			i, j = i+1, j+1
+		}
+		if i < len(a) && isDigit(a[i]) {
+			return 1
+		}
+		if j < len(b) && isDigit(b[j]) {
+			return -1
+		}
+		if firstDiff != 0 {
+			return sign(firstDiff)
+		}
+	}
+	return
+}
+
+func sign(value int) (result int) {
+	switch {
+	case value < 0:
+		result = -1
+	case value > 0:
+		result = 1
+	}
+	return
+}
@@ -0,0 +1,188 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Version
+	}{
+		{"1.0", Version{Upstream: "1.0"}},
+		{"1.0-1", Version{Upstream: "1.0", Revision: "1"}},
+		{"1:1.0-1", Version{Epoch: 1, Upstream: "1.0", Revision: "1"}},
+		{"0:1.0", Version{Upstream: "1.0"}},
+		{"1.0-2-3", Version{Upstream: "1.0-2", Revision: "3"}},
+		{"2:1.0:3-1", Version{Epoch: 2, Upstream: "1.0:3", Revision: "1"}},
+		{"1.0~rc1+dfsg-0ubuntu1", Version{Upstream: "1.0~rc1+dfsg", Revision: "0ubuntu1"}},
+		{" 1.0-1 ", Version{Upstream: "1.0", Revision: "1"}},
+	}
+	for _, test := range tests {
+		got, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q) unexpected error: %v", test.input, err)
+		} else if got != test.want {
+			t.Errorf("Parse(%q) = %#v, want %#v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"1.0 1",
+		":1.0",
+		"a:1.0",
+		"-1:1.0",
+		"1.5:1.0",
+		"1.0-",
+		"1:1.0-",
+		"-1",
+		"1:",
+	}
+	for _, input := range tests {
+		if got, err := Parse(input); err == nil {
+			t.Errorf("Parse(%q) = %#v, want error", input, got)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		input string
+		valid bool
+	}{
+		{"1.0-1", true},
+		{"1:1.0~rc1+dfsg-0ubuntu1", true},
+		{"1.0-1-2", true},
+		{"a1.0", false},
+		{"1.0_1", false},
+		{"1.0-1_2", false},
+		{"1:1.0-1:2", false},
+	}
+	for _, test := range tests {
+		err := MustParse(test.input).Validate()
+		if test.valid && err != nil {
+			t.Errorf("Validate(%q) unexpected error: %v", test.input, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("Validate(%q) expected an error", test.input)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	// expected results match: dpkg --compare-versions a lt|eq|gt b
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		// epochs
+		{"1:1.0", "2.0", 1},
+		{"1:1.0", "1:2.0", -1},
+		{"0:1.0", "1.0", 0},
+		{"2:0.1", "1:9.9", 1},
+		{"1.0", "1:0.1", -1},
+
+		// tilde sorts before everything, even the end of the string
+		{"1.0~rc1", "1.0", -1},
+		{"1.0~~", "1.0~", -1},
+		{"1.0~~a", "1.0~~", 1},
+		{"1.0~rc1", "1.0~rc2", -1},
+		{"1.0~", "1.0~", 0},
+		{"1.0~rc1-1", "1.0-1", -1},
+
+		// revisions and native versions
+		{"1.0-1", "1.0-2", -1},
+		{"1.0-10", "1.0-9", 1},
+		{"1.0", "1.0-0", 0},
+		{"1.0", "1.0-1", -1},
+		{"1.0-1", "1.0.1", -1},
+		{"1.0-0ubuntu1", "1.0-1", -1},
+		{"1.0-1+deb12u1", "1.0-1", 1},
+		{"1.0-1~bpo12+1", "1.0-1", -1},
+		{"1.0-2-1", "1.0-10-1", -1},
+
+		// leading zeros are ignored
+		{"1.01", "1.1", 0},
+		{"1.001-1", "1.1-1", 0},
+		{"1.010", "1.10", 0},
+		{"1.010", "1.9", 1},
+
+		// letters sort before non-letters
+		{"1.0a", "1.0+", -1},
+		{"1.0a", "1.0.", -1},
+		{"1.0+", "1.0.", -1},
+		{"1.0a", "1.0", 1},
+		{"1.0A", "1.0a", -1},
+		{"1.0z", "1.0+", -1},
+
+		// numeric comparison of digit runs
+		{"1.2", "1.10", -1},
+		{"10", "9", 1},
+		{"1.0", "1.0", 0},
+		{"1.0.0", "1.0", 1},
+		{"1.0+dfsg", "1.0", 1},
+	}
+	for _, test := range tests {
+		if got := Compare(test.a, test.b); got != test.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+		if got := Compare(test.b, test.a); got != -test.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", test.b, test.a, got, -test.want)
+		}
+	}
+}
+
+func TestCompareInvalid(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"a:1.0", "1.0", -1},
+		{"1.0", "1.0-", 1},
+		{"a:1.0", "b:1.0", -1},
+		{"", "", 0},
+	}
+	for _, test := range tests {
+		if got := Compare(test.a, test.b); got != test.want {
+			t.Errorf("Compare(%q, %q) = %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+func TestSort(t *testing.T) {
+	versions := []string{"1.0", "1:0.9", "1.0~rc1", "1.0-1", "0.9", "1.0~~", "1.0+b1"}
+	want := []string{"0.9", "1.0~~", "1.0~rc1", "1.0", "1.0-1", "1.0+b1", "1:0.9"}
+	Sort(versions)
+	for idx := range want {
+		if versions[idx] != want[idx] {
+			t.Fatalf("Sort = %v, want %v", versions, want)
+		}
+	}
+
+	SortDescending(versions)
+	for idx := range want {
+		if versions[idx] != want[len(want)-1-idx] {
+			t.Fatalf("SortDescending = %v, want reverse of %v", versions, want)
+		}
+	}
+
+	if latest := Latest("1.0", "1.0-1", "1:0.1", "2.0"); latest != "1:0.1" {
+		t.Errorf("Latest = %q, want %q", latest, "1:0.1")
+	}
+}
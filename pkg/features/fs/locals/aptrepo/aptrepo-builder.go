@@ -0,0 +1,552 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aptrepo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+
+	"github.com/go-enjin/be/pkg/log"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/checksums"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/version"
+)
+
+// Distribution describes a single dists/<codename> tree
+type Distribution struct {
+	Codename string
+	// Suite is an optional alias of the codename, such as "stable", written
+	// to the Release files and linked to the codename within dists/
+	Suite         string
+	Components    []string
+	Architectures []string
+}
+
+// SuiteName returns the Suite, or the Codename when there is no Suite
+func (d *Distribution) SuiteName() (suite string) {
+	if suite = d.Suite; suite == "" {
+		suite = d.Codename
+	}
+	return
+}
+
+// BinaryArchitectures returns the Architectures without "source"
+func (d *Distribution) BinaryArchitectures() (archs []string) {
+	for _, arch := range d.Architectures {
+		if arch != "source" {
+			archs = append(archs, arch)
+		}
+	}
+	return
+}
+
+// HasSources returns true if the "source" architecture is present
+func (d *Distribution) HasSources() (present bool) {
+	for _, arch := range d.Architectures {
+		if arch == "source" {
+			return true
+		}
+	}
+	return
+}
+
+// Builder generates a pool/ and dists/ apt repository tree from a directory
+// of package archives
+type Builder struct {
+	Archives      string
+	Repository    string
+	Origin        string
+	Label         string
+	Description   string
+	Distributions []*Distribution
+
+	// Retain is the number of newest versions of each binary package and
+	// architecture, and of each source package, to include in the repository,
+	// older files are removed from the pool. Zero includes all versions
+	Retain int
+
+	// Signer is the OpenPGP entity used to sign Release files, signing is
+	// skipped when nil
+	Signer *openpgp.Entity
+
+	// included are the entries of the archives scanned by the last Build,
+	// keyed by path, which are reused while the archive is unmodified
+	included map[string]*includedArchive
+}
+
+// includedArchive is the binary or source entry of a scanned archive file
+type includedArchive struct {
+	Size    int64
+	ModTime time.Time
+	Binary  *binaryEntry
+	Source  *sourceEntry
+}
+
+// binaryEntry is a single .deb or .udeb file included in the pool
+type binaryEntry struct {
+	Component string
+	Filename  string
+	Control   *control.Paragraph
+	// Path is the archived file copied to the Filename within the pool
+	Path string
+}
+
+// sourceEntry is a single .dsc file included in the pool
+type sourceEntry struct {
+	Component string
+	Directory string
+	Control   *control.Paragraph
+	// Paths are the .dsc and the files it lists, copied into the Directory
+	Paths []string
+}
+
+// NewBuilder constructs a new Builder instance
+func NewBuilder() (b *Builder) {
+	b = &Builder{
+		included: make(map[string]*includedArchive),
+	}
+	return
+}
+
+// Build scans the Archives, copies any new files into the pool and writes the
+// dists indices, changed is true if any index file was modified
+func (b *Builder) Build() (changed bool, err error) {
+	var binaries []*binaryEntry
+	var sources []*sourceEntry
+	if binaries, sources, err = b.scanArchives(); err != nil {
+		return
+	}
+	if b.Retain > 0 {
+		binaries, sources = b.retainVersions(binaries, sources)
+	}
+	if err = b.populatePool(binaries, sources); err != nil {
+		return
+	}
+
+	for _, dist := range b.Distributions {
+		var modified bool
+		if modified, err = b.writeDistribution(dist, binaries, sources); err != nil {
+			err = fmt.Errorf("error writing %v distribution: %w", dist.Codename, err)
+			return
+		}
+		changed = changed || modified
+	}
+
+	if err = b.linkSuites(); err != nil {
+		return
+	}
+
+	_, err = b.Sign(changed)
+	return
+}
+
+// linkSuites creates, or updates, a dists/<suite> symlink to the codename of
+// each distribution with a Suite
+func (b *Builder) linkSuites() (err error) {
+	for _, dist := range b.Distributions {
+		if dist.Suite == "" || dist.Suite == dist.Codename {
+			continue
+		}
+		link := filepath.Join(b.Repository, "dists", dist.Suite)
+		if info, ee := os.Lstat(link); ee == nil {
+			if info.Mode()&os.ModeSymlink == 0 {
+				err = fmt.Errorf("suite %v is not a symlink: %v", dist.Suite, link)
+				return
+			} else if target, _ := os.Readlink(link); target == dist.Codename {
+				continue
+			} else if err = os.Remove(link); err != nil {
+				err = fmt.Errorf("error removing suite symlink: %v - %w", link, err)
+				return
+			}
+		}
+		if err = os.Symlink(dist.Codename, link); err != nil {
+			err = fmt.Errorf("error linking suite %v to %v: %w", dist.Suite, dist.Codename, err)
+			return
+		}
+		log.InfoF("linked apt repository suite %v to %v", dist.Suite, dist.Codename)
+	}
+	return
+}
+
+// Sign writes the InRelease and Release.gpg files for each distribution with
+// missing or outdated signatures, or for all distributions when forced
+func (b *Builder) Sign(force bool) (signed bool, err error) {
+	if b.Signer == nil {
+		return
+	}
+	for _, dist := range b.Distributions {
+		distPath := filepath.Join(b.Repository, "dists", dist.Codename)
+		if !fileExists(filepath.Join(distPath, "Release")) {
+			continue
+		} else if !force && !needsSigning(distPath) {
+			continue
+		}
+		if err = signRelease(b.Signer, distPath); err != nil {
+			err = fmt.Errorf("error signing %v distribution: %w", dist.Codename, err)
+			return
+		}
+		log.DebugF("signed apt repository release: %v", distPath)
+		signed = true
+	}
+	return
+}
+
+func (b *Builder) knownComponent(name string) (known bool) {
+	for _, dist := range b.Distributions {
+		for _, component := range dist.Components {
+			if component == name {
+				return true
+			}
+		}
+	}
+	return
+}
+
+func (b *Builder) defaultComponent() (component string) {
+	for _, dist := range b.Distributions {
+		if len(dist.Components) > 0 {
+			return dist.Components[0]
+		}
+	}
+	return "main"
+}
+
+func (b *Builder) scanArchives() (binaries []*binaryEntry, sources []*sourceEntry, err error) {
+	included := make(map[string]*includedArchive)
+	err = filepath.Walk(b.Archives, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if info.IsDir() {
+			return nil
+		}
+
+		rel, _ := filepath.Rel(b.Archives, path)
+		component := b.defaultComponent()
+		if first, _, nested := strings.Cut(filepath.ToSlash(rel), "/"); nested && b.knownComponent(first) {
+			component = first
+		}
+
+		if prev, present := b.included[path]; present && prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+			if prev.Binary != nil && prev.Binary.Component == component {
+				binaries = append(binaries, prev.Binary)
+				included[path] = prev
+				return nil
+			} else if prev.Source != nil && prev.Source.Component == component {
+				sources = append(sources, prev.Source)
+				included[path] = prev
+				return nil
+			}
+		}
+
+		switch filepath.Ext(path) {
+		case ".deb", ".udeb":
+			var entry *binaryEntry
+			if entry, err = b.includeBinary(component, path); err != nil {
+				return fmt.Errorf("error including %v: %w", rel, err)
+			}
+			binaries = append(binaries, entry)
+			included[path] = &includedArchive{Size: info.Size(), ModTime: info.ModTime(), Binary: entry}
+		case ".dsc":
+			var entry *sourceEntry
+			if entry, err = b.includeSource(component, path); err != nil {
+				return fmt.Errorf("error including %v: %w", rel, err)
+			}
+			sources = append(sources, entry)
+			included[path] = &includedArchive{Size: info.Size(), ModTime: info.ModTime(), Source: entry}
+		}
+		return nil
+	})
+	if err == nil {
+		b.included = included
+	}
+
+	sort.SliceStable(binaries, func(i, j int) (less bool) {
+		a, b := binaries[i], binaries[j]
+		if an, bn := a.Control.Value("Package"), b.Control.Value("Package"); an != bn {
+			return an < bn
+		}
+		if cmp := version.Compare(a.Control.Value("Version"), b.Control.Value("Version")); cmp != 0 {
+			return cmp < 0
+		}
+		return a.Filename < b.Filename
+	})
+	sort.SliceStable(sources, func(i, j int) (less bool) {
+		a, b := sources[i], sources[j]
+		if an, bn := a.Control.Value("Package"), b.Control.Value("Package"); an != bn {
+			return an < bn
+		}
+		return version.Compare(a.Control.Value("Version"), b.Control.Value("Version")) < 0
+	})
+	return
+}
+
+// retainVersions returns only the newest Retain versions of each binary
+// package and architecture, and of each source package. The entries given
+// must be sorted by name and version
+func (b *Builder) retainVersions(binaries []*binaryEntry, sources []*sourceEntry) (keptBinaries []*binaryEntry, keptSources []*sourceEntry) {
+	latest := make(map[string]string)
+	counts := make(map[string]int)
+	// count returns the number of distinct versions seen for the key so far
+	count := func(key, ver string) (seen int) {
+		if previous, present := latest[key]; !present || previous != ver {
+			latest[key] = ver
+			counts[key] += 1
+		}
+		seen = counts[key]
+		return
+	}
+
+	// walk newest first, the entries are sorted by name and version
+	for idx := len(binaries) - 1; idx >= 0; idx-- {
+		entry := binaries[idx]
+		key := entry.Control.Value("Package") + "/" + entry.Control.Value("Architecture")
+		if count(key, entry.Control.Value("Version")) <= b.Retain {
+			keptBinaries = append(keptBinaries, entry)
+		} else {
+			log.DebugF("not retaining binary package: %v", entry.Filename)
+		}
+	}
+	for idx := len(sources) - 1; idx >= 0; idx-- {
+		entry := sources[idx]
+		if count("source:"+entry.Control.Value("Package"), entry.Control.Value("Version")) <= b.Retain {
+			keptSources = append(keptSources, entry)
+		} else {
+			log.DebugF("not retaining source package: %v %v", entry.Control.Value("Package"), entry.Control.Value("Version"))
+		}
+	}
+
+	slices.Reverse(keptBinaries)
+	slices.Reverse(keptSources)
+	return
+}
+
+// populatePool copies the files for all the entries given into the pool and
+// when retaining versions, removes all other files from the pool
+func (b *Builder) populatePool(binaries []*binaryEntry, sources []*sourceEntry) (err error) {
+	wanted := make(map[string]struct{})
+	for _, entry := range binaries {
+		dst := filepath.Join(b.Repository, entry.Filename)
+		if err = copyIntoPool(entry.Path, dst); err != nil {
+			return
+		}
+		wanted[dst] = struct{}{}
+	}
+	for _, entry := range sources {
+		for _, src := range entry.Paths {
+			dst := filepath.Join(b.Repository, entry.Directory, filepath.Base(src))
+			if err = copyIntoPool(src, dst); err != nil {
+				return
+			}
+			wanted[dst] = struct{}{}
+		}
+	}
+	if b.Retain > 0 {
+		err = prunePool(filepath.Join(b.Repository, "pool"), wanted)
+	}
+	return
+}
+
+// prunePool removes all files within the pool that are not wanted, along
+// with any directories left empty
+func prunePool(pool string, wanted map[string]struct{}) (err error) {
+	var dirs []string
+	err = filepath.Walk(pool, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if info.IsDir() {
+			dirs = append(dirs, path)
+			return nil
+		} else if _, present := wanted[path]; present {
+			return nil
+		}
+		if ee := os.Remove(path); ee != nil {
+			return ee
+		}
+		log.DebugF("removed from pool: %v", path)
+		return nil
+	})
+	for idx := len(dirs) - 1; idx > 0; idx-- {
+		// only succeeds when empty
+		_ = os.Remove(dirs[idx])
+	}
+	return
+}
+
+// includeBinary returns the Packages index entry for the .deb
+func (b *Builder) includeBinary(component, path string) (entry *binaryEntry, err error) {
+	var pkg *deb.Package
+	if pkg, err = deb.ReadControl(path); err != nil {
+		return
+	}
+	var ctrl *control.Paragraph
+	if ctrl, err = control.ParseParagraph(string(pkg.Control())); err != nil {
+		return
+	}
+
+	name := ctrl.Value("Package")
+	source := name
+	if v := ctrl.Value("Source"); v != "" {
+		source, _, _ = strings.Cut(v, " ")
+	}
+
+	filename := PoolDirectory(component, source) + "/" + filepath.Base(path)
+
+	var sums *checksums.Sums
+	if sums, err = checksums.HashFile(path); err != nil {
+		return
+	}
+
+	entry = &binaryEntry{
+		Component: component,
+		Filename:  filename,
+		Control:   ctrl.Copy(),
+		Path:      path,
+	}
+	entry.Control.Set("Filename", filename)
+	entry.Control.Set("Size", strconv.FormatInt(sums.Size, 10))
+	entry.Control.Set("MD5sum", sums.MD5)
+	entry.Control.Set("SHA1", sums.SHA1)
+	entry.Control.Set("SHA256", sums.SHA256)
+	return
+}
+
+// includeSource returns the Sources index entry for the .dsc
+func (b *Builder) includeSource(component, path string) (entry *sourceEntry, err error) {
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	var dsc *control.Paragraph
+	if dsc, err = control.ParseParagraph(string(data)); err != nil {
+		return
+	}
+
+	name := dsc.Value("Source")
+	directory := PoolDirectory(component, name)
+
+	var sums *checksums.Sums
+	if sums, err = checksums.HashFile(path); err != nil {
+		return
+	}
+
+	srcDir := filepath.Dir(path)
+	files := []string{filepath.Base(path)}
+	for _, line := range dsc.Lines("Files") {
+		if fields := strings.Fields(line); len(fields) == 3 {
+			files = append(files, fields[2])
+		}
+	}
+
+	entry = &sourceEntry{
+		Component: component,
+		Directory: directory,
+		Control:   control.NewParagraph(),
+	}
+	for _, file := range files {
+		entry.Paths = append(entry.Paths, filepath.Join(srcDir, file))
+	}
+	entry.Control.Add("Package", name)
+	for _, field := range dsc.Fields {
+		switch field.Name {
+		case "Source":
+			continue
+		case "Files":
+			entry.Control.Add(field.Name, appendChecksumLine(field.Value, sums.MD5, sums.Size, files[0]))
+		case "Checksums-Sha1":
+			entry.Control.Add(field.Name, appendChecksumLine(field.Value, sums.SHA1, sums.Size, files[0]))
+		case "Checksums-Sha256":
+			entry.Control.Add(field.Name, appendChecksumLine(field.Value, sums.SHA256, sums.Size, files[0]))
+		default:
+			entry.Control.Add(field.Name, field.Value)
+		}
+	}
+	entry.Control.Set("Directory", directory)
+	return
+}
+
+// PoolDirectory returns the conventional pool/ directory for the given
+// component and source package name
+func PoolDirectory(component, source string) (directory string) {
+	prefix := source
+	if strings.HasPrefix(source, "lib") && len(source) > 3 {
+		prefix = source[:4]
+	} else if len(source) > 0 {
+		prefix = source[:1]
+	}
+	directory = "pool/" + component + "/" + prefix + "/" + source
+	return
+}
+
+func appendChecksumLine(value, sum string, size int64, name string) (modified string) {
+	modified = fmt.Sprintf(" %s %d %s\n", sum, size, name) + strings.TrimLeft(value, "\n")
+	modified = "\n" + strings.TrimRight(modified, "\n")
+	return
+}
+
+// copyIntoPool hard links, or copies, the src file to the dst within the pool,
+// replacing any existing dst which differs in size or modification time from
+// the src, such as when an archive is rebuilt with the same file name
+func copyIntoPool(src, dst string) (err error) {
+	var srcInfo, dstInfo os.FileInfo
+	if srcInfo, err = os.Stat(src); err != nil {
+		return
+	}
+	if dstInfo, err = os.Stat(dst); err == nil {
+		if os.SameFile(srcInfo, dstInfo) {
+			return
+		} else if dstInfo.Size() == srcInfo.Size() && dstInfo.ModTime().Equal(srcInfo.ModTime()) {
+			return
+		} else if err = os.Remove(dst); err != nil {
+			err = fmt.Errorf("error removing outdated pool file: %v - %w", dst, err)
+			return
+		}
+		log.InfoF("replacing outdated pool file: %v", dst)
+	}
+	if err = os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return
+	}
+	if err = os.Link(src, dst); err == nil {
+		log.DebugF("linked into pool: %v", dst)
+		return
+	}
+	var data []byte
+	if data, err = os.ReadFile(src); err != nil {
+		return
+	}
+	tmp := dst + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	// the copy has the modification time of the src for the checks above
+	if err = os.Chtimes(tmp, srcInfo.ModTime(), srcInfo.ModTime()); err == nil {
+		err = os.Rename(tmp, dst)
+	}
+	if err != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	log.DebugF("copied into pool: %v", dst)
+	return
+}
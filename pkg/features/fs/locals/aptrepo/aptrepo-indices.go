@@ -0,0 +1,158 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aptrepo
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/checksums"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+// indexFile is a single file listed within a Release file
+type indexFile struct {
+	Name string
+	Sums *checksums.Sums
+}
+
+// writeDistribution writes all the Packages, Sources and Release files for
+// the given distribution
+func (b *Builder) writeDistribution(dist *Distribution, binaries []*binaryEntry, sources []*sourceEntry) (changed bool, err error) {
+	distPath := filepath.Join(b.Repository, "dists", dist.Codename)
+
+	var indices []*indexFile
+	write := func(name string, data []byte) (err error) {
+		var modified bool
+		if modified, err = writeIndex(distPath, name, data, &indices); err != nil {
+			return
+		}
+		changed = changed || modified
+		return
+	}
+
+	for _, component := range dist.Components {
+
+		for _, arch := range dist.BinaryArchitectures() {
+			var paragraphs []*control.Paragraph
+			for _, entry := range binaries {
+				if entry.Component != component {
+					continue
+				}
+				if pa := entry.Control.Value("Architecture"); pa == arch || pa == "all" {
+					paragraphs = append(paragraphs, entry.Control)
+				}
+			}
+			dir := component + "/binary-" + arch
+			if err = write(dir+"/Packages", formatIndex(paragraphs)); err != nil {
+				return
+			}
+			if err = write(dir+"/Release", b.formatComponentRelease(dist, component, arch)); err != nil {
+				return
+			}
+		}
+
+		if dist.HasSources() {
+			var paragraphs []*control.Paragraph
+			for _, entry := range sources {
+				if entry.Component == component {
+					paragraphs = append(paragraphs, entry.Control)
+				}
+			}
+			dir := component + "/source"
+			if err = write(dir+"/Sources", formatIndex(paragraphs)); err != nil {
+				return
+			}
+			if err = write(dir+"/Release", b.formatComponentRelease(dist, component, "source")); err != nil {
+				return
+			}
+		}
+	}
+
+	var modified bool
+	if modified, err = b.writeRelease(dist, distPath, indices); err != nil {
+		return
+	}
+	changed = changed || modified
+	return
+}
+
+// writeIndex writes the uncompressed data and, for Packages and Sources, the
+// .gz and .xz variants, appending each to the indices list
+func writeIndex(distPath, name string, data []byte, indices *[]*indexFile) (changed bool, err error) {
+	variants := map[string][]byte{name: data}
+	if base := filepath.Base(name); base == "Packages" || base == "Sources" {
+		if variants[name+".gz"], err = gzipData(data); err != nil {
+			return
+		}
+		if variants[name+".xz"], err = xzData(data); err != nil {
+			return
+		}
+	}
+
+	for _, variant := range []string{name, name + ".gz", name + ".xz"} {
+		content, present := variants[variant]
+		if !present {
+			continue
+		}
+		// compressed variants are only rewritten when the source changes,
+		// keeping the checksums stable between builds
+		var modified bool
+		if variant == name {
+			if modified, err = writeFileIfChanged(filepath.Join(distPath, variant), content); err != nil {
+				return
+			}
+			changed = modified
+		} else if changed || !fileExists(filepath.Join(distPath, variant)) {
+			if _, err = writeFileIfChanged(filepath.Join(distPath, variant), content); err != nil {
+				return
+			}
+		}
+
+		var sums *checksums.Sums
+		if sums, err = checksums.HashFile(filepath.Join(distPath, variant)); err != nil {
+			err = fmt.Errorf("error hashing %v: %w", variant, err)
+			return
+		}
+		*indices = append(*indices, &indexFile{Name: variant, Sums: sums})
+	}
+	return
+}
+
+func formatIndex(paragraphs []*control.Paragraph) (data []byte) {
+	var buf bytes.Buffer
+	for _, p := range paragraphs {
+		buf.WriteString(p.String())
+		buf.WriteString("\n")
+	}
+	data = buf.Bytes()
+	return
+}
+
+func (b *Builder) formatComponentRelease(dist *Distribution, component, arch string) (data []byte) {
+	p := control.NewParagraph()
+	p.Add("Archive", dist.SuiteName())
+	if b.Origin != "" {
+		p.Add("Origin", b.Origin)
+	}
+	if b.Label != "" {
+		p.Add("Label", b.Label)
+	}
+	p.Add("Component", component)
+	p.Add("Architecture", arch)
+	data = []byte(p.String())
+	return
+}
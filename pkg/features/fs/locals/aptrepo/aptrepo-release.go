@@ -0,0 +1,76 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aptrepo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/checksums"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+// writeRelease writes the top-level Release file for the distribution, the
+// Date field is only updated when any of the listed indices have changed
+func (b *Builder) writeRelease(dist *Distribution, distPath string, indices []*indexFile) (changed bool, err error) {
+	releasePath := filepath.Join(distPath, "Release")
+
+	p := control.NewParagraph()
+	if b.Origin != "" {
+		p.Add("Origin", b.Origin)
+	}
+	if b.Label != "" {
+		p.Add("Label", b.Label)
+	}
+	p.Add("Suite", dist.SuiteName())
+	p.Add("Codename", dist.Codename)
+	p.Add("Date", time.Now().UTC().Format(time.RFC1123Z))
+	p.Add("Architectures", strings.Join(dist.BinaryArchitectures(), " "))
+	p.Add("Components", strings.Join(dist.Components, " "))
+	if b.Description != "" {
+		p.Add("Description", b.Description)
+	}
+
+	for _, digest := range []struct {
+		name string
+		get  func(sums *checksums.Sums) string
+	}{
+		{"MD5Sum", func(sums *checksums.Sums) string { return sums.MD5 }},
+		{"SHA1", func(sums *checksums.Sums) string { return sums.SHA1 }},
+		{"SHA256", func(sums *checksums.Sums) string { return sums.SHA256 }},
+		{"SHA512", func(sums *checksums.Sums) string { return sums.SHA512 }},
+	} {
+		var lines []string
+		for _, index := range indices {
+			lines = append(lines, fmt.Sprintf(" %s %16d %s", digest.get(index.Sums), index.Sums.Size, index.Name))
+		}
+		p.Add(digest.name, "\n"+strings.Join(lines, "\n"))
+	}
+
+	if existing, ee := os.ReadFile(releasePath); ee == nil {
+		if prev, ee := control.ParseParagraph(string(existing)); ee == nil {
+			prev.Set("Date", p.Value("Date"))
+			if prev.String() == p.String() {
+				return
+			}
+		}
+	}
+
+	changed, err = writeFileIfChanged(releasePath, []byte(p.String()))
+	return
+}
@@ -0,0 +1,147 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aptrepo
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/clearsign"
+	"github.com/ProtonMail/go-crypto/openpgp/packet"
+)
+
+// LoadSigningKey parses the armored, or binary, OpenPGP keyring data and
+// returns the entity with a private key matching the given key id, email
+// address or fingerprint; when keyId is empty, the first private key found is
+// returned
+func LoadSigningKey(data []byte, keyId string) (entity *openpgp.Entity, err error) {
+	var keyring openpgp.EntityList
+	if keyring, err = openpgp.ReadArmoredKeyRing(bytes.NewReader(data)); err != nil {
+		if keyring, err = openpgp.ReadKeyRing(bytes.NewReader(data)); err != nil {
+			err = fmt.Errorf("error reading keyring: %w", err)
+			return
+		}
+	}
+
+	for _, e := range keyring {
+		if e.PrivateKey == nil || !matchSigningKey(e, keyId) {
+			continue
+		}
+		if _, err = releaseSigningKey(e); err != nil {
+			return
+		}
+		entity = e
+		return
+	}
+
+	if keyId == "" {
+		err = fmt.Errorf("private key not found")
+	} else {
+		err = fmt.Errorf("private key not found: %v", keyId)
+	}
+	return
+}
+
+func matchSigningKey(e *openpgp.Entity, keyId string) (matched bool) {
+	if keyId = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(keyId)), "0X"); keyId == "" {
+		return true
+	}
+	if fingerprint := fmt.Sprintf("%X", e.PrimaryKey.Fingerprint); strings.HasSuffix(fingerprint, keyId) {
+		return true
+	}
+	for _, identity := range e.Identities {
+		if identity.UserId != nil && strings.EqualFold(identity.UserId.Email, keyId) {
+			return true
+		}
+	}
+	return
+}
+
+// releaseSigningKey returns the key of the entity used to sign Release files,
+// which is the newest signing subkey or otherwise the primary key
+func releaseSigningKey(entity *openpgp.Entity) (key openpgp.Key, err error) {
+	var ok bool
+	if key, ok = entity.SigningKey(time.Now()); !ok {
+		err = fmt.Errorf("no valid signing key: %X", entity.PrimaryKey.Fingerprint)
+	} else if key.PrivateKey == nil {
+		err = fmt.Errorf("signing key has no private key: %X", key.PublicKey.Fingerprint)
+	} else if key.PrivateKey.Encrypted {
+		err = fmt.Errorf("passphrase protected signing keys are not supported: %X", key.PublicKey.Fingerprint)
+	}
+	return
+}
+
+// signRelease writes the clearsigned InRelease and detached Release.gpg
+// signatures for the Release file within distPath
+func signRelease(entity *openpgp.Entity, distPath string) (err error) {
+	var release []byte
+	if release, err = os.ReadFile(filepath.Join(distPath, "Release")); err != nil {
+		return
+	}
+
+	// both signatures are made by the same key
+	var key openpgp.Key
+	if key, err = releaseSigningKey(entity); err != nil {
+		return
+	}
+	config := &packet.Config{SigningKeyId: key.PublicKey.KeyId}
+
+	var inRelease bytes.Buffer
+	var w io.WriteCloser
+	if w, err = clearsign.Encode(&inRelease, key.PrivateKey, config); err != nil {
+		err = fmt.Errorf("error clearsigning release: %w", err)
+		return
+	}
+	if _, err = w.Write(release); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+
+	var detached bytes.Buffer
+	if err = openpgp.ArmoredDetachSign(&detached, entity, bytes.NewReader(release), config); err != nil {
+		err = fmt.Errorf("error signing release: %w", err)
+		return
+	}
+	detached.WriteString("\n")
+
+	if _, err = writeFileIfChanged(filepath.Join(distPath, "InRelease"), inRelease.Bytes()); err != nil {
+		return
+	}
+	_, err = writeFileIfChanged(filepath.Join(distPath, "Release.gpg"), detached.Bytes())
+	return
+}
+
+// needsSigning returns true if either of the InRelease or Release.gpg files
+// are missing or older than the Release file
+func needsSigning(distPath string) (stale bool) {
+	release, err := os.Stat(filepath.Join(distPath, "Release"))
+	if err != nil {
+		return
+	}
+	for _, name := range []string{"InRelease", "Release.gpg"} {
+		if info, ee := os.Stat(filepath.Join(distPath, name)); ee != nil || info.ModTime().Before(release.ModTime()) {
+			return true
+		}
+	}
+	return
+}
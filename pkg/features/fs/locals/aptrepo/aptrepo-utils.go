@@ -0,0 +1,83 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aptrepo
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+
+	"github.com/ulikunitz/xz"
+)
+
+func gzipData(data []byte) (compressed []byte, err error) {
+	var buf bytes.Buffer
+	var w *gzip.Writer
+	if w, err = gzip.NewWriterLevel(&buf, gzip.BestCompression); err != nil {
+		return
+	}
+	if _, err = w.Write(data); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	compressed = buf.Bytes()
+	return
+}
+
+func xzData(data []byte) (compressed []byte, err error) {
+	var buf bytes.Buffer
+	var w *xz.Writer
+	if w, err = xz.NewWriter(&buf); err != nil {
+		return
+	}
+	if _, err = w.Write(data); err != nil {
+		return
+	}
+	if err = w.Close(); err != nil {
+		return
+	}
+	compressed = buf.Bytes()
+	return
+}
+
+// writeFileIfChanged atomically replaces the file at path with data, unless
+// the file already has the same contents
+func writeFileIfChanged(path string, data []byte) (changed bool, err error) {
+	if existing, ee := os.ReadFile(path); ee == nil && bytes.Equal(existing, data) {
+		return
+	}
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return
+	}
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return
+	}
+	changed = true
+	return
+}
+
+func fileExists(path string) (exists bool) {
+	_, err := os.Stat(path)
+	exists = err == nil
+	return
+}
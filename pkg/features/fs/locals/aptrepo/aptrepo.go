@@ -0,0 +1,289 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package aptrepo
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+)
+
+var (
+	_ Feature     = (*CFeature)(nil)
+	_ MakeFeature = (*CFeature)(nil)
+)
+
+var (
+	DefaultCheckInterval = time.Minute
+)
+
+const Tag feature.Tag = "local-apt-repo"
+
+type Feature interface {
+	feature.Feature
+
+	// Rebuild scans the archives path and updates the repository indices
+	Rebuild() (changed bool, err error)
+}
+
+type MakeFeature interface {
+	// SetArchivesPath specifies the directory of .deb, .udeb and .dsc files to
+	// include in the repository, any sub-directories named after a component
+	// are included in that component, all others are included in the first
+	// component of each distribution
+	SetArchivesPath(path string) MakeFeature
+	// SetRepositoryPath specifies the directory to write pool/ and dists/
+	SetRepositoryPath(path string) MakeFeature
+	// SetOrigin specifies the Release Origin field
+	SetOrigin(origin string) MakeFeature
+	// SetLabel specifies the Release Label field
+	SetLabel(label string) MakeFeature
+	// SetDescription specifies the Release Description field
+	SetDescription(description string) MakeFeature
+	// AddDistribution adds a codename to the repository, the "source"
+	// architecture enables the generation of Sources indices
+	AddDistribution(codename string, components, architectures []string) MakeFeature
+	// SetSuite specifies the suite alias, such as "stable" or "testing", of
+	// the named distribution, a suite may only alias one codename
+	SetSuite(codename, suite string) MakeFeature
+	// SetSigningKey specifies the armored, or binary, OpenPGP private key data
+	// used to sign Release files, keyId selects a specific key by id, email
+	// address or fingerprint
+	SetSigningKey(data []byte, keyId string) MakeFeature
+	// SetSigningKeyFile is a convenience wrapper around SetSigningKey which
+	// reads the key data from the given file path
+	SetSigningKeyFile(path string, keyId string) MakeFeature
+	// SetCheckInterval specifies how often to rebuild the repository from the
+	// archives path and to check for Release files which need to be re-signed,
+	// a zero duration disables the periodic check
+	SetCheckInterval(interval time.Duration) MakeFeature
+	// SetRetainVersions specifies the number of newest versions of each
+	// package to keep in the repository, older versions are removed from the
+	// pool. Zero keeps all versions
+	SetRetainVersions(count int) MakeFeature
+
+	Make() Feature
+}
+
+type CFeature struct {
+	feature.CFeature
+
+	builder *Builder
+	suites  map[string]string
+
+	signingKey    []byte
+	signingKeyId  string
+	signingFile   string
+	checkInterval time.Duration
+	stop          chan struct{}
+}
+
+func New() MakeFeature {
+	return NewTagged(Tag)
+}
+
+func NewTagged(tag feature.Tag) MakeFeature {
+	f := new(CFeature)
+	f.Init(f)
+	f.PackageTag = Tag
+	f.FeatureTag = tag
+	f.CFeature.Construct(f)
+	return f
+}
+
+func (f *CFeature) Init(this interface{}) {
+	f.CFeature.Init(this)
+	f.builder = NewBuilder()
+	f.suites = make(map[string]string)
+	f.checkInterval = DefaultCheckInterval
+}
+
+func (f *CFeature) SetArchivesPath(path string) MakeFeature {
+	f.builder.Archives = path
+	return f
+}
+
+func (f *CFeature) SetRepositoryPath(path string) MakeFeature {
+	f.builder.Repository = path
+	return f
+}
+
+func (f *CFeature) SetOrigin(origin string) MakeFeature {
+	f.builder.Origin = origin
+	return f
+}
+
+func (f *CFeature) SetLabel(label string) MakeFeature {
+	f.builder.Label = label
+	return f
+}
+
+func (f *CFeature) SetDescription(description string) MakeFeature {
+	f.builder.Description = description
+	return f
+}
+
+func (f *CFeature) AddDistribution(codename string, components, architectures []string) MakeFeature {
+	f.builder.Distributions = append(f.builder.Distributions, &Distribution{
+		Codename:      codename,
+		Components:    components,
+		Architectures: architectures,
+	})
+	return f
+}
+
+func (f *CFeature) SetSuite(codename, suite string) MakeFeature {
+	f.suites[codename] = suite
+	return f
+}
+
+func (f *CFeature) SetSigningKey(data []byte, keyId string) MakeFeature {
+	f.signingKey = data
+	f.signingKeyId = keyId
+	return f
+}
+
+func (f *CFeature) SetSigningKeyFile(path string, keyId string) MakeFeature {
+	f.signingFile = path
+	f.signingKeyId = keyId
+	return f
+}
+
+func (f *CFeature) SetCheckInterval(interval time.Duration) MakeFeature {
+	f.checkInterval = interval
+	return f
+}
+
+func (f *CFeature) SetRetainVersions(count int) MakeFeature {
+	f.builder.Retain = count
+	return f
+}
+
+func (f *CFeature) Make() Feature {
+	return f
+}
+
+func (f *CFeature) Build(_ feature.Buildable) (err error) {
+	return
+}
+
+func (f *CFeature) Setup(enjin feature.Internals) {
+	f.CFeature.Setup(enjin)
+	if f.builder.Archives == "" || f.builder.Repository == "" {
+		log.FatalF("%v feature requires both archives and repository paths", f.Tag())
+	} else if len(f.builder.Distributions) == 0 {
+		log.FatalF("%v feature requires at least one distribution", f.Tag())
+	}
+	// each codename and suite is a dists/ directory, or symlink, and must
+	// name only one distribution
+	names := make(map[string]string)
+	for _, dist := range f.builder.Distributions {
+		dist.Suite = f.suites[dist.Codename]
+		names[dist.Codename] = dist.Codename
+	}
+	for codename, suite := range f.suites {
+		if _, present := names[codename]; !present {
+			log.FatalF("%v feature suite %v names an unknown distribution: %v", f.Tag(), suite, codename)
+			return
+		}
+	}
+	for _, dist := range f.builder.Distributions {
+		for _, name := range []string{dist.Codename, dist.Suite} {
+			if name == "" {
+				continue
+			} else if other, present := names[name]; present && other != dist.Codename {
+				log.FatalF("%v feature distribution name %v is used by both %v and %v", f.Tag(), name, other, dist.Codename)
+				return
+			}
+			names[name] = dist.Codename
+		}
+	}
+
+	if f.signingFile != "" && len(f.signingKey) == 0 {
+		var err error
+		if f.signingKey, err = os.ReadFile(f.signingFile); err != nil {
+			log.FatalF("error reading signing key file: %v - %v", f.signingFile, err)
+			return
+		}
+	}
+	if len(f.signingKey) > 0 {
+		var err error
+		if f.builder.Signer, err = LoadSigningKey(f.signingKey, f.signingKeyId); err != nil {
+			log.FatalF("error loading signing key: %v", err)
+			return
+		}
+		log.DebugF("loaded apt repository signing key: %X", f.builder.Signer.PrimaryKey.Fingerprint)
+	}
+}
+
+func (f *CFeature) Startup(ctx *cli.Context) (err error) {
+	if err = f.CFeature.Startup(ctx); err != nil {
+		return
+	}
+	if _, err = f.Rebuild(); err != nil {
+		err = fmt.Errorf("error building apt repository: %w", err)
+		return
+	}
+
+	if f.checkInterval > 0 {
+		f.stop = make(chan struct{})
+		go f.watchArchives(f.stop)
+	}
+	return
+}
+
+func (f *CFeature) Shutdown() {
+	if f.stop != nil {
+		close(f.stop)
+		f.stop = nil
+	}
+	f.CFeature.Shutdown()
+}
+
+// watchArchives periodically rebuilds the repository, publishing any packages
+// added to the archives path since startup, and re-signs any Release files
+// updated outside of Rebuild, for example by reprepro
+func (f *CFeature) watchArchives(stop chan struct{}) {
+	ticker := time.NewTicker(f.checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if _, err := f.Rebuild(); err != nil {
+				log.ErrorF("error rebuilding apt repository: %v", err)
+			}
+		}
+	}
+}
+
+func (f *CFeature) Rebuild() (changed bool, err error) {
+	f.Lock()
+	defer f.Unlock()
+	if changed, err = f.builder.Build(); err == nil {
+		if changed {
+			log.InfoF("updated apt repository indices: %v", f.builder.Repository)
+		} else {
+			log.DebugF("apt repository indices unchanged: %v", f.builder.Repository)
+		}
+	}
+	return
+}
@@ -0,0 +1,503 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/forms"
+	"github.com/go-enjin/be/pkg/log"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/checksums"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/lint"
+)
+
+var (
+	DefaultApiPerPage = 50
+	MaximumApiPerPage = 500
+)
+
+type apiError struct {
+	Error string `json:"error"`
+}
+
+type apiStats struct {
+	Packages          int            `json:"packages"`
+	IntegrityFailures int            `json:"integrity_failures"`
+	Uninstallable     int            `json:"uninstallable"`
+	PageCache         PageCacheStats `json:"page_cache"`
+}
+
+type apiPagination struct {
+	Page    int `json:"page"`
+	PerPage int `json:"per_page"`
+	Total   int `json:"total"`
+	Pages   int `json:"pages"`
+}
+
+type apiPackageSummary struct {
+	Mount        string `json:"mount"`
+	Name         string `json:"name"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture"`
+	Component    string `json:"component,omitempty"`
+	Section      string `json:"section,omitempty"`
+	Synopsis     string `json:"synopsis,omitempty"`
+	Filename     string `json:"filename"`
+	Size         int64  `json:"size"`
+	Url          string `json:"url"`
+}
+
+type apiPackage struct {
+	apiPackageSummary
+	Checksums *checksums.Sums   `json:"checksums,omitempty"`
+	Control   map[string]string `json:"control"`
+	// Licenses is the machine-readable copyright license summary, when not
+	// available the CopyrightIssue explains why
+	Licenses       []string `json:"licenses,omitempty"`
+	CopyrightIssue string   `json:"copyright_issue,omitempty"`
+	// MaintainerScripts are the names of the scripts dpkg runs as root
+	MaintainerScripts []string `json:"maintainer_scripts,omitempty"`
+	// IntegrityIssues are the problems found verifying the package
+	IntegrityIssues []string `json:"integrity_issues,omitempty"`
+	// Lint are the findings of the lint rules
+	Lint []*lint.Finding `json:"lint,omitempty"`
+	// Relations are the parsed relationship fields, keyed by field name
+	Relations map[string]control.Relations `json:"relations,omitempty"`
+	// InstallabilityIssues are the unmet dependencies of the package, per
+	// codename and architecture
+	InstallabilityIssues []string `json:"installability_issues,omitempty"`
+	// Published are the distribution components listing the package file
+	Published []*debPublication `json:"published,omitempty"`
+}
+
+type apiHealth struct {
+	Reports []*healthReport `json:"reports"`
+}
+
+type apiMountDists struct {
+	Mount string         `json:"mount"`
+	Dists []*distRelease `json:"dists"`
+}
+
+type apiDistributions struct {
+	Mounts []*apiMountDists `json:"mounts"`
+}
+
+type apiIntegrityFailure struct {
+	apiPackageSummary
+	Issues []string `json:"issues"`
+}
+
+type apiIntegrityList struct {
+	apiPagination
+	Packages []*apiIntegrityFailure `json:"packages"`
+}
+
+type apiLintReport struct {
+	apiPackageSummary
+	Findings []*lint.Finding `json:"findings"`
+}
+
+type apiLintList struct {
+	apiPagination
+	Packages []*apiLintReport `json:"packages"`
+}
+
+type apiPackageList struct {
+	apiPagination
+	Packages []*apiPackageSummary `json:"packages"`
+}
+
+type apiPackageVersions struct {
+	Name     string        `json:"name"`
+	Version  string        `json:"version,omitempty"`
+	Packages []*apiPackage `json:"packages"`
+}
+
+type apiFile struct {
+	Path     string    `json:"path"`
+	Type     string    `json:"type"`
+	Mode     string    `json:"mode"`
+	Owner    string    `json:"owner"`
+	Group    string    `json:"group"`
+	Size     int64     `json:"size"`
+	Linkname string    `json:"linkname,omitempty"`
+	ModTime  time.Time `json:"mtime"`
+}
+
+type apiFileList struct {
+	apiPagination
+	Name         string     `json:"name"`
+	Version      string     `json:"version"`
+	Architecture string     `json:"architecture"`
+	Files        []*apiFile `json:"files"`
+}
+
+func newApiPackageSummary(dd *dpkgDeb) (s *apiPackageSummary) {
+	s = &apiPackageSummary{
+		Mount:        dd.MP.Mount,
+		Name:         dd.Name,
+		Version:      dd.Version,
+		Architecture: dd.Architecture,
+		Component:    dd.Component,
+		Section:      dd.Control.Value("Section"),
+		Synopsis:     dd.Control.Synopsis(),
+		Filename:     strings.TrimPrefix(filepath.ToSlash(dd.File), "/"),
+		Size:         dd.Size,
+		Url:          dd.Url,
+	}
+	return
+}
+
+func newApiPackage(dd *dpkgDeb) (p *apiPackage) {
+	p = &apiPackage{
+		apiPackageSummary: *newApiPackageSummary(dd),
+		Checksums:         dd.Sums,
+		Control:           make(map[string]string),
+	}
+	for _, name := range dd.Control.Names() {
+		p.Control[name] = dd.Control.Value(name)
+	}
+	if dd.Copyright != nil {
+		p.Licenses = dd.Copyright.Summary()
+	}
+	p.CopyrightIssue = dd.CopyrightIssue()
+	p.MaintainerScripts = dd.MaintainerScripts()
+	p.IntegrityIssues = dd.IntegrityIssues()
+	p.Lint = dd.Lint
+	for _, field := range gRelationFields {
+		if relations := relationsOf(dd, field); len(relations) > 0 {
+			if p.Relations == nil {
+				p.Relations = make(map[string]control.Relations)
+			}
+			p.Relations[field] = relations
+		}
+	}
+	return
+}
+
+func newApiFile(file *deb.File) (af *apiFile) {
+	af = &apiFile{
+		Path:     deb.CleanName(file.Name),
+		Mode:     deb.ModeString(file.Type, file.Mode),
+		Owner:    file.Uname,
+		Group:    file.Gname,
+		Size:     file.Size,
+		Linkname: file.Linkname,
+		ModTime:  file.ModTime,
+	}
+	switch {
+	case file.IsDir():
+		af.Type = "directory"
+	case file.IsSymlink():
+		af.Type = "symlink"
+	case file.IsRegular():
+		af.Type = "file"
+	default:
+		af.Type = "other"
+	}
+	return
+}
+
+// paginate returns the bounds of the requested page of a list with total
+// entries, using the page and per_page query parameters
+func paginate(r *http.Request, total int) (p apiPagination, start, end int, err error) {
+	p = apiPagination{Page: 1, PerPage: DefaultApiPerPage, Total: total}
+	query := r.URL.Query()
+	if value := query.Get("page"); value != "" {
+		if p.Page, err = strconv.Atoi(value); err != nil || p.Page < 1 {
+			err = fmt.Errorf("invalid page: %q", value)
+			return
+		}
+	}
+	if value := query.Get("per_page"); value != "" {
+		if p.PerPage, err = strconv.Atoi(value); err != nil || p.PerPage < 1 || p.PerPage > MaximumApiPerPage {
+			err = fmt.Errorf("invalid per_page: %q, must be 1-%d", value, MaximumApiPerPage)
+			return
+		}
+	}
+	p.Pages = (total + p.PerPage - 1) / p.PerPage
+	if start = (p.Page - 1) * p.PerPage; start > total {
+		start = total
+	}
+	if end = start + p.PerPage; end > total {
+		end = total
+	}
+	return
+}
+
+func (f *CFeature) apiQuery(r *http.Request) (q packageQuery) {
+	query := r.URL.Query()
+	q = packageQuery{
+		Architecture: query.Get("arch"),
+		Component:    query.Get("component"),
+		Section:      query.Get("section"),
+		Search:       query.Get("q"),
+	}
+	if mount := query.Get("mount"); mount != "" {
+		// an unknown mount path matches no packages
+		if q.MP = f.findMountPoint(forms.CleanRequestPath(mount)); q.MP == nil {
+			q.MP = &feature.CMountPoint{Mount: mount}
+		}
+	}
+	return
+}
+
+// ServeApi handles all requests for paths within the api path, returning
+// false for any other path
+func (f *CFeature) ServeApi(path string, w http.ResponseWriter, r *http.Request) (handled bool) {
+	if f.apiPath == "" {
+		return
+	}
+	rest, ok := strings.CutPrefix(path, f.apiPath+"/")
+	if !ok {
+		return
+	}
+	handled = true
+
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		f.serveApiError(http.StatusMethodNotAllowed, "method not allowed", w, r)
+		return
+	}
+
+	var route []string
+	switch rest {
+	case "stats":
+		f.serveApiJSON(&apiStats{
+			Packages:          f.store.Len(),
+			IntegrityFailures: len(f.integrityFailures(f.store.List())),
+			Uninstallable:     f.uninstallable(),
+			PageCache:         f.pages.Stats(),
+		}, w, r)
+		return
+	case "integrity":
+		f.serveApiIntegrity(w, r)
+		return
+	case "health":
+		f.serveApiHealth(w, r)
+		return
+	case "distributions":
+		f.serveApiDistributions(w, r)
+		return
+	case "lint":
+		f.serveApiLint(w, r)
+		return
+	case "packages":
+		route = []string{}
+	default:
+		// the path is already unescaped, versions with epochs may be requested
+		// with the colon either literal or percent-encoded
+		if rest, ok = strings.CutPrefix(rest, "packages/"); !ok {
+			f.serveApiError(http.StatusNotFound, "not found", w, r)
+			return
+		}
+		route = strings.Split(rest, "/")
+	}
+
+	switch len(route) {
+	case 0:
+		f.serveApiPackages(w, r)
+	case 1:
+		f.serveApiPackage(route[0], "", w, r)
+	case 2:
+		f.serveApiPackage(route[0], route[1], w, r)
+	case 3:
+		if route[2] == "files" {
+			f.serveApiFiles(route[0], route[1], w, r)
+			return
+		}
+		fallthrough
+	default:
+		f.serveApiError(http.StatusNotFound, "not found", w, r)
+	}
+	return
+}
+
+func (f *CFeature) serveApiError(status int, message string, w http.ResponseWriter, r *http.Request) {
+	if err := f.Enjin.ServeStatusJSON(status, &apiError{Error: message}, w, r); err != nil {
+		log.ErrorF("error serving api error: %v", err)
+	}
+}
+
+func (f *CFeature) serveApiJSON(v interface{}, w http.ResponseWriter, r *http.Request) {
+	if err := f.Enjin.ServeJSON(v, w, r); err != nil {
+		log.ErrorF("error serving api response: %v - %v", r.URL.Path, err)
+	}
+}
+
+func (f *CFeature) serveApiPackages(w http.ResponseWriter, r *http.Request) {
+	list := f.store.Query(f.apiQuery(r))
+	pagination, start, end, err := paginate(r, len(list))
+	if err != nil {
+		f.serveApiError(http.StatusBadRequest, err.Error(), w, r)
+		return
+	}
+	response := &apiPackageList{apiPagination: pagination, Packages: make([]*apiPackageSummary, 0, end-start)}
+	for _, dd := range list[start:end] {
+		response.Packages = append(response.Packages, newApiPackageSummary(dd))
+	}
+	f.serveApiJSON(response, w, r)
+}
+
+// integrityFailures returns the packages with integrity issues
+func (f *CFeature) integrityFailures(list []*dpkgDeb) (failures []*dpkgDeb) {
+	for _, dd := range list {
+		if len(dd.IntegrityIssues()) > 0 {
+			failures = append(failures, dd)
+		}
+	}
+	return
+}
+
+func (f *CFeature) serveApiIntegrity(w http.ResponseWriter, r *http.Request) {
+	list := f.integrityFailures(f.store.Query(f.apiQuery(r)))
+	pagination, start, end, err := paginate(r, len(list))
+	if err != nil {
+		f.serveApiError(http.StatusBadRequest, err.Error(), w, r)
+		return
+	}
+	response := &apiIntegrityList{apiPagination: pagination, Packages: make([]*apiIntegrityFailure, 0, end-start)}
+	for _, dd := range list[start:end] {
+		response.Packages = append(response.Packages, &apiIntegrityFailure{
+			apiPackageSummary: *newApiPackageSummary(dd),
+			Issues:            dd.IntegrityIssues(),
+		})
+	}
+	f.serveApiJSON(response, w, r)
+}
+
+// uninstallable returns the number of uninstallable package entries of all
+// mount points
+func (f *CFeature) uninstallable() (count int) {
+	for _, mp := range f.mount {
+		if report := f.healthReport(mp); report != nil {
+			count += report.Uninstallable()
+		}
+	}
+	return
+}
+
+func (f *CFeature) serveApiHealth(w http.ResponseWriter, r *http.Request) {
+	response := &apiHealth{Reports: make([]*healthReport, 0, len(f.mount))}
+	for _, mp := range f.mount {
+		if report := f.healthReport(mp); report != nil {
+			response.Reports = append(response.Reports, report)
+		}
+	}
+	f.serveApiJSON(response, w, r)
+}
+
+func (f *CFeature) serveApiDistributions(w http.ResponseWriter, r *http.Request) {
+	response := &apiDistributions{Mounts: make([]*apiMountDists, 0, len(f.mount))}
+	for _, mp := range f.mount {
+		dists := f.Distributions(mp)
+		if dists == nil {
+			dists = make([]*distRelease, 0)
+		}
+		response.Mounts = append(response.Mounts, &apiMountDists{Mount: mp.Mount, Dists: dists})
+	}
+	f.serveApiJSON(response, w, r)
+}
+
+func (f *CFeature) serveApiLint(w http.ResponseWriter, r *http.Request) {
+	var severity lint.Severity
+	if value := r.URL.Query().Get("severity"); value != "" {
+		var ok bool
+		if severity, ok = lint.ParseSeverity(value); !ok {
+			f.serveApiError(http.StatusBadRequest, fmt.Sprintf("invalid severity: %q, must be one of: error, warning, info", value), w, r)
+			return
+		}
+	}
+	list := lintFindings(f.store.Query(f.apiQuery(r)), severity)
+	pagination, start, end, err := paginate(r, len(list))
+	if err != nil {
+		f.serveApiError(http.StatusBadRequest, err.Error(), w, r)
+		return
+	}
+	response := &apiLintList{apiPagination: pagination, Packages: make([]*apiLintReport, 0, end-start)}
+	for _, dd := range list[start:end] {
+		report := &apiLintReport{apiPackageSummary: *newApiPackageSummary(dd)}
+		for _, finding := range dd.Lint {
+			if severity == "" || finding.Severity.Rank() <= severity.Rank() {
+				report.Findings = append(report.Findings, finding)
+			}
+		}
+		response.Packages = append(response.Packages, report)
+	}
+	f.serveApiJSON(response, w, r)
+}
+
+func (f *CFeature) serveApiPackage(name, version string, w http.ResponseWriter, r *http.Request) {
+	q := f.apiQuery(r)
+	q.Name, q.Version = name, version
+	list := f.store.Query(q)
+	if len(list) == 0 {
+		f.serveApiError(http.StatusNotFound, "package not found", w, r)
+		return
+	}
+	response := &apiPackageVersions{Name: name, Version: version}
+	for _, dd := range list {
+		p := newApiPackage(dd)
+		p.InstallabilityIssues = f.InstallabilityIssues(dd)
+		p.Published = f.Publications(dd)
+		response.Packages = append(response.Packages, p)
+	}
+	f.serveApiJSON(response, w, r)
+}
+
+func (f *CFeature) serveApiFiles(name, version string, w http.ResponseWriter, r *http.Request) {
+	q := f.apiQuery(r)
+	q.Name, q.Version = name, version
+	list := f.store.Query(q)
+	switch {
+	case len(list) == 0:
+		f.serveApiError(http.StatusNotFound, "package not found", w, r)
+		return
+	case len(list) > 1:
+		var found []string
+		for _, dd := range list {
+			found = append(found, "mount="+dd.MP.Mount+"&component="+dd.Component+"&arch="+dd.Architecture)
+		}
+		f.serveApiError(http.StatusBadRequest, "multiple packages found, specify one of: "+strings.Join(found, ", "), w, r)
+		return
+	}
+
+	dd := list[0]
+	pagination, start, end, err := paginate(r, len(dd.Files))
+	if err != nil {
+		f.serveApiError(http.StatusBadRequest, err.Error(), w, r)
+		return
+	}
+	response := &apiFileList{
+		apiPagination: pagination,
+		Name:          dd.Name,
+		Version:       dd.Version,
+		Architecture:  dd.Architecture,
+		Files:         make([]*apiFile, 0, end-start),
+	}
+	for _, file := range dd.Files[start:end] {
+		response.Files = append(response.Files, newApiFile(file))
+	}
+	f.serveApiJSON(response, w, r)
+}
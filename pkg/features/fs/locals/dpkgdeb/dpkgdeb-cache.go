@@ -0,0 +1,186 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-enjin/be/pkg/log"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/checksums"
+)
+
+// gCacheVersion is incremented whenever the dpkgDebData structure changes,
+// invalidating all existing cache records
+const gCacheVersion = 6
+
+const gCacheIndexFile = "index.json"
+
+// diskCacheEntry associates a package file path with its checksums
+type diskCacheEntry struct {
+	Size    int64           `json:"size"`
+	ModTime time.Time       `json:"mtime"`
+	Sums    *checksums.Sums `json:"sums"`
+}
+
+// diskCacheRecord is the persisted form of the dpkgDebData for a single
+// package file, keyed by the SHA256 of the file
+type diskCacheRecord struct {
+	Version int          `json:"version"`
+	Data    *dpkgDebData `json:"data"`
+}
+
+// diskCache is a directory of parsed package content. Packages are immutable
+// once in the pool, so a record is reused as long as the file's size,
+// modification time and SHA256 are unchanged
+type diskCache struct {
+	path  string
+	index map[string]*diskCacheEntry
+	dirty bool
+
+	sync.RWMutex
+}
+
+func newDiskCache(path string) (c *diskCache, err error) {
+	if err = os.MkdirAll(path, 0755); err != nil {
+		return
+	}
+	c = &diskCache{
+		path:  path,
+		index: make(map[string]*diskCacheEntry),
+	}
+	if data, ee := os.ReadFile(filepath.Join(path, gCacheIndexFile)); ee == nil {
+		if ee = json.Unmarshal(data, &c.index); ee != nil {
+			log.WarnF("ignoring corrupt dpkg-deb cache index: %v", ee)
+			c.index = make(map[string]*diskCacheEntry)
+		}
+	}
+	return
+}
+
+// Checksums returns the cached checksums for the file at the given path, if
+// the size and modification time are unchanged, otherwise the file is hashed
+// and the index updated
+func (c *diskCache) Checksums(path string, info os.FileInfo) (sums *checksums.Sums, err error) {
+	c.RLock()
+	entry, present := c.index[path]
+	c.RUnlock()
+
+	if present && entry.Sums != nil && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		sums = entry.Sums
+		return
+	}
+
+	if sums, err = checksums.HashFile(path); err != nil {
+		return
+	}
+
+	c.Lock()
+	c.index[path] = &diskCacheEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+		Sums:    sums,
+	}
+	c.dirty = true
+	c.Unlock()
+	return
+}
+
+func (c *diskCache) recordPath(sha256 string) (path string) {
+	path = filepath.Join(c.path, sha256+".json")
+	return
+}
+
+// Load returns the cached data for the given SHA256, if present and current
+func (c *diskCache) Load(sha256 string) (data *dpkgDebData, ok bool) {
+	contents, err := os.ReadFile(c.recordPath(sha256))
+	if err != nil {
+		return
+	}
+	var record diskCacheRecord
+	if err = json.Unmarshal(contents, &record); err != nil || record.Version != gCacheVersion || record.Data == nil {
+		return
+	}
+	data, ok = record.Data, true
+	return
+}
+
+// Store writes the data for the given SHA256
+func (c *diskCache) Store(sha256 string, data *dpkgDebData) (err error) {
+	var contents []byte
+	if contents, err = json.Marshal(&diskCacheRecord{Version: gCacheVersion, Data: data}); err != nil {
+		return
+	}
+	err = writeFileAtomic(c.recordPath(sha256), contents)
+	return
+}
+
+// Save removes index entries for files not present in keep, removes any
+// records no longer referenced and writes the index if modified
+func (c *diskCache) Save(keep map[string]struct{}) (err error) {
+	c.Lock()
+	defer c.Unlock()
+
+	referenced := make(map[string]struct{})
+	for path, entry := range c.index {
+		if _, present := keep[path]; !present {
+			delete(c.index, path)
+			c.dirty = true
+		} else if entry.Sums != nil {
+			referenced[entry.Sums.SHA256+".json"] = struct{}{}
+		}
+	}
+
+	if !c.dirty {
+		return
+	}
+
+	if entries, ee := os.ReadDir(c.path); ee == nil {
+		for _, entry := range entries {
+			name := entry.Name()
+			if name == gCacheIndexFile || !strings.HasSuffix(name, ".json") {
+				continue
+			}
+			if _, present := referenced[name]; !present {
+				_ = os.Remove(filepath.Join(c.path, name))
+			}
+		}
+	}
+
+	var contents []byte
+	if contents, err = json.Marshal(c.index); err != nil {
+		return
+	}
+	if err = writeFileAtomic(filepath.Join(c.path, gCacheIndexFile), contents); err == nil {
+		c.dirty = false
+	}
+	return
+}
+
+func writeFileAtomic(path string, data []byte) (err error) {
+	tmp := path + ".tmp"
+	if err = os.WriteFile(tmp, data, 0644); err != nil {
+		return
+	}
+	if err = os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+	}
+	return
+}
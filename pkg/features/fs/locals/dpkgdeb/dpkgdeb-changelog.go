@@ -0,0 +1,151 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"time"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/types/page"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/changelog"
+)
+
+// gChangelogRecent is the number of entries shown on the deb page
+const gChangelogRecent = 3
+
+// changelogUrl returns the url of the changelog sub-page of the deb page
+func changelogUrl(dd *dpkgDeb) (url string) {
+	url = dd.Url + "/changelog"
+	return
+}
+
+// makeChangelogSection returns the njn fields for the given changelog entries
+func makeChangelogSection(entries []*changelog.Entry) (section []interface{}) {
+	for _, entry := range entries {
+		var changes []interface{}
+		for _, change := range entry.Changes {
+			if len(change.Details) == 0 {
+				changes = append(changes, html.EscapeString(change.Text))
+				continue
+			}
+			// nested lists are not inline fields, details are separate lines
+			item := []interface{}{html.EscapeString(change.Text)}
+			for _, detail := range change.Details {
+				item = append(item, map[string]interface{}{"type": "br"}, html.EscapeString("- "+detail))
+			}
+			changes = append(changes, item)
+		}
+
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				map[string]interface{}{"type": "b", "text": []interface{}{html.EscapeString(entry.Header())}},
+			},
+		})
+		if len(changes) > 0 {
+			section = append(section, map[string]interface{}{"type": "ul", "list": changes})
+		}
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{html.EscapeString(" -- " + entry.Author() + "  " + entry.DateString)},
+		})
+	}
+	return
+}
+
+// makeChangelogBlock returns the JSON encoded njn fields for the changelog
+// block of the deb page, showing only the most recent entries
+func makeChangelogBlock(dd *dpkgDeb) (output string, err error) {
+	var section []interface{}
+	if len(dd.Changelog) == 0 {
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": "No changelog found.",
+		})
+	} else {
+		recent := dd.Changelog
+		if len(recent) > gChangelogRecent {
+			recent = recent[:gChangelogRecent]
+		}
+		section = makeChangelogSection(recent)
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				map[string]interface{}{
+					"type": "a",
+					"href": changelogUrl(dd),
+					"text": "Full changelog",
+				},
+			},
+		})
+	}
+	output, err = MarshalNjn(section)
+	return
+}
+
+// cachedChangelogPage returns a copy of the changelog page for the given
+// package and language, making and caching it on the first request
+func (f *CFeature) cachedChangelogPage(r *http.Request, tag language.Tag, dd *dpkgDeb) (p feature.Page, err error) {
+	url := changelogUrl(dd)
+	if cached, ok := f.pages.Get(url, tag, dd); ok {
+		p = cached
+		return
+	}
+	if p, err = f.makeChangelogPage(r, dd); err != nil {
+		return
+	}
+	f.pages.Put(url, tag, dd, p)
+	p = p.Copy()
+	return
+}
+
+func (f *CFeature) makeChangelogPage(r *http.Request, dd *dpkgDeb) (p feature.Page, err error) {
+	if len(dd.Changelog) == 0 {
+		err = fmt.Errorf("changelog not found")
+		return
+	}
+	debName, _ := f.makeDebNameUrl(dd.MP.Mount, dd.File)
+	url := changelogUrl(dd)
+
+	var data string
+	if data, err = MarshalNjn(makeChangelogSection(dd.Changelog)); err != nil {
+		err = fmt.Errorf("error encoding changelog page: %v - %v", url, err)
+		return
+	}
+
+	source := fmt.Sprintf(
+		gContentPageTemplate,
+		debName+" changelog", "Debian changelog for "+debName, url,
+		debName,
+		"package-changelog",
+		"Changelog",
+		data,
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), url, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new changelog page: %v - %v", url, err)
+		return
+	}
+	p.SetSlugUrl(url)
+	return
+}
@@ -0,0 +1,317 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/types/page"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+// gContentsPerPage is the number of tree entries on each contents page
+const gContentsPerPage = 250
+
+// contentsUrl returns the url of the contents sub-page of the deb page
+func contentsUrl(dd *dpkgDeb) (url string) {
+	url = dd.Url + "/contents"
+	return
+}
+
+// contentsPageNumber returns the requested contents page number, from the
+// page query parameter
+func contentsPageNumber(r *http.Request) (number int, ok bool) {
+	value := r.URL.Query().Get("page")
+	if value == "" {
+		number, ok = 1, true
+		return
+	}
+	var err error
+	number, err = strconv.Atoi(value)
+	ok = err == nil && number >= 1
+	return
+}
+
+// flattenContents returns the tree below root in depth-first order
+func flattenContents(root *deb.Node) (entries []*deb.Node) {
+	root.Walk(func(node *deb.Node, _ int) {
+		entries = append(entries, node)
+	})
+	return
+}
+
+// describeDir returns the file count and total size of the directory
+func describeDir(node *deb.Node) (text string) {
+	files := "files"
+	if node.Count == 1 {
+		files = "file"
+	}
+	text = fmt.Sprintf("%d %s, %s", node.Count, files, formatSize(node.Size))
+	return
+}
+
+// makeContentsBlock returns the JSON encoded njn fields summarizing the
+// package contents on the deb page
+func makeContentsBlock(dd *dpkgDeb) (output string, err error) {
+	root := deb.NewTree(dd.Files)
+
+	var rows []interface{}
+	for _, child := range root.Children {
+		name, summary := child.Name, formatSize(child.Size)
+		if child.IsDir() {
+			name, summary = name+"/", describeDir(child)
+		}
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": html.EscapeString(name)},
+				map[string]interface{}{"type": "td", "text": summary},
+			},
+		})
+	}
+
+	section := []interface{}{
+		map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				fmt.Sprintf("%s in %d directories. ", describeDir(root), root.Dirs()),
+				map[string]interface{}{"type": "a", "href": contentsUrl(dd), "text": []interface{}{"Browse contents"}},
+			},
+		},
+	}
+	if len(rows) > 0 {
+		section = append(section, map[string]interface{}{"type": "table", "body": rows})
+	}
+
+	output, err = MarshalNjn(section)
+	return
+}
+
+// contentsDir is a directory being rendered on a contents page
+type contentsDir struct {
+	node   *deb.Node
+	label  string
+	fields []interface{}
+	files  []interface{}
+}
+
+// close returns the njn fields of this directory, with any files as a table
+// following the subdirectories
+func (d *contentsDir) close() (fields []interface{}) {
+	fields = d.fields
+	if len(d.files) > 0 {
+		fields = append(fields, map[string]interface{}{
+			"type": "table",
+			"head": []interface{}{
+				map[string]interface{}{"type": "th", "text": "Mode"},
+				map[string]interface{}{"type": "th", "text": "Owner"},
+				map[string]interface{}{"type": "th", "text": "Size"},
+				map[string]interface{}{"type": "th", "text": "Modified"},
+				map[string]interface{}{"type": "th", "text": "Name"},
+			},
+			"body": d.files,
+		})
+	}
+	return
+}
+
+// makeContentsSection returns the njn fields of the given depth-first slice
+// of contents entries, as nested details elements per directory. Directories
+// continued from the previous page are included for context
+func makeContentsSection(dd *dpkgDeb, root *deb.Node, entries []*deb.Node) (section []interface{}) {
+	stack := []*contentsDir{{}}
+
+	pop := func() {
+		dir := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		parent := stack[len(stack)-1]
+		summary := dir.label + " — " + describeDir(dir.node)
+		parent.fields = append(parent.fields, makeDetails(summary, len(stack) <= 2, dir.close()...))
+	}
+
+	push := func(node *deb.Node, label string) {
+		stack = append(stack, &contentsDir{node: node, label: label})
+	}
+
+	if len(entries) > 0 {
+		// open the ancestors of the first entry, continued from the last page
+		parent := root
+		parts := strings.Split(entries[0].Path, "/")
+		for _, name := range parts[:len(parts)-1] {
+			for _, child := range parent.Children {
+				if child.Name == name {
+					parent = child
+					break
+				}
+			}
+			push(parent, parent.Path+"/ (continued)")
+		}
+	}
+
+	for _, entry := range entries {
+		for len(stack) > 1 && !strings.HasPrefix(entry.Path, stack[len(stack)-1].node.Path+"/") {
+			pop()
+		}
+		if entry.IsDir() {
+			push(entry, entry.Name+"/")
+			continue
+		}
+
+		file := entry.File
+		var name []interface{}
+		switch {
+		case file.IsSymlink():
+			name = append(name, html.EscapeString(entry.Name+" → "+file.Linkname))
+		case file.Linkname != "":
+			name = append(name, html.EscapeString(entry.Name+" link to "+file.Linkname))
+		case file.IsRegular():
+			name = append(name, map[string]interface{}{
+				"type": "a",
+				"href": filesUrl(dd, entry.Path),
+				"text": []interface{}{html.EscapeString(entry.Name)},
+			})
+		default:
+			name = append(name, html.EscapeString(entry.Name))
+		}
+		dir := stack[len(stack)-1]
+		dir.files = append(dir.files, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "code", "code": []interface{}{deb.ModeString(file.Type, file.Mode)}},
+				}},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(file.Uname + "/" + file.Gname)},
+				map[string]interface{}{"type": "td", "text": formatSize(file.Size)},
+				map[string]interface{}{"type": "td", "text": file.ModTime.UTC().Format("2006-01-02 15:04")},
+				map[string]interface{}{"type": "td", "text": name},
+			},
+		})
+	}
+	for len(stack) > 1 {
+		pop()
+	}
+
+	section = stack[0].close()
+	return
+}
+
+// makeContentsPager returns the njn paragraph linking to the neighbouring
+// contents pages
+func makeContentsPager(dd *dpkgDeb, number, pages int) (field map[string]interface{}) {
+	url := contentsUrl(dd)
+	// whitespace only strings are dropped by njn, separators use &nbsp;
+	var text []interface{}
+	if number > 1 {
+		text = append(text, map[string]interface{}{
+			"type": "a", "href": fmt.Sprintf("%s?page=%d", url, number-1), "text": []interface{}{"&laquo; previous"},
+		}, "&nbsp;|&nbsp;")
+	}
+	text = append(text, fmt.Sprintf("Page %d of %d", number, pages))
+	if number < pages {
+		text = append(text, "&nbsp;|&nbsp;", map[string]interface{}{
+			"type": "a", "href": fmt.Sprintf("%s?page=%d", url, number+1), "text": []interface{}{"next &raquo;"},
+		})
+	}
+	field = map[string]interface{}{"type": "p", "text": text}
+	return
+}
+
+// cachedContentsPage returns a copy of the given contents page for the given
+// package and language, making and caching it on the first request. ok is
+// false when the page number is out of range
+func (f *CFeature) cachedContentsPage(r *http.Request, tag language.Tag, dd *dpkgDeb, number int) (p feature.Page, ok bool, err error) {
+	key := fmt.Sprintf("%s?page=%d", contentsUrl(dd), number)
+	if cached, present := f.pages.Get(key, tag, dd); present {
+		p, ok = cached, true
+		return
+	}
+	if p, ok, err = f.makeContentsPage(r, dd, number); err != nil || !ok {
+		return
+	}
+	f.pages.Put(key, tag, dd, p)
+	p = p.Copy()
+	return
+}
+
+func (f *CFeature) makeContentsPage(r *http.Request, dd *dpkgDeb, number int) (p feature.Page, ok bool, err error) {
+	root := deb.NewTree(dd.Files)
+	entries := flattenContents(root)
+	pages := (len(entries) + gContentsPerPage - 1) / gContentsPerPage
+	if pages == 0 {
+		pages = 1
+	}
+	if number > pages {
+		return
+	}
+	start := (number - 1) * gContentsPerPage
+	end := start + gContentsPerPage
+	if end > len(entries) {
+		end = len(entries)
+	}
+
+	debName, _ := f.makeDebNameUrl(dd.MP.Mount, dd.File)
+	url := contentsUrl(dd)
+
+	var section []interface{}
+	section = append(section, map[string]interface{}{
+		"type": "p",
+		"text": fmt.Sprintf("%s in %d directories.", describeDir(root), root.Dirs()),
+	})
+	if pages > 1 {
+		section = append(section, makeContentsPager(dd, number, pages))
+	}
+	if len(entries) == 0 {
+		section = append(section, map[string]interface{}{"type": "p", "text": "No files found."})
+	} else {
+		section = append(section, makeContentsSection(dd, root, entries[start:end])...)
+	}
+	if pages > 1 {
+		section = append(section, makeContentsPager(dd, number, pages))
+	}
+
+	var data string
+	if data, err = MarshalNjn(section); err != nil {
+		err = fmt.Errorf("error encoding contents page: %v - %v", url, err)
+		return
+	}
+
+	source := fmt.Sprintf(
+		gContentPageTemplate,
+		debName+" contents", "Debian package contents of "+debName, url,
+		debName,
+		"package-contents",
+		"Contents",
+		data,
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), url, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new contents page: %v - %v", url, err)
+		return
+	}
+	p.SetSlugUrl(url)
+	ok = true
+	return
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"archive/tar"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/go-enjin/be/pkg/cli/run"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+var (
+	// gMaintainerScripts are the control archive members run by dpkg, as root,
+	// when installing, upgrading or removing the package
+	gMaintainerScripts = []string{
+		"preinst",
+		"postinst",
+		"prerm",
+		"postrm",
+		"config",
+	}
+	// gControlMembers are the control archive members displayed on the deb
+	// page, in order
+	gControlMembers = append(slices.Clone(gMaintainerScripts),
+		"templates",
+		"triggers",
+		"conffiles",
+		"md5sums",
+		"shlibs",
+	)
+	// gScriptLanguages are the code block languages of the known maintainer
+	// script interpreters
+	gScriptLanguages = map[string]string{
+		"sh":      "bash",
+		"bash":    "bash",
+		"dash":    "bash",
+		"perl":    "perl",
+		"python":  "python",
+		"python3": "python",
+	}
+)
+
+// ControlFile returns the named control archive member, nil when the package
+// does not have one
+func (dd *dpkgDeb) ControlFile(name string) (file *deb.File) {
+	for _, file = range dd.ControlFiles {
+		if deb.CleanName(file.Name) == name {
+			return
+		}
+	}
+	file = nil
+	return
+}
+
+// MaintainerScripts returns the names of the maintainer scripts included with
+// the package
+func (dd *dpkgDeb) MaintainerScripts() (names []string) {
+	for _, name := range gMaintainerScripts {
+		if dd.ControlFile(name) != nil {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// readControlFiles returns the known control archive members using dpkg-deb,
+// for when the native reader is unable to read the package
+func readControlFiles(fullpath string) (files []*deb.File) {
+	for _, name := range gControlMembers {
+		if data, _, _, err := run.Cmd("dpkg-deb", "--info", fullpath, name); err == nil {
+			files = append(files, &deb.File{
+				Name: name,
+				Type: tar.TypeReg,
+				Size: int64(len(data)),
+				Data: []byte(data),
+			})
+		}
+	}
+	return
+}
+
+// scriptLanguage returns the code block language of the given control
+// archive member, from the interpreter of the #! line for scripts
+func scriptLanguage(name string, data []byte) (language string) {
+	if name == "templates" {
+		language = "debcontrol"
+		return
+	}
+	line, _, _ := strings.Cut(string(data), "\n")
+	interpreter, found := strings.CutPrefix(line, "#!")
+	if !found {
+		language = "plaintext"
+		return
+	}
+	fields := strings.Fields(interpreter)
+	if len(fields) == 0 {
+		language = "plaintext"
+		return
+	}
+	command := path.Base(fields[0])
+	if command == "env" && len(fields) > 1 {
+		command = fields[1]
+	}
+	if language = gScriptLanguages[command]; language == "" {
+		language = "plaintext"
+	}
+	return
+}
+
+// makeScriptsBadge returns the JSON encoded njn paragraph warning that the
+// package runs maintainer scripts, or an empty string when it does not
+func makeScriptsBadge(dd *dpkgDeb) (output string, err error) {
+	scripts := dd.MaintainerScripts()
+	if len(scripts) == 0 {
+		return
+	}
+	badge := map[string]interface{}{
+		"type": "p",
+		"text": []interface{}{
+			map[string]interface{}{
+				"type": "mark",
+				"text": []interface{}{
+					map[string]interface{}{"type": "strong", "text": []interface{}{"Runs maintainer scripts as root:"}},
+					"&nbsp;",
+					map[string]interface{}{"type": "a", "href": "#control-files", "text": []interface{}{strings.Join(scripts, ", ")}},
+				},
+			},
+		},
+	}
+	output, err = MarshalNjn(badge)
+	return
+}
+
+// makeControlBlock returns the JSON encoded njn fields displaying the known
+// control archive members, each in a code block with the maintainer scripts
+// open by default. The language class of each block selects the highlighter
+// syntax, see registerHighlighter
+func makeControlBlock(dd *dpkgDeb) (output string, err error) {
+	var section []interface{}
+	for _, name := range gControlMembers {
+		file := dd.ControlFile(name)
+		if file == nil {
+			continue
+		}
+		text := strings.TrimSuffix(string(file.Data), "\n")
+		var lines []interface{}
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, line)
+		}
+		count := "lines"
+		if len(lines) == 1 {
+			count = "line"
+		}
+		summary := fmt.Sprintf("%s — %d %s, %s", name, len(lines), count, formatSize(file.Size))
+		if file.Mode.Perm() != 0 {
+			summary += ", " + deb.ModeString(file.Type, file.Mode)
+		}
+		section = append(section, makeDetails(summary, slices.Contains(gMaintainerScripts, name),
+			map[string]interface{}{
+				"type":       "code",
+				"decorated":  "true",
+				"attributes": map[string]interface{}{"class": "language-" + scriptLanguage(name, file.Data)},
+				"code":       lines,
+			},
+		))
+	}
+	if len(section) == 0 {
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": "No maintainer scripts or other control files besides the control file.",
+		})
+	}
+	output, err = MarshalNjn(section)
+	return
+}
@@ -0,0 +1,217 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/types/page"
+)
+
+// copyrightUrl returns the url of the copyright sub-page of the deb page
+func copyrightUrl(dd *dpkgDeb) (url string) {
+	url = dd.Url + "/copyright"
+	return
+}
+
+// makeLicenseFields returns the JSON encoded njn table summarizing the
+// licenses of the given package, for the deb-fields sidebar. Packages
+// without a machine-readable copyright are flagged
+func makeLicenseFields(dd *dpkgDeb) (output string, err error) {
+	var value []interface{}
+	if dd.Copyright != nil {
+		value = append(value, map[string]interface{}{
+			"type": "a",
+			"href": copyrightUrl(dd),
+			"text": []interface{}{html.EscapeString(strings.Join(dd.Copyright.Summary(), ", "))},
+		})
+	} else {
+		value = append(value, map[string]interface{}{
+			"type": "mark",
+			"text": []interface{}{html.EscapeString("Unknown: " + dd.CopyrightIssue())},
+		})
+		if dd.CopyrightText != "" {
+			value = append(value,
+				map[string]interface{}{"type": "br"},
+				map[string]interface{}{"type": "a", "href": copyrightUrl(dd), "text": []interface{}{"copyright"}},
+			)
+		}
+	}
+
+	table := map[string]interface{}{
+		"type": "table",
+		"body": []interface{}{
+			map[string]interface{}{
+				"type": "tr",
+				"data": []interface{}{
+					map[string]interface{}{"type": "td", "text": []interface{}{
+						map[string]interface{}{"type": "b", "text": []interface{}{"License"}},
+					}},
+					map[string]interface{}{"type": "td", "text": value},
+				},
+			},
+		},
+	}
+	output, err = MarshalNjn(table)
+	return
+}
+
+// makeCopyrightSection returns the njn fields for the copyright page of the
+// given package
+func makeCopyrightSection(dd *dpkgDeb) (section []interface{}) {
+	codeBlock := func(text string) (field map[string]interface{}) {
+		var lines []interface{}
+		for _, line := range strings.Split(text, "\n") {
+			lines = append(lines, line)
+		}
+		field = map[string]interface{}{"type": "code", "code": lines}
+		return
+	}
+
+	c := dd.Copyright
+	if c == nil {
+		section = append(section,
+			map[string]interface{}{
+				"type": "p",
+				"text": []interface{}{
+					map[string]interface{}{"type": "mark", "text": []interface{}{html.EscapeString("Unknown license: " + dd.CopyrightIssue())}},
+				},
+			},
+			codeBlock(dd.CopyrightText),
+		)
+		return
+	}
+
+	if c.UpstreamName != "" || c.Source != "" {
+		var text []interface{}
+		if c.UpstreamName != "" {
+			text = append(text, map[string]interface{}{"type": "b", "text": []interface{}{html.EscapeString(c.UpstreamName)}})
+		}
+		if c.Source != "" {
+			if len(text) > 0 {
+				text = append(text, map[string]interface{}{"type": "br"})
+			}
+			text = append(text, html.EscapeString("Source: "+c.Source))
+		}
+		section = append(section, map[string]interface{}{"type": "p", "text": text})
+	}
+
+	var rows []interface{}
+	addRow := func(patterns, holders []string, license string) {
+		var copyrights []interface{}
+		for _, holder := range holders {
+			copyrights = append(copyrights, html.EscapeString(holder))
+		}
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": html.EscapeString(strings.Join(patterns, " "))},
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "ul", "list": copyrights},
+				}},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(license)},
+			},
+		})
+	}
+	if c.License != nil {
+		addRow([]string{"(package)"}, c.Copyright, c.License.Name)
+	}
+	for _, files := range c.Files {
+		addRow(files.Patterns, files.Copyright, files.License.Name)
+	}
+	section = append(section, map[string]interface{}{
+		"type": "table",
+		"head": []interface{}{
+			map[string]interface{}{"type": "th", "text": "Files"},
+			map[string]interface{}{"type": "th", "text": "Copyright"},
+			map[string]interface{}{"type": "th", "text": "License"},
+		},
+		"body": rows,
+	})
+
+	names := c.Summary()
+	for _, license := range c.Licenses {
+		if !slices.Contains(names, license.Name) {
+			names = append(names, license.Name)
+		}
+	}
+	for _, name := range names {
+		if text := c.LicenseText(name); text != "" {
+			section = append(section, makeDetails(name, false, codeBlock(text)))
+		}
+	}
+	return
+}
+
+// cachedCopyrightPage returns a copy of the copyright page for the given
+// package and language, making and caching it on the first request
+func (f *CFeature) cachedCopyrightPage(r *http.Request, tag language.Tag, dd *dpkgDeb) (p feature.Page, err error) {
+	url := copyrightUrl(dd)
+	if cached, ok := f.pages.Get(url, tag, dd); ok {
+		p = cached
+		return
+	}
+	if p, err = f.makeCopyrightPage(r, dd); err != nil {
+		return
+	}
+	f.pages.Put(url, tag, dd, p)
+	p = p.Copy()
+	return
+}
+
+func (f *CFeature) makeCopyrightPage(r *http.Request, dd *dpkgDeb) (p feature.Page, err error) {
+	if dd.CopyrightText == "" {
+		err = fmt.Errorf("copyright not found")
+		return
+	}
+	debName, _ := f.makeDebNameUrl(dd.MP.Mount, dd.File)
+	url := copyrightUrl(dd)
+
+	var data string
+	if data, err = MarshalNjn(makeCopyrightSection(dd)); err != nil {
+		err = fmt.Errorf("error encoding copyright page: %v - %v", url, err)
+		return
+	}
+
+	header := "Copyright"
+	if dd.Copyright != nil {
+		header = "Copyright: " + strings.Join(dd.Copyright.Summary(), ", ")
+	}
+	source := fmt.Sprintf(
+		gContentPageTemplate,
+		debName+" copyright", "Debian copyright for "+debName, url,
+		debName,
+		"package-copyright",
+		EscapeJson(html.EscapeString(header)),
+		data,
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), url, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new copyright page: %v - %v", url, err)
+		return
+	}
+	p.SetSlugUrl(url)
+	return
+}
@@ -0,0 +1,289 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"html"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/fvbommel/sortorder"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+// distRelease is a distribution published within a mount point, described by
+// its dists/<codename>/Release file
+type distRelease struct {
+	Codename string `json:"codename"`
+	Suite    string `json:"suite,omitempty"`
+	// Aliases are the names of any dists/ symlinks to the distribution
+	Aliases       []string `json:"aliases,omitempty"`
+	Components    []string `json:"components"`
+	Architectures []string `json:"architectures"`
+	Date          string   `json:"date,omitempty"`
+	// Signed is true if the InRelease file is present
+	Signed bool `json:"signed"`
+}
+
+// Suites returns the suite and any aliases which differ from the codename
+func (dist *distRelease) Suites() (names []string) {
+	for _, name := range append([]string{dist.Suite}, dist.Aliases...) {
+		if name != "" && name != dist.Codename && !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	return
+}
+
+// debPublication is a distribution component which lists a package file
+type debPublication struct {
+	Codename  string `json:"codename"`
+	Suite     string `json:"suite,omitempty"`
+	Component string `json:"component"`
+	// Architectures are the Packages indices listing the package file, "all"
+	// packages are listed by every architecture
+	Architectures []string `json:"architectures"`
+}
+
+// Label returns the codename and component, with any suite in parentheses
+func (pub *debPublication) Label() (label string) {
+	label = pub.Codename + "/" + pub.Component
+	if pub.Suite != "" && pub.Suite != pub.Codename {
+		label += " (" + pub.Suite + ")"
+	}
+	return
+}
+
+// distsReport is the distributions of a mount point and the package files
+// each distribution component lists
+type distsReport struct {
+	Dists []*distRelease
+	// published are the publications of each package file, keyed by Filename
+	published map[string][]*debPublication
+
+	signature string
+}
+
+// isSuiteLink returns true if the named dists/ entry is a symlink, as created
+// for suite aliases of a codename
+func isSuiteLink(path string) (link bool) {
+	info, err := os.Lstat(path)
+	link = err == nil && info.Mode()&os.ModeSymlink != 0
+	return
+}
+
+// distsSignature returns a signature of the Release files, Packages indices
+// and suite symlinks of the mount point which changes when any are modified
+func distsSignature(mp *feature.CMountPoint) (signature string) {
+	var links, files []string
+	entries, _ := os.ReadDir(filepath.Join(mp.Path, "dists"))
+	for _, entry := range entries {
+		path := filepath.Join(mp.Path, "dists", entry.Name())
+		if isSuiteLink(path) {
+			target, _ := os.Readlink(path)
+			links = append(links, path+"->"+target)
+			continue
+		}
+		files = append(files, filepath.Join(path, "Release"))
+	}
+	files = append(files, packageIndices(mp)...)
+	signature = strings.Join(append(links, statSignature(files...)), "\n")
+	return
+}
+
+// readDists returns the distributions of the mount point and the package
+// files listed by each of their Packages indices
+func (f *CFeature) readDists(mp *feature.CMountPoint) (report *distsReport) {
+	report = &distsReport{published: make(map[string][]*debPublication)}
+
+	distsPath := filepath.Join(mp.Path, "dists")
+	entries, _ := os.ReadDir(distsPath)
+	byDir := make(map[string]*distRelease)
+	aliases := make(map[string][]string)
+	for _, entry := range entries {
+		path := filepath.Join(distsPath, entry.Name())
+		if isSuiteLink(path) {
+			if target, err := os.Readlink(path); err == nil {
+				aliases[filepath.Base(target)] = append(aliases[filepath.Base(target)], entry.Name())
+			}
+			continue
+		} else if !entry.IsDir() {
+			continue
+		}
+		dist := &distRelease{Codename: entry.Name()}
+		if data, err := os.ReadFile(filepath.Join(path, "Release")); err == nil {
+			if p, ee := control.ParseParagraph(string(data)); ee != nil {
+				log.ErrorF("error parsing release file: %v - %v", path, ee)
+			} else {
+				if codename := p.Value("Codename"); codename != "" {
+					dist.Codename = codename
+				}
+				if suite := p.Value("Suite"); suite != dist.Codename {
+					dist.Suite = suite
+				}
+				dist.Components = strings.Fields(p.Value("Components"))
+				dist.Architectures = strings.Fields(p.Value("Architectures"))
+				dist.Date = p.Value("Date")
+			}
+		}
+		_, err := os.Stat(filepath.Join(path, "InRelease"))
+		dist.Signed = err == nil
+		byDir[entry.Name()] = dist
+		report.Dists = append(report.Dists, dist)
+	}
+	for dir, names := range aliases {
+		if dist, present := byDir[dir]; present {
+			sort.Strings(names)
+			for _, name := range names {
+				if name != dist.Suite {
+					dist.Aliases = append(dist.Aliases, name)
+				}
+			}
+		}
+	}
+	sort.Slice(report.Dists, func(i, j int) (less bool) {
+		less = sortorder.NaturalLess(report.Dists[i].Codename, report.Dists[j].Codename)
+		return
+	})
+
+	for _, index := range packageIndices(mp) {
+		archDir := filepath.Dir(index)
+		arch := strings.TrimPrefix(filepath.Base(archDir), "binary-")
+		component := filepath.Base(filepath.Dir(archDir))
+		dist, present := byDir[filepath.Base(filepath.Dir(filepath.Dir(archDir)))]
+		if !present {
+			continue
+		}
+
+		paragraphs, err := f.packageIndex(index)
+		if err != nil {
+			log.ErrorF("error reading packages index: %v - %v", index, err)
+			continue
+		}
+		for _, paragraph := range paragraphs {
+			filename := paragraph.Value("Filename")
+			if filename == "" {
+				continue
+			}
+			filename = deb.CleanName(filename)
+			var pub *debPublication
+			for _, existing := range report.published[filename] {
+				if existing.Codename == dist.Codename && existing.Component == component {
+					pub = existing
+					break
+				}
+			}
+			if pub == nil {
+				pub = &debPublication{Codename: dist.Codename, Suite: dist.Suite, Component: component}
+				report.published[filename] = append(report.published[filename], pub)
+			}
+			if !slices.Contains(pub.Architectures, arch) {
+				pub.Architectures = append(pub.Architectures, arch)
+			}
+		}
+	}
+	for _, pubs := range report.published {
+		sort.Slice(pubs, func(i, j int) (less bool) {
+			if pubs[i].Codename != pubs[j].Codename {
+				less = sortorder.NaturalLess(pubs[i].Codename, pubs[j].Codename)
+				return
+			}
+			less = pubs[i].Component < pubs[j].Component
+			return
+		})
+	}
+	return
+}
+
+// updateDists reads the distributions of each mount point with modified
+// Release files or Packages indices, returning true if any changed
+func (f *CFeature) updateDists() (changed bool) {
+	for _, mp := range f.mount {
+		signature := distsSignature(mp)
+
+		f.distsLock.RLock()
+		previous := f.dists[mp.Mount]
+		f.distsLock.RUnlock()
+		if previous != nil && previous.signature == signature {
+			continue
+		}
+
+		report := f.readDists(mp)
+		report.signature = signature
+		f.distsLock.Lock()
+		f.dists[mp.Mount] = report
+		f.distsLock.Unlock()
+		changed = true
+		log.DebugF("dpkg-deb distributions read for %v: %d found", mp.Mount, len(report.Dists))
+	}
+	return
+}
+
+// Distributions returns the distributions published within the mount point
+func (f *CFeature) Distributions(mp *feature.CMountPoint) (dists []*distRelease) {
+	f.distsLock.RLock()
+	defer f.distsLock.RUnlock()
+	if report, present := f.dists[mp.Mount]; present {
+		dists = report.Dists
+	}
+	return
+}
+
+// Publications returns the distribution components listing the package file
+func (f *CFeature) Publications(dd *dpkgDeb) (pubs []*debPublication) {
+	f.distsLock.RLock()
+	defer f.distsLock.RUnlock()
+	if report, present := f.dists[dd.MP.Mount]; present {
+		pubs = report.published[deb.CleanName(dd.File)]
+	}
+	return
+}
+
+// publicationsText returns the labels of the publications of the package
+// file, joined with commas
+func (f *CFeature) publicationsText(dd *dpkgDeb) (text string) {
+	var labels []string
+	for _, pub := range f.Publications(dd) {
+		labels = append(labels, pub.Label())
+	}
+	text = strings.Join(labels, ", ")
+	return
+}
+
+// makePublishedNotice returns the JSON encoded njn paragraph listing the
+// distribution components the package file is published in, or noting that
+// none list it when the mount point has any distributions
+func (f *CFeature) makePublishedNotice(dd *dpkgDeb) (output string, err error) {
+	var text []interface{}
+	if published := f.publicationsText(dd); published != "" {
+		text = []interface{}{"Published in&nbsp;" + html.EscapeString(published)}
+	} else if len(f.Distributions(dd.MP)) > 0 {
+		text = []interface{}{
+			map[string]interface{}{"type": "mark", "text": []interface{}{"Not published in any distribution"}},
+		}
+	} else {
+		return
+	}
+	output, err = MarshalNjn(map[string]interface{}{"type": "p", "text": text})
+	return
+}
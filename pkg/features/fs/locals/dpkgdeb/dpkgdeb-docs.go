@@ -0,0 +1,110 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/changelog"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/copyright"
+)
+
+// gDocFileLimit is the maximum size of a documentation file, before and after
+// decompression
+const gDocFileLimit = 1024 * 1024
+
+var (
+	// gChangelogNames are the changelog file names looked for within the
+	// package documentation directory, in order of preference
+	gChangelogNames = []string{
+		"changelog.Debian.gz",
+		"changelog.gz",
+	}
+	// gCopyrightName is the copyright file name within the package
+	// documentation directory
+	gCopyrightName = "copyright"
+)
+
+// docFiles captures the documentation files while walking the data archive,
+// before the package name is known
+type docFiles struct {
+	files map[string][]byte
+}
+
+func newDocFiles() (c *docFiles) {
+	c = &docFiles{files: make(map[string][]byte)}
+	return
+}
+
+// Capture is a deb.WalkDataFn which keeps any usr/share/doc/*/ changelog and
+// copyright files
+func (c *docFiles) Capture(hdr *tar.Header, r io.Reader) (err error) {
+	if hdr.Typeflag != tar.TypeReg || hdr.Size > gDocFileLimit {
+		return
+	}
+	name := strings.TrimPrefix(path.Clean("/"+hdr.Name), "/")
+	dir, base := path.Split(name)
+	if path.Dir(strings.TrimSuffix(dir, "/")) != "usr/share/doc" {
+		return
+	}
+	if base == gCopyrightName || base == gChangelogNames[0] || base == gChangelogNames[1] {
+		c.files[name], err = io.ReadAll(io.LimitReader(r, gDocFileLimit))
+	}
+	return
+}
+
+// Changelog returns the entries of the preferred changelog file for the named
+// package, if any were captured
+func (c *docFiles) Changelog(name string) (entries []*changelog.Entry, err error) {
+	for _, known := range gChangelogNames {
+		data, present := c.files["usr/share/doc/"+name+"/"+known]
+		if !present {
+			continue
+		}
+		var gz *gzip.Reader
+		if gz, err = gzip.NewReader(bytes.NewReader(data)); err != nil {
+			err = fmt.Errorf("%v: %v", known, err)
+			return
+		}
+		defer gz.Close()
+		var contents []byte
+		if contents, err = io.ReadAll(io.LimitReader(gz, gDocFileLimit)); err != nil {
+			err = fmt.Errorf("%v: %v", known, err)
+			return
+		}
+		entries, err = changelog.Parse(bytes.NewReader(contents))
+		return
+	}
+	return
+}
+
+// Copyright returns the text of the copyright file for the named package and
+// the parsed machine-readable copyright, err is copyright.ErrNotMachineReadable
+// when the text is not in the DEP-5 format
+func (c *docFiles) Copyright(name string) (text string, parsed *copyright.Copyright, err error) {
+	data, present := c.files["usr/share/doc/"+name+"/"+gCopyrightName]
+	if !present {
+		return
+	}
+	text = string(data)
+	parsed, err = copyright.ParseString(text)
+	return
+}
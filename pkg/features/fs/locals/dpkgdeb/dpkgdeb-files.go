@@ -0,0 +1,249 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+	"github.com/go-enjin/be/types/page"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+// gInlineContentTypes are the content types served inline, all other types
+// are served as attachments so that package content is never rendered as
+// part of the site
+var gInlineContentTypes = []string{
+	"text/plain",
+	"image/png",
+	"image/jpeg",
+	"image/gif",
+	"image/webp",
+}
+
+// filesUrl returns the url of the named member of the package data archive
+func filesUrl(dd *dpkgDeb, name string) (u string) {
+	segments := strings.Split(deb.CleanName(name), "/")
+	for idx, segment := range segments {
+		segments[idx] = url.PathEscape(segment)
+	}
+	u = dd.Url + "/files/" + strings.Join(segments, "/")
+	return
+}
+
+// findFile returns the named member of the package data archive
+func findFile(dd *dpkgDeb, name string) (file *deb.File) {
+	name = deb.CleanName(name)
+	for _, file = range dd.Files {
+		if deb.CleanName(file.Name) == name {
+			return
+		}
+	}
+	file = nil
+	return
+}
+
+// isText returns true if the data looks like UTF-8 text
+func isText(data []byte) (ok bool) {
+	ok = utf8.Valid(data) && !bytes.ContainsRune(data, 0)
+	return
+}
+
+// fileContentType returns the content type to serve the named file with,
+// from the file extension or the leading data. Text types are served as
+// plain text
+func fileContentType(name string, head []byte) (contentType string, inline bool) {
+	if contentType = mime.TypeByExtension(path.Ext(name)); contentType == "" {
+		contentType = http.DetectContentType(head)
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if strings.HasPrefix(mediaType, "text/") || (mediaType == "application/octet-stream" && isText(head)) {
+		mediaType = "text/plain"
+		contentType = "text/plain; charset=utf-8"
+	}
+	for _, known := range gInlineContentTypes {
+		if inline = mediaType == known; inline {
+			break
+		}
+	}
+	return
+}
+
+// serveFile handles the files route of the package, returning false when
+// the route is not for a package data archive member
+func (f *CFeature) serveFile(rt *debRoute, w http.ResponseWriter, r *http.Request) (handled bool) {
+	if rt.DD == nil || len(rt.Sub) < 2 || rt.Sub[0] != "files" {
+		return
+	}
+	dd := rt.DD
+
+	name, err := url.PathUnescape(strings.Join(rt.Sub[1:], "/"))
+	if err != nil {
+		return
+	}
+	file := findFile(dd, name)
+	if file == nil {
+		return
+	}
+	name = deb.CleanName(file.Name)
+	handled = true
+
+	switch {
+	case file.IsDir():
+		f.Enjin.ServeRedirect(contentsUrl(dd), w, r)
+		return
+	case file.IsSymlink():
+		target := file.Linkname
+		if !strings.HasPrefix(target, "/") {
+			target = path.Join(path.Dir(name), target)
+		}
+		if findFile(dd, target) == nil {
+			f.Enjin.ServeNotFound(w, r)
+			return
+		}
+		f.Enjin.ServeRedirect(filesUrl(dd, target), w, r)
+		return
+	case file.Type == tar.TypeLink:
+		if file = findFile(dd, file.Linkname); file == nil || !file.IsRegular() {
+			f.Enjin.ServeNotFound(w, r)
+			return
+		}
+	case !file.IsRegular():
+		f.Enjin.ServeNotFound(w, r)
+		return
+	}
+
+	if f.fileSizeLimit > 0 && file.Size > f.fileSizeLimit {
+		log.DebugF("package file exceeds the size limit: %v - %v (%d bytes)", dd.File, name, file.Size)
+		f.Enjin.ServeForbidden(w, r)
+		return
+	}
+
+	_, raw := r.URL.Query()["raw"]
+	if !raw && file.Size <= DefaultInlineTextLimit {
+		if data, ee := deb.ReadDataFile(filepath.Join(dd.MP.Path, dd.File), file.Name, DefaultInlineTextLimit); ee != nil {
+			log.ErrorF("error reading package file: %v - %v: %v", dd.File, name, ee)
+			f.Enjin.ServeInternalServerError(w, r)
+			return
+		} else if isText(data) && f.serveFileTextPage(dd, name, data, w, r) {
+			return
+		}
+	}
+
+	err = deb.WalkData(filepath.Join(dd.MP.Path, dd.File), func(hdr *tar.Header, reader io.Reader) (err error) {
+		if deb.CleanName(hdr.Name) != deb.CleanName(file.Name) {
+			return
+		}
+		br := bufio.NewReaderSize(reader, 512)
+		head, _ := br.Peek(512)
+		contentType, inline := fileContentType(name, head)
+		disposition := "attachment"
+		if inline {
+			disposition = "inline"
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("Content-Length", strconv.FormatInt(hdr.Size, 10))
+		w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": path.Base(name)}))
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Cache-Control", f.getCacheControl())
+		w.WriteHeader(http.StatusOK)
+		if r.Method != http.MethodHead {
+			if _, err = io.CopyN(w, br, hdr.Size); err != nil {
+				err = fmt.Errorf("error writing response: %w", err)
+				return
+			}
+		}
+		err = deb.ErrStopWalk
+		return
+	})
+	if err != nil {
+		log.ErrorF("error serving package file: %v - %v: %v", dd.File, name, err)
+	} else {
+		log.DebugF("served local %v package file: %v - %v", rt.MP.Mount, dd.File, name)
+	}
+	return
+}
+
+// serveFileTextPage serves the text view of the named package file, returning
+// false when the text cannot be rendered as a page and is to be served raw
+func (f *CFeature) serveFileTextPage(dd *dpkgDeb, name string, data []byte, w http.ResponseWriter, r *http.Request) (served bool) {
+	debName, _ := f.makeDebNameUrl(dd.MP.Mount, dd.File)
+	u := filesUrl(dd, name)
+
+	var lines []interface{}
+	for _, line := range strings.Split(strings.TrimSuffix(string(data), "\n"), "\n") {
+		lines = append(lines, line)
+	}
+	section := []interface{}{
+		map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				fmt.Sprintf("%s, %s.&nbsp;", html.EscapeString("/"+name), formatSize(int64(len(data)))),
+				map[string]interface{}{"type": "a", "href": u + "?raw", "text": []interface{}{"Download"}},
+				"&nbsp;|&nbsp;",
+				map[string]interface{}{"type": "a", "href": contentsUrl(dd), "text": []interface{}{"Contents"}},
+			},
+		},
+		map[string]interface{}{"type": "code", "code": lines},
+	}
+
+	var err error
+	var encoded string
+	if encoded, err = MarshalNjn(section); err != nil {
+		log.DebugF("serving package file raw: %v - %v", u, err)
+		return
+	}
+	served = true
+
+	source := fmt.Sprintf(
+		gContentPageTemplate,
+		EscapeJson(path.Base(name))+" - "+debName, EscapeJson("/"+name+" from "+debName), EscapeJson(u),
+		debName,
+		"package-file",
+		EscapeJson(html.EscapeString(path.Base(name))),
+		encoded,
+	)
+
+	var p feature.Page
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), u, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		log.ErrorF("error making new package file page: %v - %v", u, err)
+		f.Enjin.ServeInternalServerError(w, r)
+		return
+	}
+	p.SetSlugUrl(u)
+	p.Context().SetSpecific("CacheControl", f.getCacheControl())
+	if err = f.Enjin.ServePage(p, w, r); err != nil {
+		log.ErrorF("error serving package file page: %v - %v", u, err)
+	}
+	return
+}
@@ -0,0 +1,92 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"path/filepath"
+	"sort"
+	"strings"
+
+	beContext "github.com/go-enjin/be/pkg/context"
+	"github.com/go-enjin/be/pkg/feature"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/version"
+)
+
+func (f *CFeature) MakeFuncMap(ctx beContext.Context) (fm feature.FuncMap) {
+	fm = feature.FuncMap{
+		"sortDebFiles":     f.SortDebFiles,
+		"aptDistributions": f.AptDistributions,
+	}
+	return
+}
+
+// AptDistributions returns the distributions published within the mount
+// point with the given url path, sorted by codename
+func (f *CFeature) AptDistributions(mount string) (dists []*distRelease) {
+	for _, mp := range f.mount {
+		if mp.Mount == mount {
+			dists = f.Distributions(mp)
+			return
+		}
+	}
+	return
+}
+
+// SortDebFiles returns a copy of the given .deb file paths sorted by package
+// name and newest version first
+func (f *CFeature) SortDebFiles(files []string) (sorted []string) {
+	type debFile struct {
+		path, name, version string
+	}
+	list := make([]debFile, len(files))
+	for idx, file := range files {
+		list[idx].path = file
+		list[idx].name, list[idx].version = f.debFileNameVersion(file)
+	}
+	sort.SliceStable(list, func(i, j int) (less bool) {
+		a, b := list[i], list[j]
+		if a.name != b.name {
+			return a.name < b.name
+		} else if cmp := version.Compare(a.version, b.version); cmp != 0 {
+			return cmp > 0
+		}
+		return a.path < b.path
+	})
+	for _, item := range list {
+		sorted = append(sorted, item.path)
+	}
+	return
+}
+
+// debFileNameVersion returns the package name and version of the given .deb
+// file, from the package store if present, otherwise from the conventional
+// name_version_arch.deb file name which does not include any epoch
+func (f *CFeature) debFileNameVersion(file string) (name, ver string) {
+	base := filepath.Base(file)
+	for _, mp := range f.mount {
+		_, url := f.makeDebNameUrl(mp.Mount, base)
+		if dd, ok := f.store.Get(url); ok {
+			name, ver = dd.Name, dd.Version
+			return
+		}
+	}
+	parts := strings.Split(strings.TrimSuffix(base, filepath.Ext(base)), "_")
+	name = parts[0]
+	if len(parts) > 1 {
+		ver = parts[1]
+	}
+	return
+}
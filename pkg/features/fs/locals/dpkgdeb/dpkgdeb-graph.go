@@ -0,0 +1,265 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/go-enjin/be/pkg/log"
+)
+
+const (
+	gGraphCharWidth  = 7
+	gGraphLineHeight = 16
+	gGraphNodeHeight = 2*gGraphLineHeight + 8
+	gGraphPadding    = 8
+	gGraphColumnGap  = 64
+	gGraphRowGap     = 16
+)
+
+// graphNode is a package, or an unresolved dependency, within a dependency
+// graph
+type graphNode struct {
+	Label   string
+	Detail  string
+	Url     string
+	Missing bool
+	Level   int
+}
+
+// graphEdge is a dependency between two graph nodes
+type graphEdge struct {
+	From  int
+	To    int
+	Field string
+}
+
+// dependencyGraph is the transitive closure of the Pre-Depends and Depends
+// of a package within its archive
+type dependencyGraph struct {
+	Nodes []*graphNode
+	Edges []*graphEdge
+}
+
+// makeDependencyGraph returns the dependency graph of the package, following
+// the first resolvable alternative of each dependency and preferring the
+// architecture of the package. Dependencies which do not resolve within the
+// archive are included as missing leaf nodes
+func (f *CFeature) makeDependencyGraph(ix *relationIndex, dd *dpkgDeb) (g *dependencyGraph) {
+	g = &dependencyGraph{}
+	lookup := make(map[string]int)
+	addNode := func(key string, node *graphNode) (idx int, added bool) {
+		if idx, present := lookup[key]; present {
+			return idx, false
+		}
+		idx = len(g.Nodes)
+		lookup[key] = idx
+		g.Nodes = append(g.Nodes, node)
+		return idx, true
+	}
+	addEdge := func(edge *graphEdge) {
+		for _, existing := range g.Edges {
+			if existing.From == edge.From && existing.To == edge.To {
+				return
+			}
+		}
+		g.Edges = append(g.Edges, edge)
+	}
+
+	root, _ := addNode(dd.Url, &graphNode{Label: dd.Name, Detail: dd.Version + " " + dd.Architecture, Url: dd.Url})
+	queue := []*dpkgDeb{dd}
+	indices := []int{root}
+	for len(queue) > 0 {
+		current, from := queue[0], indices[0]
+		queue, indices = queue[1:], indices[1:]
+		for _, field := range gGraphFields {
+			for _, alternatives := range relationsOf(current, field) {
+				var target *dpkgDeb
+				for _, relation := range alternatives {
+					if target = ix.best(dd.Architecture, ix.resolve(current, relation)); target != nil {
+						break
+					}
+				}
+				level := g.Nodes[from].Level + 1
+				if target == nil {
+					label := alternatives.String()
+					to, _ := addNode("missing:"+label, &graphNode{Label: label, Detail: "not in archive", Missing: true, Level: level})
+					addEdge(&graphEdge{From: from, To: to, Field: field})
+					continue
+				}
+				to, added := addNode(target.Url, &graphNode{Label: target.Name, Detail: target.Version + " " + target.Architecture, Url: target.Url, Level: level})
+				addEdge(&graphEdge{From: from, To: to, Field: field})
+				if added {
+					queue = append(queue, target)
+					indices = append(indices, to)
+				}
+			}
+		}
+	}
+	return
+}
+
+// quoteDot returns the string as a quoted DOT identifier
+func quoteDot(value string) (quoted string) {
+	quoted = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value) + `"`
+	return
+}
+
+// DOT returns the graph in the Graphviz DOT language
+func (g *dependencyGraph) DOT(name string) (output string) {
+	var b strings.Builder
+	b.WriteString("digraph " + quoteDot(name) + " {\n")
+	b.WriteString("\trankdir=LR;\n")
+	b.WriteString("\tnode [shape=box];\n")
+	for idx, node := range g.Nodes {
+		attrs := []string{"label=" + quoteDot(node.Label+"\n"+node.Detail)}
+		if node.Url != "" {
+			attrs = append(attrs, "URL="+quoteDot(node.Url))
+		}
+		if node.Missing {
+			attrs = append(attrs, "style=dashed")
+		}
+		b.WriteString(fmt.Sprintf("\tn%d [%s];\n", idx, strings.Join(attrs, ", ")))
+	}
+	for _, edge := range g.Edges {
+		attrs := ""
+		if edge.Field != "Depends" {
+			attrs = " [label=" + quoteDot(edge.Field) + "]"
+		}
+		b.WriteString(fmt.Sprintf("\tn%d -> n%d%s;\n", edge.From, edge.To, attrs))
+	}
+	b.WriteString("}\n")
+	output = b.String()
+	return
+}
+
+// SVG returns the graph drawn as an SVG image, with a column of nodes per
+// level of dependencies
+func (g *dependencyGraph) SVG() (output string) {
+	var columns [][]int
+	for idx, node := range g.Nodes {
+		for len(columns) <= node.Level {
+			columns = append(columns, nil)
+		}
+		columns[node.Level] = append(columns[node.Level], idx)
+	}
+
+	nodeWidth := func(node *graphNode) (width int) {
+		chars := max(utf8.RuneCountInString(node.Label), utf8.RuneCountInString(node.Detail))
+		width = chars*gGraphCharWidth + 2*gGraphPadding
+		return
+	}
+
+	xs, ys, widths := make([]int, len(g.Nodes)), make([]int, len(g.Nodes)), make([]int, len(g.Nodes))
+	var width, height int
+	for _, column := range columns {
+		var columnWidth int
+		for _, idx := range column {
+			columnWidth = max(columnWidth, nodeWidth(g.Nodes[idx]))
+		}
+		for row, idx := range column {
+			xs[idx] = width + gGraphPadding
+			ys[idx] = gGraphPadding + row*(gGraphNodeHeight+gGraphRowGap)
+			widths[idx] = columnWidth
+		}
+		width += columnWidth + gGraphColumnGap
+		height = max(height, len(column)*(gGraphNodeHeight+gGraphRowGap))
+	}
+	width += 2*gGraphPadding - gGraphColumnGap
+	height += 2*gGraphPadding - gGraphRowGap
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" width="%d" height="%d" viewBox="0 0 %d %d" font-family="monospace" font-size="12">`+"\n", width, height, width, height))
+	b.WriteString(`<defs><marker id="arrow" viewBox="0 0 10 10" refX="10" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M 0 0 L 10 5 L 0 10 z" fill="#555"/></marker></defs>` + "\n")
+	b.WriteString(`<rect width="100%" height="100%" fill="#fff"/>` + "\n")
+
+	for _, edge := range g.Edges {
+		x1, y1 := xs[edge.From]+widths[edge.From], ys[edge.From]+gGraphNodeHeight/2
+		x2, y2 := xs[edge.To], ys[edge.To]+gGraphNodeHeight/2
+		mid := (x1 + x2) / 2
+		dash := ""
+		if edge.Field != "Depends" {
+			dash = ` stroke-dasharray="2,2"`
+		}
+		b.WriteString(fmt.Sprintf(`<path d="M %d %d C %d %d, %d %d, %d %d" fill="none" stroke="#555"%s marker-end="url(#arrow)"><title>%s</title></path>`+"\n", x1, y1, mid, y1, mid, y2, x2, y2, dash, html.EscapeString(edge.Field)))
+	}
+
+	for idx, node := range g.Nodes {
+		x, y := xs[idx], ys[idx]
+		stroke, dash := "#333", ""
+		if node.Missing {
+			stroke, dash = "#999", ` stroke-dasharray="4,3"`
+		}
+		var shape strings.Builder
+		shape.WriteString(fmt.Sprintf(`<rect x="%d" y="%d" width="%d" height="%d" rx="4" fill="#f8f8f8" stroke="%s"%s/>`, x, y, widths[idx], gGraphNodeHeight, stroke, dash))
+		shape.WriteString(fmt.Sprintf(`<text x="%d" y="%d" font-weight="bold">%s</text>`, x+gGraphPadding, y+gGraphPadding+gGraphLineHeight-4, html.EscapeString(node.Label)))
+		shape.WriteString(fmt.Sprintf(`<text x="%d" y="%d" fill="#666">%s</text>`, x+gGraphPadding, y+gGraphPadding+2*gGraphLineHeight-4, html.EscapeString(node.Detail)))
+		if node.Url != "" {
+			b.WriteString(fmt.Sprintf(`<a href="%s" xlink:href="%s">%s</a>`+"\n", html.EscapeString(node.Url), html.EscapeString(node.Url), shape.String()))
+		} else {
+			b.WriteString(shape.String() + "\n")
+		}
+	}
+
+	b.WriteString("</svg>\n")
+	output = b.String()
+	return
+}
+
+// serveGraph handles the graph.dot and graph.svg routes of the package,
+// returning false when the route is not for a dependency graph
+func (f *CFeature) serveGraph(rt *debRoute, w http.ResponseWriter, r *http.Request) (handled bool) {
+	if rt.DD == nil || len(rt.Sub) != 1 {
+		return
+	}
+	var contentType string
+	switch rt.Sub[0] {
+	case "graph.dot":
+		contentType = "text/vnd.graphviz; charset=utf-8"
+	case "graph.svg":
+		contentType = "image/svg+xml"
+	default:
+		return
+	}
+	handled = true
+	dd := rt.DD
+
+	ix := f.relationIndex(dd.MP)
+	g := f.makeDependencyGraph(ix, dd)
+	var output string
+	if contentType == "image/svg+xml" {
+		output = g.SVG()
+	} else {
+		output = g.DOT(dd.Name + "_" + dd.Version + "_" + dd.Architecture)
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", strconv.Itoa(len(output)))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		if _, err := w.Write([]byte(output)); err != nil {
+			log.ErrorF("error writing dependency graph: %v - %v", dd.Url, err)
+			return
+		}
+	}
+	log.DebugF("served local %v dependency graph: %v - %v", rt.MP.Mount, dd.File, rt.Sub[0])
+	return
+}
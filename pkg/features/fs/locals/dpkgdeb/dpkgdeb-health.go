@@ -0,0 +1,433 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+	"github.com/go-enjin/be/types/page"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/resolver"
+)
+
+// healthEntry is an uninstallable package listed in a Packages index
+type healthEntry struct {
+	Name         string   `json:"name"`
+	Version      string   `json:"version"`
+	Architecture string   `json:"architecture"`
+	Filename     string   `json:"filename"`
+	Problems     []string `json:"problems"`
+}
+
+// healthDist is the installability of the packages of one codename and
+// architecture
+type healthDist struct {
+	Codename     string `json:"codename"`
+	Architecture string `json:"architecture"`
+	// BaseSystem is true when the upstream base system packages were
+	// included, otherwise dependencies on packages not within the archive
+	// are assumed to be met
+	BaseSystem    bool           `json:"base_system"`
+	Checked       int            `json:"checked"`
+	Uninstallable []*healthEntry `json:"uninstallable"`
+}
+
+// healthReport is the installability of all the packages of a mount point
+type healthReport struct {
+	Mount   string        `json:"mount"`
+	Updated time.Time     `json:"updated"`
+	Dists   []*healthDist `json:"dists"`
+	// Problems are the index files and entries which could not be read
+	Problems []string `json:"problems,omitempty"`
+
+	signature string
+}
+
+// Uninstallable returns the number of uninstallable package entries
+func (report *healthReport) Uninstallable() (count int) {
+	for _, dist := range report.Dists {
+		count += len(dist.Uninstallable)
+	}
+	return
+}
+
+// healthInputs returns the Packages indices of the mount point grouped by
+// codename and architecture, the base system index files per codename and a
+// signature of all the files which changes when any of them are modified
+func (f *CFeature) healthInputs(mp *feature.CMountPoint) (indices map[string]map[string][]string, base map[string][]string, signature string) {
+	indices = make(map[string]map[string][]string)
+	base = make(map[string][]string)
+	files := packageIndices(mp)
+
+	for _, index := range files {
+		dir := filepath.Dir(index)
+		arch := strings.TrimPrefix(filepath.Base(dir), "binary-")
+		codename := filepath.Base(filepath.Dir(filepath.Dir(dir)))
+		if _, present := indices[codename]; !present {
+			indices[codename] = make(map[string][]string)
+		}
+		indices[codename][arch] = append(indices[codename][arch], index)
+	}
+
+	for codename := range indices {
+		for _, pattern := range f.basePackages[mp.Path][codename] {
+			matches, err := filepath.Glob(pattern)
+			if err != nil {
+				log.ErrorF("error matching base system packages: %v - %v", pattern, err)
+				continue
+			}
+			sort.Strings(matches)
+			base[codename] = append(base[codename], matches...)
+			files = append(files, matches...)
+		}
+	}
+
+	signature = statSignature(files...)
+	return
+}
+
+// checkHealth returns the installability report of the mount point
+func (f *CFeature) checkHealth(mp *feature.CMountPoint, indices map[string]map[string][]string, base map[string][]string) (report *healthReport) {
+	report = &healthReport{Mount: mp.Mount, Updated: time.Now()}
+
+	// the Packages indices of the mount point are shared with the integrity
+	// and distributions checks, base system files may be compressed
+	read := func(path string, isBase bool) (packages []*resolver.Package) {
+		var problems []string
+		var err error
+		if isBase {
+			packages, problems, err = resolver.ReadIndex(path, true)
+		} else {
+			var paragraphs []*control.Paragraph
+			if paragraphs, err = f.packageIndex(path); err == nil {
+				packages, problems = resolver.NewPackages(paragraphs, false)
+			}
+		}
+		if err != nil {
+			report.Problems = append(report.Problems, fmt.Sprintf("%v: %v", path, err))
+			return
+		}
+		for _, problem := range problems {
+			report.Problems = append(report.Problems, fmt.Sprintf("%v: %v", path, problem))
+		}
+		return
+	}
+
+	var codenames []string
+	for codename := range indices {
+		codenames = append(codenames, codename)
+	}
+	sort.Strings(codenames)
+
+	for _, codename := range codenames {
+		var basePackages []*resolver.Package
+		for _, path := range base[codename] {
+			basePackages = append(basePackages, read(path, true)...)
+		}
+
+		var arches []string
+		for arch := range indices[codename] {
+			arches = append(arches, arch)
+		}
+		sort.Strings(arches)
+
+		// each architecture is checked with all the packages of the codename
+		// so that names only available for other architectures are known,
+		// "all" packages are listed in every architecture index
+		var packages []*resolver.Package
+		filenames := make(map[string]struct{})
+		for _, arch := range arches {
+			for _, path := range indices[codename][arch] {
+				for _, pkg := range read(path, false) {
+					if _, present := filenames[pkg.Filename]; !present {
+						filenames[pkg.Filename] = struct{}{}
+						packages = append(packages, pkg)
+					}
+				}
+			}
+		}
+
+		for _, arch := range arches {
+			dist := &healthDist{
+				Codename:      codename,
+				Architecture:  arch,
+				BaseSystem:    len(basePackages) > 0,
+				Uninstallable: make([]*healthEntry, 0),
+			}
+			for _, pkg := range packages {
+				if pkg.Architecture == arch || pkg.Architecture == "all" {
+					dist.Checked += 1
+				}
+			}
+			for _, result := range resolver.Check(arch, append(packages, basePackages...), dist.BaseSystem) {
+				dist.Uninstallable = append(dist.Uninstallable, &healthEntry{
+					Name:         result.Package.Name,
+					Version:      result.Package.Version,
+					Architecture: result.Package.Architecture,
+					Filename:     deb.CleanName(result.Package.Filename),
+					Problems:     result.Problems,
+				})
+			}
+			report.Dists = append(report.Dists, dist)
+		}
+	}
+	return
+}
+
+// updateHealth checks the installability of the packages of each mount point
+// with modified Packages indices or base system files, returning true if any
+// report changed
+func (f *CFeature) updateHealth() (changed bool) {
+	for _, mp := range f.mount {
+		indices, base, signature := f.healthInputs(mp)
+
+		f.healthLock.RLock()
+		previous := f.health[mp.Mount]
+		f.healthLock.RUnlock()
+		if previous != nil && previous.signature == signature {
+			continue
+		}
+
+		start := time.Now()
+		report := f.checkHealth(mp, indices, base)
+		report.signature = signature
+		f.healthLock.Lock()
+		f.health[mp.Mount] = report
+		f.healthLock.Unlock()
+		changed = true
+
+		for _, problem := range report.Problems {
+			log.WarnF("dpkg-deb installability check problem: %v", problem)
+		}
+		if count := report.Uninstallable(); count > 0 {
+			log.WarnF("dpkg-deb installability check: %d uninstallable packages in %v", count, mp.Mount)
+		}
+		log.DebugF("dpkg-deb installability checked for %v in %v", mp.Mount, time.Now().Sub(start))
+	}
+	return
+}
+
+// healthReport returns the current installability report of the mount point
+func (f *CFeature) healthReport(mp *feature.CMountPoint) (report *healthReport) {
+	f.healthLock.RLock()
+	defer f.healthLock.RUnlock()
+	report = f.health[mp.Mount]
+	return
+}
+
+// InstallabilityIssues returns the problems found installing the package
+// from each codename and architecture it is listed in
+func (f *CFeature) InstallabilityIssues(dd *dpkgDeb) (issues []string) {
+	report := f.healthReport(dd.MP)
+	if report == nil {
+		return
+	}
+	filename := deb.CleanName(dd.File)
+	for _, dist := range report.Dists {
+		for _, entry := range dist.Uninstallable {
+			if entry.Filename != filename {
+				continue
+			}
+			for _, problem := range entry.Problems {
+				issues = append(issues, dist.Codename+"/"+dist.Architecture+": "+problem)
+			}
+		}
+	}
+	return
+}
+
+// makeInstallabilityNotice returns the JSON encoded njn fields listing the
+// installability issues of the package, or an empty string when there are
+// none
+func (f *CFeature) makeInstallabilityNotice(dd *dpkgDeb) (output string, err error) {
+	issues := f.InstallabilityIssues(dd)
+	if len(issues) == 0 {
+		return
+	}
+	var list []interface{}
+	for _, issue := range issues {
+		list = append(list, html.EscapeString(issue))
+	}
+	fields := []interface{}{
+		map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				map[string]interface{}{
+					"type": "mark",
+					"text": []interface{}{
+						map[string]interface{}{"type": "strong", "text": []interface{}{"This package can not be installed from this archive"}},
+					},
+				},
+				"&nbsp;(",
+				map[string]interface{}{"type": "a", "href": healthUrl(dd.MP), "text": []interface{}{"health report"}},
+				")",
+			},
+		},
+		map[string]interface{}{"type": "ul", "list": list},
+	}
+	var encoded []string
+	for _, field := range fields {
+		var data string
+		if data, err = MarshalNjn(field); err != nil {
+			return
+		}
+		encoded = append(encoded, data)
+	}
+	output = strings.Join(encoded, ",")
+	return
+}
+
+// healthUrl returns the url of the installability report page of the mount
+// point
+func healthUrl(mp *feature.CMountPoint) (url string) {
+	url = reservedUrl(mp, "health")
+	return
+}
+
+// cachedHealthPage returns a copy of the installability report page for the
+// given mount point and language, making and caching it when the store
+// changes
+func (f *CFeature) cachedHealthPage(r *http.Request, tag language.Tag, mp *feature.CMountPoint) (p feature.Page, err error) {
+	url := healthUrl(mp)
+	generation := f.store.Generation()
+	if cached, ok := f.pages.Get(url, tag, generation); ok {
+		p = cached
+		return
+	}
+	if p, err = f.makeHealthPage(r, mp); err != nil {
+		return
+	}
+	f.pages.Put(url, tag, generation, p)
+	p = p.Copy()
+	return
+}
+
+func (f *CFeature) makeHealthPage(r *http.Request, mp *feature.CMountPoint) (p feature.Page, err error) {
+	url := healthUrl(mp)
+	report := f.healthReport(mp)
+
+	var section []interface{}
+	var summary string
+	if report == nil || len(report.Dists) == 0 {
+		summary = "Not checked"
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": "No Packages indices have been checked yet.",
+		})
+	} else {
+		summary = fmt.Sprintf("%d uninstallable", report.Uninstallable())
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": "Checked " + report.Updated.UTC().Format(time.RFC1123) + ".",
+		})
+
+		for _, dist := range report.Dists {
+			scope := "dependencies on packages outside of this archive are assumed to be met"
+			if dist.BaseSystem {
+				scope = "checked against the base system packages"
+			}
+			section = append(section, map[string]interface{}{
+				"type": "p",
+				"text": []interface{}{
+					map[string]interface{}{"type": "strong", "text": []interface{}{html.EscapeString(dist.Codename + "/" + dist.Architecture + ":")}},
+					fmt.Sprintf("&nbsp;%d of %d packages uninstallable, %s.", len(dist.Uninstallable), dist.Checked, scope),
+				},
+			})
+			if len(dist.Uninstallable) == 0 {
+				continue
+			}
+
+			var rows []interface{}
+			for _, entry := range dist.Uninstallable {
+				name := []interface{}{html.EscapeString(entry.Name)}
+				if dd, present := f.store.Get(mp.Mount + "/" + filepath.Base(entry.Filename)); present {
+					name = []interface{}{map[string]interface{}{"type": "a", "href": dd.Url, "text": name}}
+				}
+				var problems []interface{}
+				for _, problem := range entry.Problems {
+					problems = append(problems, html.EscapeString(problem))
+				}
+				rows = append(rows, map[string]interface{}{
+					"type": "tr",
+					"data": []interface{}{
+						map[string]interface{}{"type": "td", "text": name},
+						map[string]interface{}{"type": "td", "text": html.EscapeString(entry.Version)},
+						map[string]interface{}{"type": "td", "text": html.EscapeString(entry.Architecture)},
+						map[string]interface{}{"type": "td", "text": []interface{}{
+							map[string]interface{}{"type": "ul", "list": problems},
+						}},
+					},
+				})
+			}
+			section = append(section, map[string]interface{}{
+				"type": "table",
+				"head": []interface{}{
+					map[string]interface{}{"type": "th", "text": "Package"},
+					map[string]interface{}{"type": "th", "text": "Version"},
+					map[string]interface{}{"type": "th", "text": "Architecture"},
+					map[string]interface{}{"type": "th", "text": "Problems"},
+				},
+				"body": rows,
+			})
+		}
+
+		if len(report.Problems) > 0 {
+			var problems []interface{}
+			for _, problem := range report.Problems {
+				problems = append(problems, html.EscapeString(problem))
+			}
+			section = append(section,
+				map[string]interface{}{"type": "p", "text": "The following index entries could not be read:"},
+				map[string]interface{}{"type": "ul", "list": problems},
+			)
+		}
+	}
+
+	var data string
+	if data, err = MarshalNjn(section); err != nil {
+		err = fmt.Errorf("error encoding health page: %v - %v", url, err)
+		return
+	}
+
+	source := fmt.Sprintf(
+		gContentPageTemplate,
+		"Health", "Installability of the Debian packages available from "+mp.Mount, url,
+		"Health",
+		"installability",
+		summary,
+		data,
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), url, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new health page: %v - %v", url, err)
+		return
+	}
+	p.SetSlugUrl(url)
+	return
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"embed"
+	"fmt"
+
+	beEmbed "github.com/go-enjin/be/drivers/fs/embed"
+	"github.com/go-enjin/be/pkg/feature"
+)
+
+// staticFs holds the syntax highlighter of the decorated code blocks, which
+// the control archive block marks with the language of each script
+//
+//go:embed static/**
+var staticFs embed.FS
+
+const (
+	gHighlightStyleTmpl  = `<link rel="stylesheet" href="{{ fsUrl "/dpkgdeb/highlight.css" }}"/>`
+	gHighlightScriptTmpl = `<script src="{{ fsUrl "/dpkgdeb/highlight.js" }}" defer></script>`
+)
+
+// registerHighlighter serves the syntax highlighter assets and includes them
+// in every page layout
+func (f *CFeature) registerHighlighter(b feature.Buildable) (err error) {
+	var static beEmbed.FileSystem
+	if static, err = beEmbed.New(f.Tag().String(), "static", staticFs); err != nil {
+		err = fmt.Errorf("error mounting highlighter assets: %v", err)
+		return
+	}
+	b.RegisterPublicFileSystem("/", static)
+	if err = b.RegisterTemplatePartial("head", "tail", "dpkgdeb-highlight-style", gHighlightStyleTmpl); err != nil {
+		return
+	}
+	err = b.RegisterTemplatePartial("body", "tail", "dpkgdeb-highlight-script", gHighlightScriptTmpl)
+	return
+}
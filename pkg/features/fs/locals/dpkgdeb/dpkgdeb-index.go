@@ -0,0 +1,188 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/types/page"
+)
+
+// packageVersion is all the package files of a single version
+type packageVersion struct {
+	Version  string
+	Packages []*dpkgDeb
+}
+
+// packageGroup is all the versions of a single package name, newest first
+type packageGroup struct {
+	Name          string
+	Latest        *dpkgDeb
+	Architectures []string
+	Versions      []*packageVersion
+}
+
+// groupPackages returns the given packages grouped by name, sorted by name
+func groupPackages(list []*dpkgDeb) (groups []*packageGroup) {
+	sorted := append([]*dpkgDeb{}, list...)
+	sortPackages(sorted)
+
+	var group *packageGroup
+	var pv *packageVersion
+	for _, dd := range sorted {
+		if group == nil || group.Name != dd.Name {
+			// newest version is first
+			group = &packageGroup{Name: dd.Name, Latest: dd}
+			groups = append(groups, group)
+			pv = nil
+		}
+		if !slices.Contains(group.Architectures, dd.Architecture) {
+			group.Architectures = append(group.Architectures, dd.Architecture)
+		}
+		if pv == nil || pv.Version != dd.Version {
+			pv = &packageVersion{Version: dd.Version}
+			group.Versions = append(group.Versions, pv)
+		}
+		pv.Packages = append(pv.Packages, dd)
+	}
+
+	for _, group = range groups {
+		sort.Strings(group.Architectures)
+	}
+	return
+}
+
+// findMountPoint returns the mount point with the given mount path
+func (f *CFeature) findMountPoint(mount string) (mp *feature.CMountPoint) {
+	for _, mp = range f.mount {
+		if mp.Mount == mount {
+			return
+		}
+	}
+	mp = nil
+	return
+}
+
+// makePackageTable returns the njn table listing the given package groups
+// with links to their package pages and package files
+func makePackageTable(mp *feature.CMountPoint, groups []*packageGroup) (table map[string]interface{}) {
+	var rows []interface{}
+	for _, group := range groups {
+		var versions []interface{}
+		for _, pv := range group.Versions {
+			for _, dd := range pv.Packages {
+				label := pv.Version
+				if len(pv.Packages) > 1 {
+					label += " [" + dd.Component + "/" + dd.Architecture + "]"
+				}
+				versions = append(versions, map[string]interface{}{
+					"type": "a",
+					"href": dd.Url,
+					"text": html.EscapeString(label),
+				})
+			}
+		}
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "a", "href": packageUrl(mp, group.Name), "text": html.EscapeString(group.Name)},
+				}},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(group.Latest.Control.Synopsis())},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(group.Latest.Version)},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(strings.Join(group.Architectures, ", "))},
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "ul", "list": versions},
+				}},
+			},
+		})
+	}
+	table = map[string]interface{}{
+		"type": "table",
+		"head": []interface{}{
+			map[string]interface{}{"type": "th", "text": "Package"},
+			map[string]interface{}{"type": "th", "text": "Summary"},
+			map[string]interface{}{"type": "th", "text": "Latest"},
+			map[string]interface{}{"type": "th", "text": "Architectures"},
+			map[string]interface{}{"type": "th", "text": "Versions"},
+		},
+		"body": rows,
+	}
+	return
+}
+
+// cachedIndexPage returns a copy of the package index page for the given
+// mount point and language, making and caching it when the store changes
+func (f *CFeature) cachedIndexPage(r *http.Request, tag language.Tag, mp *feature.CMountPoint) (p feature.Page, err error) {
+	generation := f.store.Generation()
+	if cached, ok := f.pages.Get(mp.Mount, tag, generation); ok {
+		p = cached
+		return
+	}
+	if p, err = f.makeIndexPage(r, mp); err != nil {
+		return
+	}
+	f.pages.Put(mp.Mount, tag, generation, p)
+	p = p.Copy()
+	return
+}
+
+func (f *CFeature) makeIndexPage(r *http.Request, mp *feature.CMountPoint) (p feature.Page, err error) {
+	groups := groupPackages(f.store.Query(packageQuery{MP: mp}))
+
+	var section []interface{}
+	if len(groups) == 0 {
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": "No packages found.",
+		})
+	} else {
+		section = append(section, makePackageTable(mp, groups))
+	}
+	section = append(section, f.makeSourceIndexSection(mp)...)
+
+	var data string
+	if data, err = MarshalNjn(section); err != nil {
+		err = fmt.Errorf("error encoding index page: %v - %v", mp.Mount, err)
+		return
+	}
+
+	source := fmt.Sprintf(
+		gContentPageTemplate,
+		"Packages", "Debian packages available from "+mp.Mount, mp.Mount,
+		"Packages",
+		"package-index",
+		fmt.Sprintf("%d packages", len(groups)),
+		data,
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), mp.Mount, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new index page: %v - %v", mp.Mount, err)
+		return
+	}
+	p.SetSlugUrl(mp.Mount)
+	return
+}
@@ -0,0 +1,89 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/go-enjin/be/pkg/feature"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+// gPackageIndexGlob matches the uncompressed Packages indices within a mount
+// point
+var gPackageIndexGlob = filepath.Join("dists", "*", "*", "binary-*", "Packages")
+
+// parsedIndex is a Packages index along with the signature of the file when
+// it was parsed
+type parsedIndex struct {
+	signature  string
+	paragraphs []*control.Paragraph
+}
+
+// packageIndices returns the uncompressed Packages indices within the mount
+// point, excluding those reached through suite symlinks so that each index is
+// listed once
+func packageIndices(mp *feature.CMountPoint) (indices []string) {
+	found, _ := filepath.Glob(filepath.Join(mp.Path, gPackageIndexGlob))
+	for _, index := range found {
+		if !isSuiteLink(filepath.Dir(filepath.Dir(filepath.Dir(index)))) {
+			indices = append(indices, index)
+		}
+	}
+	sort.Strings(indices)
+	return
+}
+
+// statSignature returns a signature of the size and modification time of the
+// given files which changes when any are added, removed or modified
+func statSignature(paths ...string) (signature string) {
+	var stats []string
+	for _, path := range paths {
+		if info, err := os.Stat(path); err == nil {
+			stats = append(stats, fmt.Sprintf("%s:%d:%d", path, info.Size(), info.ModTime().UnixNano()))
+		}
+	}
+	signature = strings.Join(stats, "\n")
+	return
+}
+
+// packageIndex returns the paragraphs of the given Packages index, only
+// parsing the file again when its signature changes. The paragraphs are
+// shared by all callers and must not be modified
+func (f *CFeature) packageIndex(path string) (paragraphs []*control.Paragraph, err error) {
+	signature := statSignature(path)
+
+	f.parsedLock.Lock()
+	defer f.parsedLock.Unlock()
+	if previous, present := f.parsed[path]; present && previous.signature == signature {
+		paragraphs = previous.paragraphs
+		return
+	}
+
+	var data []byte
+	if data, err = os.ReadFile(path); err != nil {
+		return
+	}
+	if paragraphs, err = control.ParseString(string(data)); err != nil {
+		return
+	}
+	f.parsed[path] = &parsedIndex{signature: signature, paragraphs: paragraphs}
+	return
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"slices"
+	"strings"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+// packageIndexDigests is the readPackageIndices result of a mount point along
+// with the signature of the Packages indices read
+type packageIndexDigests struct {
+	signature string
+	digests   map[string]string
+}
+
+// indexDigests returns the readPackageIndices digests of the mount point,
+// only reading the Packages indices again when their signature changes. Must
+// only be called while scanning
+func (f *CFeature) indexDigests(mp *feature.CMountPoint) (digests map[string]string) {
+	signature := statSignature(packageIndices(mp)...)
+	if previous, present := f.indexed[mp.Mount]; present && previous.signature == signature {
+		digests = previous.digests
+		return
+	}
+	digests = f.readPackageIndices(mp)
+	f.indexed[mp.Mount] = &packageIndexDigests{signature: signature, digests: digests}
+	log.DebugF("dpkg-deb packages indices read for %v: %d files listed", mp.Mount, len(digests))
+	return
+}
+
+// readPackageIndices returns the SHA256 digests listed in the Packages indices
+// within the mount point, keyed by Filename. Differing digests listed for the
+// same file are joined with commas
+func (f *CFeature) readPackageIndices(mp *feature.CMountPoint) (digests map[string]string) {
+	digests = make(map[string]string)
+	for _, index := range packageIndices(mp) {
+		paragraphs, err := f.packageIndex(index)
+		if err != nil {
+			log.ErrorF("error reading packages index: %v - %v", index, err)
+			continue
+		}
+		for _, paragraph := range paragraphs {
+			filename, sha256 := paragraph.Value("Filename"), paragraph.Value("SHA256")
+			if filename == "" || sha256 == "" {
+				continue
+			}
+			filename = deb.CleanName(filename)
+			if existing := digests[filename]; existing == "" {
+				digests[filename] = sha256
+			} else if !slices.Contains(strings.Split(existing, ","), sha256) {
+				digests[filename] = existing + "," + sha256
+			}
+		}
+	}
+	return
+}
+
+// verifyMd5sums returns the problems found comparing the hashed data archive
+// with the md5sums control file, packages without md5sums are not verified
+func verifyMd5sums(pkg *deb.Package, hasher *deb.DataHasher) (problems []string) {
+	cf := pkg.ControlFile("md5sums")
+	if cf == nil {
+		return
+	}
+	expected, err := deb.ParseMd5sums(cf.Data)
+	if err != nil {
+		problems = append(problems, err.Error())
+		return
+	}
+	problems = hasher.Verify(expected)
+	return
+}
+
+// IntegrityIssues returns the problems found verifying the data archive with
+// the md5sums and the package file with the Packages indices
+func (dd *dpkgDeb) IntegrityIssues() (issues []string) {
+	issues = append(issues, dd.DataIssues...)
+	if dd.IndexSHA256 != "" && dd.Sums != nil && dd.IndexSHA256 != dd.Sums.SHA256 {
+		issues = append(issues, fmt.Sprintf("SHA256 mismatch with the dists/ Packages index, expected %s got %s", dd.IndexSHA256, dd.Sums.SHA256))
+	}
+	return
+}
+
+// makeIntegrityNotice returns the JSON encoded njn fields listing the
+// integrity issues of the package, or an empty string when there are none
+func makeIntegrityNotice(dd *dpkgDeb) (output string, err error) {
+	issues := dd.IntegrityIssues()
+	if len(issues) == 0 {
+		return
+	}
+	var list []interface{}
+	for _, issue := range issues {
+		list = append(list, html.EscapeString(issue))
+	}
+	fields := []interface{}{
+		map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				map[string]interface{}{
+					"type": "mark",
+					"text": []interface{}{
+						map[string]interface{}{"type": "strong", "text": []interface{}{"Integrity verification failed, this package may be corrupt"}},
+					},
+				},
+			},
+		},
+		map[string]interface{}{"type": "ul", "list": list},
+	}
+	var encoded []string
+	for _, field := range fields {
+		var data string
+		if data, err = MarshalNjn(field); err != nil {
+			return
+		}
+		encoded = append(encoded, data)
+	}
+	output = strings.Join(encoded, ",")
+	return
+}
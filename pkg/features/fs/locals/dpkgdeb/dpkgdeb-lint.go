@@ -0,0 +1,96 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"path/filepath"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/lint"
+)
+
+// lintDpkgDeb returns the findings of the configured lint rules for the
+// given package
+func (f *CFeature) lintDpkgDeb(dd *dpkgDeb) (findings []*lint.Finding) {
+	pkg := &lint.Package{
+		Filename:     filepath.ToSlash(dd.File),
+		Control:      dd.Control,
+		ControlFiles: dd.ControlFiles,
+		Files:        dd.Files,
+	}
+	findings = lint.Run(pkg, f.lintRules...)
+	return
+}
+
+// lintFindings returns the packages with findings of at least the given
+// severity, all findings when severity is empty
+func lintFindings(list []*dpkgDeb, severity lint.Severity) (found []*dpkgDeb) {
+	for _, dd := range list {
+		if worst := lint.Worst(dd.Lint); worst != "" && (severity == "" || worst.Rank() <= severity.Rank()) {
+			found = append(found, dd)
+		}
+	}
+	return
+}
+
+// makeLintBlock returns the JSON encoded njn fields listing the lint
+// findings of the package
+func makeLintBlock(dd *dpkgDeb) (output string, err error) {
+	if len(dd.Lint) == 0 {
+		output, err = MarshalNjn([]interface{}{
+			map[string]interface{}{"type": "p", "text": "No lint findings."},
+		})
+		return
+	}
+
+	counts := make(map[lint.Severity]int)
+	var rows []interface{}
+	for _, finding := range dd.Lint {
+		counts[finding.Severity] += 1
+		severity := []interface{}{html.EscapeString(string(finding.Severity))}
+		if finding.Severity == lint.Error {
+			severity = []interface{}{map[string]interface{}{"type": "mark", "text": severity}}
+		}
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": severity},
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "code", "code": []interface{}{finding.Rule}},
+				}},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(finding.Message)},
+			},
+		})
+	}
+
+	section := []interface{}{
+		map[string]interface{}{
+			"type": "p",
+			"text": fmt.Sprintf("%d errors, %d warnings, %d notes.", counts[lint.Error], counts[lint.Warning], counts[lint.Info]),
+		},
+		map[string]interface{}{
+			"type": "table",
+			"head": []interface{}{
+				map[string]interface{}{"type": "th", "text": "Severity"},
+				map[string]interface{}{"type": "th", "text": "Rule"},
+				map[string]interface{}{"type": "th", "text": "Message"},
+			},
+			"body": rows,
+		},
+	}
+	output, err = MarshalNjn(section)
+	return
+}
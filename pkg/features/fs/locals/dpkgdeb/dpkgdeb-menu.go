@@ -0,0 +1,53 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"path"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/menu"
+)
+
+// makeMountMenu returns the menu linking to the package index of each mount
+// point, labelled with the last segment of the mount path
+func (f *CFeature) makeMountMenu() (m menu.Menu) {
+	for _, mp := range f.mount {
+		m = append(m, &menu.Item{
+			Text: path.Base(mp.Mount),
+			Href: mp.Mount,
+		})
+	}
+	return
+}
+
+// GetMenus returns the mount point menu for the SetMenu name, the menu is the
+// same for all languages
+func (f *CFeature) GetMenus(_ language.Tag) (found map[string]menu.Menu) {
+	found = make(map[string]menu.Menu)
+	if f.menuName != "" && len(f.mount) > 0 {
+		found[f.menuName] = f.makeMountMenu()
+	}
+	return
+}
+
+func (f *CFeature) GetAllMenus() (menus map[language.Tag]map[string]menu.Menu) {
+	menus = make(map[language.Tag]map[string]menu.Menu)
+	for _, tag := range f.Enjin.SiteLocales() {
+		menus[tag] = f.GetMenus(tag)
+	}
+	return
+}
@@ -0,0 +1,192 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/types/page"
+)
+
+// packageUrl returns the url of the package landing page
+func packageUrl(mp *feature.CMountPoint, name string) (url string) {
+	url = mp.Mount + "/" + name
+	return
+}
+
+// downloadUrl returns the public url of the package file, if the mount point
+// has a download url configured
+func (f *CFeature) downloadUrl(dd *dpkgDeb) (url string) {
+	url = f.mountDownloadUrl(dd.MP, dd.File)
+	return
+}
+
+// mountDownloadUrl returns the public url of the file within the mount point,
+// if the mount point has a download url configured
+func (f *CFeature) mountDownloadUrl(mp *feature.CMountPoint, file string) (url string) {
+	if prefix, ok := f.downloads[mp.Path]; ok {
+		url = strings.TrimSuffix(prefix, "/") + "/" + strings.TrimPrefix(filepath.ToSlash(file), "/")
+	}
+	return
+}
+
+// formatSize returns the size in a human-readable binary unit
+func formatSize(size int64) (formatted string) {
+	const unit = 1024
+	if size < unit {
+		formatted = fmt.Sprintf("%d B", size)
+		return
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	formatted = fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+	return
+}
+
+// cachedPackagePage returns a copy of the landing page for the given package
+// name and language, making and caching it when the store changes
+func (f *CFeature) cachedPackagePage(r *http.Request, tag language.Tag, mp *feature.CMountPoint, name string) (p feature.Page, err error) {
+	url := packageUrl(mp, name)
+	generation := f.store.Generation()
+	if cached, ok := f.pages.Get(url, tag, generation); ok {
+		p = cached
+		return
+	}
+	if p, err = f.makePackagePage(r, mp, name); err != nil {
+		return
+	}
+	f.pages.Put(url, tag, generation, p)
+	p = p.Copy()
+	return
+}
+
+func (f *CFeature) makePackagePage(r *http.Request, mp *feature.CMountPoint, name string) (p feature.Page, err error) {
+	groups := groupPackages(f.store.Query(packageQuery{Name: name, MP: mp}))
+	if len(groups) == 0 {
+		err = fmt.Errorf("package not found")
+		return
+	}
+	group := groups[0]
+	latest := group.Latest
+	url := packageUrl(mp, name)
+
+	var downloads []interface{}
+	for _, arch := range group.Architectures {
+		list := f.store.Query(packageQuery{Name: name, Architecture: arch, MP: mp})
+		downloads = append(downloads, map[string]interface{}{
+			"type": "a",
+			"href": url + "/latest_" + arch + ".deb",
+			"text": html.EscapeString(fmt.Sprintf("%v %v (%v)", name, list[0].Version, arch)),
+		})
+	}
+
+	var rows []interface{}
+	for _, pv := range group.Versions {
+		for _, dd := range pv.Packages {
+			links := []interface{}{
+				map[string]interface{}{"type": "a", "href": dd.Url, "text": "details"},
+			}
+			if download := f.downloadUrl(dd); download != "" {
+				links = append(links, " ", map[string]interface{}{"type": "a", "href": download, "text": "download"})
+			}
+			rows = append(rows, map[string]interface{}{
+				"type": "tr",
+				"data": []interface{}{
+					map[string]interface{}{"type": "td", "text": html.EscapeString(pv.Version)},
+					map[string]interface{}{"type": "td", "text": html.EscapeString(dd.Architecture)},
+					map[string]interface{}{"type": "td", "text": html.EscapeString(dd.Component)},
+					map[string]interface{}{"type": "td", "text": html.EscapeString(f.publicationsText(dd))},
+					map[string]interface{}{"type": "td", "text": formatSize(dd.Size)},
+					map[string]interface{}{"type": "td", "text": links},
+				},
+			})
+		}
+	}
+
+	sections := []interface{}{
+		[]interface{}{
+			map[string]interface{}{"type": "ul", "list": downloads},
+		},
+		[]interface{}{
+			map[string]interface{}{
+				"type": "table",
+				"head": []interface{}{
+					map[string]interface{}{"type": "th", "text": "Version"},
+					map[string]interface{}{"type": "th", "text": "Architecture"},
+					map[string]interface{}{"type": "th", "text": "Component"},
+					map[string]interface{}{"type": "th", "text": "Published in"},
+					map[string]interface{}{"type": "th", "text": "Size"},
+					map[string]interface{}{"type": "th", "text": "Links"},
+				},
+				"body": rows,
+			},
+		},
+	}
+	var licenseFields string
+	if licenseFields, err = makeLicenseFields(latest); err != nil {
+		err = fmt.Errorf("error encoding license fields: %v - %v", url, err)
+		return
+	}
+
+	var fields string
+	if fields, err = MakePackageFields(latest.Control); err != nil {
+		err = fmt.Errorf("error encoding package fields: %v - %v", url, err)
+		return
+	}
+
+	var description string
+	if description, err = MakeLongDescriptionParagraphs(latest.Control.LongDescription()); err != nil {
+		err = fmt.Errorf("error encoding description: %v - %v", url, err)
+		return
+	}
+
+	encoded := make([]string, len(sections))
+	for idx, section := range sections {
+		if encoded[idx], err = MarshalNjn(section); err != nil {
+			err = fmt.Errorf("error encoding package page: %v - %v", url, err)
+			return
+		}
+	}
+
+	var source = fmt.Sprintf(
+		gPackagePageTemplate,
+		name, "Debian package "+name, url,
+		name,
+		fields+","+licenseFields,
+		EscapeJson(html.EscapeString(latest.Control.Synopsis())), description,
+		encoded[0],
+		encoded[1],
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), url, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new package page: %v - %v", url, err)
+		return
+	}
+	p.SetSlugUrl(url)
+	return
+}
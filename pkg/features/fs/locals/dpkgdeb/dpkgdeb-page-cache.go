@@ -0,0 +1,142 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"container/list"
+	"sync"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+)
+
+// PageCacheStats is a snapshot of the rendered page cache counters
+type PageCacheStats struct {
+	Entries       int   `json:"entries"`
+	Capacity      int   `json:"capacity"`
+	Hits          int64 `json:"hits"`
+	Misses        int64 `json:"misses"`
+	Invalidations int64 `json:"invalidations"`
+	Evictions     int64 `json:"evictions"`
+}
+
+type pageCacheKey struct {
+	url string
+	tag language.Tag
+}
+
+type pageCacheEntry struct {
+	key    pageCacheKey
+	source interface{}
+	page   feature.Page
+}
+
+// pageCache holds the rendered pages per url and language. Entries are only
+// valid for the source they were made from: the owning dpkgDeb instance of
+// package sub-pages, which the packageStore replaces whenever the package file
+// changes, a debPageSource for package pages or the packageStore generation
+// for pages listing many packages. Sources must be comparable. A capacity of
+// zero is unlimited, otherwise the least recently used entries are evicted
+type pageCache struct {
+	capacity int
+	entries  map[pageCacheKey]*list.Element
+	order    *list.List
+
+	hits          int64
+	misses        int64
+	invalidations int64
+	evictions     int64
+
+	sync.Mutex
+}
+
+func newPageCache(capacity int) (c *pageCache) {
+	c = &pageCache{
+		capacity: capacity,
+		entries:  make(map[pageCacheKey]*list.Element),
+		order:    list.New(),
+	}
+	return
+}
+
+// Get returns a copy of the cached page for the given url, language and
+// source
+func (c *pageCache) Get(url string, tag language.Tag, source interface{}) (p feature.Page, ok bool) {
+	c.Lock()
+	defer c.Unlock()
+	key := pageCacheKey{url: url, tag: tag}
+	if element, present := c.entries[key]; present {
+		entry := element.Value.(*pageCacheEntry)
+		if entry.source == source {
+			c.order.MoveToFront(element)
+			c.hits += 1
+			p, ok = entry.page.Copy(), true
+			return
+		}
+		c.remove(element)
+		c.invalidations += 1
+	}
+	c.misses += 1
+	return
+}
+
+// Put stores the page for the given url, language and source
+func (c *pageCache) Put(url string, tag language.Tag, source interface{}, p feature.Page) {
+	c.Lock()
+	defer c.Unlock()
+	key := pageCacheKey{url: url, tag: tag}
+	if element, present := c.entries[key]; present {
+		c.remove(element)
+	}
+	c.entries[key] = c.order.PushFront(&pageCacheEntry{key: key, source: source, page: p})
+	for c.capacity > 0 && c.order.Len() > c.capacity {
+		c.remove(c.order.Back())
+		c.evictions += 1
+	}
+}
+
+// Retain removes all entries for which the valid function returns false
+func (c *pageCache) Retain(valid func(url string, source interface{}) bool) {
+	c.Lock()
+	defer c.Unlock()
+	for _, element := range c.entries {
+		entry := element.Value.(*pageCacheEntry)
+		if !valid(entry.key.url, entry.source) {
+			c.remove(element)
+			c.invalidations += 1
+		}
+	}
+}
+
+// Stats returns a snapshot of the cache counters
+func (c *pageCache) Stats() (stats PageCacheStats) {
+	c.Lock()
+	defer c.Unlock()
+	stats = PageCacheStats{
+		Entries:       c.order.Len(),
+		Capacity:      c.capacity,
+		Hits:          c.hits,
+		Misses:        c.misses,
+		Invalidations: c.invalidations,
+		Evictions:     c.evictions,
+	}
+	return
+}
+
+func (c *pageCache) remove(element *list.Element) {
+	entry := c.order.Remove(element).(*pageCacheEntry)
+	delete(c.entries, entry.key)
+}
@@ -15,22 +15,82 @@
 package dpkgdeb
 
 import (
+	"errors"
 	"fmt"
+	"html"
 	"net/http"
+	"os"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/go-enjin/golang-org-x-text/language"
+
 	"github.com/go-enjin/be/pkg/cli/run"
 	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
 	"github.com/go-enjin/be/types/page"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/checksums"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/changelog"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/copyright"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/lint"
 )
 
 type dpkgDeb struct {
-	Info     string
-	Contents string
-	File     string
-	MP       *feature.CMountPoint
+	Url          string
+	Name         string
+	Version      string
+	Architecture string
+	Component    string
+
+	dpkgDebData
+
+	Sums *checksums.Sums
+	// IndexSHA256 is the digest listed for the package in the Packages
+	// indices of the mount point, empty when not listed
+	IndexSHA256 string
+	// Lint are the findings of the lint rules, checked on every scan
+	Lint    []*lint.Finding
+	File    string
+	Size    int64
+	ModTime time.Time
+	MP      *feature.CMountPoint
+}
+
+// dpkgDebData is the content parsed from a package file, persisted by the
+// diskCache
+type dpkgDebData struct {
+	Info      string
+	Files     []*deb.File
+	Control   *control.Paragraph
+	Changelog []*changelog.Entry
+	// ControlFiles are the control archive members besides the control file
+	ControlFiles []*deb.File
+	// CopyrightText is the verbatim copyright file, Copyright is only set
+	// when the text is machine-readable, otherwise CopyrightError explains
+	// why not
+	CopyrightText  string
+	Copyright      *copyright.Copyright
+	CopyrightError string
+	// DataIssues are the problems found reading the data archive and
+	// verifying it with the md5sums
+	DataIssues []string
+}
+
+// CopyrightIssue returns why the package has no machine-readable copyright,
+// or an empty string if it does
+func (dd *dpkgDeb) CopyrightIssue() (issue string) {
+	switch {
+	case dd.Copyright != nil:
+	case dd.CopyrightText == "":
+		issue = "copyright file not found"
+	default:
+		issue = dd.CopyrightError
+	}
+	return
 }
 
 func (f *CFeature) makeDebNameUrl(mount, file string) (name, url string) {
@@ -40,24 +100,158 @@ func (f *CFeature) makeDebNameUrl(mount, file string) (name, url string) {
 }
 
 func (f *CFeature) makeDpkgDeb(file string, mp *feature.CMountPoint) (dd *dpkgDeb, err error) {
+	if dd, err = f.readDpkgDeb(file, mp); err != nil {
+		return
+	}
+	_, dd.Url = f.makeDebNameUrl(mp.Mount, file)
+	dd.Name = dd.Control.Value("Package")
+	dd.Version = dd.Control.Value("Version")
+	dd.Architecture = dd.Control.Value("Architecture")
+	dd.Component = poolComponent(file)
+	return
+}
+
+// poolComponent returns the component name from a pool/<component>/ path
+func poolComponent(file string) (component string) {
+	parts := strings.Split(filepath.ToSlash(filepath.Clean(file)), "/")
+	if len(parts) > 2 && parts[0] == "pool" {
+		component = parts[1]
+	}
+	return
+}
+
+func (f *CFeature) readDpkgDeb(file string, mp *feature.CMountPoint) (dd *dpkgDeb, err error) {
 	fullpath := filepath.Join(mp.Path, file)
 	dd = &dpkgDeb{
 		File: file,
 		MP:   mp,
 	}
 
+	var info os.FileInfo
+	if info, err = os.Stat(fullpath); err != nil {
+		return
+	}
+	dd.Size = info.Size()
+	dd.ModTime = info.ModTime()
+
+	if f.cache == nil {
+		if dd.Sums, err = checksums.HashFile(fullpath); err != nil {
+			return
+		}
+		err = f.parseDpkgDeb(dd, fullpath)
+		return
+	}
+
+	if dd.Sums, err = f.cache.Checksums(fullpath, info); err != nil {
+		return
+	}
+	if data, ok := f.cache.Load(dd.Sums.SHA256); ok {
+		dd.dpkgDebData = *data
+		return
+	}
+	if err = f.parseDpkgDeb(dd, fullpath); err != nil {
+		return
+	}
+	if ee := f.cache.Store(dd.Sums.SHA256, &dd.dpkgDebData); ee != nil {
+		log.ErrorF("error caching dpkg-deb data: %v - %v", file, ee)
+	}
+	return
+}
+
+func (f *CFeature) parseDpkgDeb(dd *dpkgDeb, fullpath string) (err error) {
+	file := dd.File
+
+	var pkg *deb.Package
+	docs := newDocFiles()
+	hasher := deb.NewDataHasher()
+	pkg, err = deb.ReadWalk(fullpath, hasher.Wrap(docs.Capture))
+	if errors.Is(err, deb.ErrCorruptData) && pkg != nil && pkg.ControlFile("control") != nil {
+		// keep the package so that the corruption is reported
+		dd.DataIssues = append(dd.DataIssues, err.Error())
+		err = nil
+	} else if err == nil {
+		dd.DataIssues = verifyMd5sums(pkg, hasher)
+	}
+	if err == nil {
+		dd.Info = pkg.FormatInfo()
+		dd.Files = pkg.Contents
+		for _, cf := range pkg.ControlFiles {
+			if deb.CleanName(cf.Name) != "control" {
+				dd.ControlFiles = append(dd.ControlFiles, cf)
+			}
+		}
+		if dd.Control, err = control.ParseParagraph(string(pkg.Control())); err != nil {
+			err = fmt.Errorf("error parsing control file: %v - %v", file, err)
+			return
+		}
+		name := dd.Control.Value("Package")
+		if dd.Changelog, err = docs.Changelog(name); err != nil {
+			log.WarnF("error parsing changelog: %v - %v", file, err)
+			err = nil
+		}
+		if dd.CopyrightText, dd.Copyright, err = docs.Copyright(name); err != nil {
+			dd.CopyrightError = err.Error()
+			err = nil
+		}
+		return
+	} else if !f.dpkgFallback {
+		err = fmt.Errorf("error reading deb package: %v - %v", file, err)
+		return
+	}
+
+	log.WarnF("error reading deb package, falling back to dpkg-deb: %v - %v", file, err)
 	if dd.Info, _, _, err = run.Cmd("dpkg-deb", "--info", fullpath); err != nil {
 		err = fmt.Errorf("dpkg-deb --info error: %v - %v", file, err)
 		return
 	}
-	if dd.Contents, _, _, err = run.Cmd("dpkg-deb", "--contents", fullpath); err != nil {
+	var contents string
+	if contents, _, _, err = run.Cmd("dpkg-deb", "--contents", fullpath); err != nil {
 		err = fmt.Errorf("dpkg-deb --contents error: %v - %v", file, err)
 		return
 	}
+	if dd.Files, err = deb.ParseContents(contents); err != nil {
+		err = fmt.Errorf("error parsing dpkg-deb --contents: %v - %v", file, err)
+		return
+	}
+	dd.ControlFiles = readControlFiles(fullpath)
+	var fields string
+	if fields, _, _, err = run.Cmd("dpkg-deb", "--field", fullpath); err != nil {
+		err = fmt.Errorf("dpkg-deb --field error: %v - %v", file, err)
+		return
+	}
+	if dd.Control, err = control.ParseParagraph(fields); err != nil {
+		err = fmt.Errorf("error parsing control file: %v - %v", file, err)
+		return
+	}
 
 	return
 }
 
+// debPageSource is the page cache source of a package page, which lists the
+// relationships with other packages and so is only valid while both the
+// package and the relationIndex signature of the package are unchanged
+type debPageSource struct {
+	dd        *dpkgDeb
+	relations string
+}
+
+// cachedDebPage returns a copy of the rendered page for the given package and
+// language, making and caching it when the package or the packages it has
+// relationships with change
+func (f *CFeature) cachedDebPage(r *http.Request, tag language.Tag, dd *dpkgDeb) (p feature.Page, err error) {
+	source := debPageSource{dd: dd, relations: f.relationIndex(dd.MP).signature(dd)}
+	if cached, ok := f.pages.Get(dd.Url, tag, source); ok {
+		p = cached
+		return
+	}
+	if p, err = f.makeDebPage(r, dd); err != nil {
+		return
+	}
+	f.pages.Put(dd.Url, tag, source, p)
+	p = p.Copy()
+	return
+}
+
 func (f *CFeature) makeDebPage(r *http.Request, dd *dpkgDeb) (p feature.Page, err error) {
 
 	fullpath := filepath.Join(dd.MP.Path, dd.File)
@@ -69,29 +263,122 @@ func (f *CFeature) makeDebPage(r *http.Request, dd *dpkgDeb) (p feature.Page, er
 			if idx == last {
 				comma = ""
 			}
-			output += fmt.Sprintf("\"%v\"%s", EscapeQuotes(line), comma)
+			output += fmt.Sprintf("\"%v\"%s", EscapeJson(line), comma)
 		}
 		return
 	}
 
 	debName, url := f.makeDebNameUrl(dd.MP.Mount, dd.File)
-	parsed, lines, order := ParseDpkgDebInfoOutput(dd.Info)
-	// name, summary, description, section, version, homepage := DecomposeDpkgDebInfo(parsed)
-	_, summary, description, _, _, _ := DecomposeDpkgDebInfo(parsed)
+	summary, description := dd.Control.Synopsis(), dd.Control.LongDescription()
+
+	infoCodeBlock := makeIntoLines(strings.Split(dd.Info, "\n"))
+
+	var fields string
+	if fields, err = MakePackageFields(dd.Control, gRelationFields...); err != nil {
+		err = fmt.Errorf("error encoding package fields: %v - %v", fullpath, err)
+		return
+	}
+
+	var licenseFields string
+	if licenseFields, err = makeLicenseFields(dd); err != nil {
+		err = fmt.Errorf("error encoding license fields: %v - %v", fullpath, err)
+		return
+	}
+
+	var lintBlock string
+	if lintBlock, err = makeLintBlock(dd); err != nil {
+		err = fmt.Errorf("error encoding lint findings: %v - %v", fullpath, err)
+		return
+	}
+
+	ix := f.relationIndex(dd.MP)
+
+	var relationsBlock string
+	if relationsBlock, err = f.makeRelationsBlock(ix, dd); err != nil {
+		err = fmt.Errorf("error encoding relationships: %v - %v", fullpath, err)
+		return
+	}
+
+	var reverseBlock string
+	if reverseBlock, err = f.makeReverseBlock(ix, dd); err != nil {
+		err = fmt.Errorf("error encoding reverse dependencies: %v - %v", fullpath, err)
+		return
+	}
+
+	var changelogBlock string
+	if changelogBlock, err = makeChangelogBlock(dd); err != nil {
+		err = fmt.Errorf("error encoding changelog: %v - %v", fullpath, err)
+		return
+	}
 
-	infoCodeBlock := makeIntoLines(lines)
-	contentsBlock := makeIntoLines(strings.Split(dd.Contents, "\n"))
+	var contentsBlock string
+	if contentsBlock, err = makeContentsBlock(dd); err != nil {
+		err = fmt.Errorf("error encoding contents: %v - %v", fullpath, err)
+		return
+	}
+
+	var integrityNotice string
+	if integrityNotice, err = makeIntegrityNotice(dd); err != nil {
+		err = fmt.Errorf("error encoding integrity notice: %v - %v", fullpath, err)
+		return
+	}
+
+	var installabilityNotice string
+	if installabilityNotice, err = f.makeInstallabilityNotice(dd); err != nil {
+		err = fmt.Errorf("error encoding installability notice: %v - %v", fullpath, err)
+		return
+	}
 
-	fields := MakePackageFields(parsed, order)
+	var publishedNotice string
+	if publishedNotice, err = f.makePublishedNotice(dd); err != nil {
+		err = fmt.Errorf("error encoding published notice: %v - %v", fullpath, err)
+		return
+	}
+
+	var sourceNotice string
+	if sourceNotice, err = f.makeSourceNotice(dd); err != nil {
+		err = fmt.Errorf("error encoding source notice: %v - %v", fullpath, err)
+		return
+	}
+
+	var scriptsBadge string
+	if scriptsBadge, err = makeScriptsBadge(dd); err != nil {
+		err = fmt.Errorf("error encoding maintainer scripts badge: %v - %v", fullpath, err)
+		return
+	}
+
+	var descriptionParagraphs string
+	if descriptionParagraphs, err = MakeLongDescriptionParagraphs(description); err != nil {
+		err = fmt.Errorf("error encoding description: %v - %v", fullpath, err)
+		return
+	}
+
+	var paragraphs []string
+	for _, fields := range []string{integrityNotice, installabilityNotice, publishedNotice, sourceNotice, scriptsBadge, descriptionParagraphs} {
+		if fields != "" {
+			paragraphs = append(paragraphs, fields)
+		}
+	}
+
+	var controlBlock string
+	if controlBlock, err = makeControlBlock(dd); err != nil {
+		err = fmt.Errorf("error encoding control files: %v - %v", fullpath, err)
+		return
+	}
 
 	var source = fmt.Sprintf(
 		gPageTemplate,
 		debName, "Debian package details for "+debName, url,
 		debName,
-		fields,
-		summary, MakeLongDescriptionParagraphs(description),
-		infoCodeBlock,
+		fields+","+licenseFields,
+		EscapeJson(html.EscapeString(summary)), strings.Join(paragraphs, ","),
+		lintBlock,
+		relationsBlock,
+		reverseBlock,
+		changelogBlock,
 		contentsBlock,
+		controlBlock,
+		infoCodeBlock,
 	)
 
 	created := time.Now().Unix()
@@ -0,0 +1,347 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"html"
+	"slices"
+	"strings"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
+)
+
+var (
+	// gRelationFields are the package relationship fields, in display order
+	gRelationFields = []string{
+		"Pre-Depends",
+		"Depends",
+		"Recommends",
+		"Suggests",
+		"Conflicts",
+		"Breaks",
+		"Replaces",
+		"Provides",
+	}
+	// gGraphFields are the relationship fields followed by the dependency
+	// graph
+	gGraphFields = []string{
+		"Pre-Depends",
+		"Depends",
+	}
+)
+
+// relationsOf returns the named relationship field of the package, logging
+// and ignoring any malformed field
+func relationsOf(dd *dpkgDeb, field string) (relations control.Relations) {
+	var err error
+	if relations, err = dd.Control.Relations(field); err != nil {
+		log.DebugF("error parsing %v relations: %v - %v", field, dd.Url, err)
+		relations = nil
+	}
+	return
+}
+
+// archCompatible returns true if packages of the given architectures can be
+// installed together
+func archCompatible(a, b string) (ok bool) {
+	ok = a == b || a == "all" || b == "all"
+	return
+}
+
+// relationProvider is a package providing a virtual package
+type relationProvider struct {
+	dd       *dpkgDeb
+	relation *control.Relation
+}
+
+// reverseRelation is a relationship of another package which resolves to
+// the package it is listed for
+type reverseRelation struct {
+	dd           *dpkgDeb
+	field        string
+	alternatives control.Alternatives
+}
+
+// relationIndex resolves package relationships within a list of packages
+type relationIndex struct {
+	list      []*dpkgDeb
+	byName    map[string][]*dpkgDeb
+	providers map[string][]*relationProvider
+	// reverse are the relationships of other packages resolving to each
+	// package, in list and field order
+	reverse map[*dpkgDeb][]*reverseRelation
+	// generation is the packageStore generation the list was taken from
+	generation uint64
+}
+
+// newRelationIndex returns a relationIndex of the given packages, which are
+// expected to be in the sortPackages order
+func newRelationIndex(list []*dpkgDeb) (ix *relationIndex) {
+	ix = &relationIndex{
+		list:      list,
+		byName:    make(map[string][]*dpkgDeb),
+		providers: make(map[string][]*relationProvider),
+		reverse:   make(map[*dpkgDeb][]*reverseRelation),
+	}
+	for _, dd := range list {
+		ix.byName[dd.Name] = append(ix.byName[dd.Name], dd)
+		for _, alternatives := range relationsOf(dd, "Provides") {
+			for _, relation := range alternatives {
+				ix.providers[relation.Name] = append(ix.providers[relation.Name], &relationProvider{dd: dd, relation: relation})
+			}
+		}
+	}
+	for _, other := range list {
+		for _, field := range gRelationFields {
+			if field == "Provides" {
+				continue
+			}
+			for _, alternatives := range relationsOf(other, field) {
+				var targets []*dpkgDeb
+				for _, relation := range alternatives {
+					for _, target := range ix.resolve(other, relation) {
+						if target.Name != other.Name && !slices.Contains(targets, target) {
+							targets = append(targets, target)
+						}
+					}
+				}
+				for _, target := range targets {
+					ix.reverse[target] = append(ix.reverse[target], &reverseRelation{dd: other, field: field, alternatives: alternatives})
+				}
+			}
+		}
+	}
+	return
+}
+
+// relationIndex returns the relationIndex of the packages within the mount
+// point, made once per packageStore generation
+func (f *CFeature) relationIndex(mp *feature.CMountPoint) (ix *relationIndex) {
+	generation := f.store.Generation()
+	f.relationsLock.Lock()
+	defer f.relationsLock.Unlock()
+	if ix = f.relations[mp.Mount]; ix != nil && ix.generation == generation {
+		return
+	}
+	ix = newRelationIndex(f.store.Query(packageQuery{MP: mp}))
+	ix.generation = generation
+	f.relations[mp.Mount] = ix
+	return
+}
+
+// resolve returns the packages which satisfy the relation for the given
+// package, real packages first and newest versions first
+func (ix *relationIndex) resolve(from *dpkgDeb, relation *control.Relation) (targets []*dpkgDeb) {
+	for _, dd := range ix.byName[relation.Name] {
+		if archCompatible(from.Architecture, dd.Architecture) && relation.Satisfies(dd.Version) {
+			targets = append(targets, dd)
+		}
+	}
+	for _, provider := range ix.providers[relation.Name] {
+		if !archCompatible(from.Architecture, provider.dd.Architecture) || slices.Contains(targets, provider.dd) {
+			continue
+		}
+		// versioned relations are only satisfied by versioned provides
+		if relation.Operator == "" || (provider.relation.Operator == "=" && relation.Satisfies(provider.relation.Version)) {
+			targets = append(targets, provider.dd)
+		}
+	}
+	return
+}
+
+// best returns the preferred target for the given architecture, the newest
+// with the same architecture, then the newest "all" architecture or otherwise
+// the first
+func (ix *relationIndex) best(arch string, targets []*dpkgDeb) (dd *dpkgDeb) {
+	for _, preferred := range []string{arch, "all"} {
+		for _, target := range targets {
+			if target.Architecture == preferred {
+				dd = target
+				return
+			}
+		}
+	}
+	if len(targets) > 0 {
+		dd = targets[0]
+	}
+	return
+}
+
+// linkTargets returns whether the relation names a package within the index
+// and otherwise the distinct packages providing it
+func (ix *relationIndex) linkTargets(dd *dpkgDeb, relation *control.Relation) (present bool, providers []*dpkgDeb) {
+	if _, present = ix.byName[relation.Name]; present {
+		return
+	}
+	for _, target := range ix.resolve(dd, relation) {
+		if target != dd && !slices.ContainsFunc(providers, func(p *dpkgDeb) bool { return p.Name == target.Name }) {
+			providers = append(providers, target)
+		}
+	}
+	return
+}
+
+// signature returns a summary of the links made for the relationships of the
+// package and of its reverse relationships, which changes whenever the
+// relations listed on the package page would
+func (ix *relationIndex) signature(dd *dpkgDeb) (signature string) {
+	var parts []string
+	for _, field := range gRelationFields {
+		for _, alternatives := range relationsOf(dd, field) {
+			for _, relation := range alternatives {
+				present, providers := ix.linkTargets(dd, relation)
+				part := relation.Name
+				if present {
+					part += "+"
+				}
+				for _, provider := range providers {
+					part += " " + provider.Name
+				}
+				parts = append(parts, part)
+			}
+		}
+	}
+	for _, rr := range ix.reverse[dd] {
+		parts = append(parts, rr.dd.Url+" "+rr.field+" "+rr.alternatives.String())
+	}
+	signature = strings.Join(parts, "\n")
+	return
+}
+
+// makeRelationText returns the njn inline text of the alternatives, linking
+// each package name found within the index to its landing page
+func (ix *relationIndex) makeRelationText(dd *dpkgDeb, alternatives control.Alternatives) (text []interface{}) {
+	for idx, relation := range alternatives {
+		if idx > 0 {
+			text = append(text, "&nbsp;|&nbsp;")
+		}
+		present, providers := ix.linkTargets(dd, relation)
+		if present {
+			text = append(text, map[string]interface{}{
+				"type": "a",
+				"href": packageUrl(dd.MP, relation.Name),
+				"text": []interface{}{html.EscapeString(relation.Name)},
+			})
+		} else {
+			text = append(text, html.EscapeString(relation.Name))
+		}
+		if detail := strings.TrimSpace(relation.String()[len(relation.Name):]); detail != "" {
+			text = append(text, "&nbsp;"+html.EscapeString(detail))
+		}
+		if len(providers) > 0 {
+			text = append(text, "&nbsp;(provided by&nbsp;")
+			for pdx, provider := range providers {
+				if pdx > 0 {
+					text = append(text, ",&nbsp;")
+				}
+				text = append(text, map[string]interface{}{
+					"type": "a",
+					"href": packageUrl(dd.MP, provider.Name),
+					"text": []interface{}{html.EscapeString(provider.Name)},
+				})
+			}
+			text = append(text, ")")
+		}
+	}
+	return
+}
+
+// makeRelationsBlock returns the JSON encoded njn fields listing the
+// relationship fields of the package, with links to the dependency graph
+func (f *CFeature) makeRelationsBlock(ix *relationIndex, dd *dpkgDeb) (output string, err error) {
+	var rows []interface{}
+	for _, field := range gRelationFields {
+		relations := relationsOf(dd, field)
+		if len(relations) == 0 {
+			continue
+		}
+		var items []interface{}
+		for _, alternatives := range relations {
+			items = append(items, ix.makeRelationText(dd, alternatives))
+		}
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "b", "text": []interface{}{field}},
+				}},
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "ul", "list": items},
+				}},
+			},
+		})
+	}
+
+	section := []interface{}{
+		map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				"Dependency graph within this archive:&nbsp;",
+				map[string]interface{}{"type": "a", "href": dd.Url + "/graph.svg", "text": []interface{}{"SVG"}},
+				"&nbsp;|&nbsp;",
+				map[string]interface{}{"type": "a", "href": dd.Url + "/graph.dot", "text": []interface{}{"DOT"}},
+			},
+		},
+	}
+	if len(rows) == 0 {
+		section = append(section, map[string]interface{}{"type": "p", "text": "No package relationships."})
+	} else {
+		section = append(section, map[string]interface{}{"type": "table", "body": rows})
+	}
+	output, err = MarshalNjn(section)
+	return
+}
+
+// makeReverseBlock returns the JSON encoded njn fields listing the packages
+// within the archive with relationships resolving to the given package
+func (f *CFeature) makeReverseBlock(ix *relationIndex, dd *dpkgDeb) (output string, err error) {
+	var rows []interface{}
+	for _, rr := range ix.reverse[dd] {
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "a", "href": rr.dd.Url, "text": []interface{}{html.EscapeString(rr.dd.Name)}},
+				}},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(rr.dd.Version)},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(rr.dd.Architecture)},
+				map[string]interface{}{"type": "td", "text": rr.field},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(rr.alternatives.String())},
+			},
+		})
+	}
+
+	var section []interface{}
+	if len(rows) == 0 {
+		section = append(section, map[string]interface{}{"type": "p", "text": "No other packages within this archive refer to this package."})
+	} else {
+		section = append(section, map[string]interface{}{
+			"type": "table",
+			"head": []interface{}{
+				map[string]interface{}{"type": "th", "text": "Package"},
+				map[string]interface{}{"type": "th", "text": "Version"},
+				map[string]interface{}{"type": "th", "text": "Architecture"},
+				map[string]interface{}{"type": "th", "text": "Field"},
+				map[string]interface{}{"type": "th", "text": "Relation"},
+			},
+			"body": rows,
+		})
+	}
+	output, err = MarshalNjn(section)
+	return
+}
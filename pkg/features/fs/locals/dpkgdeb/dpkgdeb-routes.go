@@ -0,0 +1,199 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+)
+
+// gReservedPath is the path segment, within each mount point, of the pages
+// which are not package pages. Package names must start with an alphanumeric
+// character and so can never be shadowed by these pages
+const gReservedPath = "-"
+
+// reservedUrl returns the url of the named reserved page of the mount point
+func reservedUrl(mp *feature.CMountPoint, name string) (url string) {
+	url = mp.Mount + "/" + gReservedPath + "/" + name
+	return
+}
+
+// debRoute is a request path parsed relative to a mount point, the index
+// route has neither DD, DSC nor Name set
+type debRoute struct {
+	Path string
+	MP   *feature.CMountPoint
+	// DD is the package file named by the first path segment
+	DD *dpkgDeb
+	// DSC is the source package file named by the first path segment
+	DSC *dpkgDsc
+	// Name is the package name given by the first path segment
+	Name string
+	// Sub is the list of any remaining path segments
+	Sub []string
+	// Health is true for the installability report route
+	Health bool
+	// Search is true for the package search route
+	Search bool
+}
+
+// IsIndex returns true if the route is the mount point package index
+func (rt *debRoute) IsIndex() (ok bool) {
+	ok = rt.DD == nil && rt.DSC == nil && rt.Name == "" && !rt.Health && !rt.Search
+	return
+}
+
+// parseRoute returns the route for the given request path, if the path is
+// within a mount point and names a known package file or package name
+func (f *CFeature) parseRoute(path string) (rt *debRoute, ok bool) {
+	for _, mp := range f.mount {
+		if path == mp.Mount {
+			rt, ok = &debRoute{Path: path, MP: mp}, true
+			return
+		}
+		rest, found := strings.CutPrefix(path, mp.Mount+"/")
+		if !found || rest == "" {
+			continue
+		}
+		if rest == gReservedPath+"/health" {
+			rt, ok = &debRoute{Path: path, MP: mp, Health: true}, true
+			return
+		} else if rest == gReservedPath+"/search" {
+			rt, ok = &debRoute{Path: path, MP: mp, Search: true}, true
+			return
+		}
+		segments := strings.Split(rest, "/")
+		rt = &debRoute{Path: path, MP: mp, Sub: segments[1:]}
+		if dd, present := f.store.Get(mp.Mount + "/" + segments[0]); present {
+			rt.DD, ok = dd, true
+			return
+		}
+		if ds, present := f.getSource(mp.Mount + "/" + segments[0]); present {
+			rt.DSC, ok = ds, true
+			return
+		}
+		if len(f.store.Query(packageQuery{Name: segments[0], MP: mp})) > 0 {
+			rt.Name, ok = segments[0], true
+			return
+		}
+	}
+	rt = nil
+	return
+}
+
+// routePage returns the page for the given route, ok is false when the route
+// is not a page
+func (f *CFeature) routePage(r *http.Request, tag language.Tag, rt *debRoute) (p feature.Page, ok bool, err error) {
+	switch {
+	case rt.IsIndex():
+		if p, err = f.cachedIndexPage(r, tag, rt.MP); err != nil {
+			err = fmt.Errorf("error making index page: %v - %w", rt.Path, err)
+		}
+	case rt.Health:
+		if p, err = f.cachedHealthPage(r, tag, rt.MP); err != nil {
+			err = fmt.Errorf("error making health page: %v - %w", rt.Path, err)
+		}
+	case rt.Search:
+		if p, err = f.makeSearchPage(r, rt.MP); err != nil {
+			err = fmt.Errorf("error making search page: %v - %w", rt.Path, err)
+		}
+	case rt.DD != nil && len(rt.Sub) == 0:
+		if p, err = f.cachedDebPage(r, tag, rt.DD); err != nil {
+			err = fmt.Errorf("error making deb page: %v - %w", rt.Path, err)
+		}
+	case rt.DSC != nil && len(rt.Sub) == 0:
+		if p, err = f.cachedSourcePage(r, tag, rt.DSC); err != nil {
+			err = fmt.Errorf("error making source page: %v - %w", rt.Path, err)
+		}
+	case rt.DD != nil && len(rt.Sub) == 1 && rt.Sub[0] == "changelog" && len(rt.DD.Changelog) > 0:
+		if p, err = f.cachedChangelogPage(r, tag, rt.DD); err != nil {
+			err = fmt.Errorf("error making changelog page: %v - %w", rt.Path, err)
+		}
+	case rt.DD != nil && len(rt.Sub) == 1 && rt.Sub[0] == "copyright" && rt.DD.CopyrightText != "":
+		if p, err = f.cachedCopyrightPage(r, tag, rt.DD); err != nil {
+			err = fmt.Errorf("error making copyright page: %v - %w", rt.Path, err)
+		}
+	case rt.DD != nil && len(rt.Sub) == 1 && rt.Sub[0] == "contents":
+		number, valid := contentsPageNumber(r)
+		if !valid {
+			return
+		}
+		if p, ok, err = f.cachedContentsPage(r, tag, rt.DD, number); err != nil {
+			err = fmt.Errorf("error making contents page: %v - %w", rt.Path, err)
+		}
+		return
+	case rt.Name != "" && len(rt.Sub) == 0:
+		if p, err = f.cachedPackagePage(r, tag, rt.MP, rt.Name); err != nil {
+			err = fmt.Errorf("error making package page: %v - %w", rt.Path, err)
+		}
+	default:
+		return
+	}
+	ok = err == nil
+	return
+}
+
+// latestRedirect returns the package file to redirect to for the latest.deb
+// and latest_<arch>.deb package routes. Without an architecture, the newest
+// version must be for a single architecture or have an "all" architecture
+// file
+func (f *CFeature) latestRedirect(rt *debRoute) (dd *dpkgDeb, ok bool) {
+	if rt.Name == "" || len(rt.Sub) != 1 {
+		return
+	}
+
+	var arch string
+	switch name := rt.Sub[0]; {
+	case name == "latest.deb":
+	case strings.HasPrefix(name, "latest_") && strings.HasSuffix(name, ".deb"):
+		if arch = strings.TrimSuffix(strings.TrimPrefix(name, "latest_"), ".deb"); arch == "" {
+			return
+		}
+	default:
+		return
+	}
+
+	list := f.store.Query(packageQuery{Name: rt.Name, Architecture: arch, MP: rt.MP})
+	if len(list) == 0 {
+		return
+	}
+	// newest version is first
+	var candidates []*dpkgDeb
+	for _, candidate := range list {
+		if candidate.Version == list[0].Version {
+			candidates = append(candidates, candidate)
+		}
+	}
+	single := true
+	for _, candidate := range candidates {
+		single = single && candidate.Architecture == candidates[0].Architecture
+	}
+	if single {
+		dd, ok = candidates[0], true
+		return
+	}
+	for _, candidate := range candidates {
+		if candidate.Architecture == "all" {
+			dd, ok = candidate, true
+			return
+		}
+	}
+	return
+}
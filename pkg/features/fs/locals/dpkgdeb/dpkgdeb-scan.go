@@ -0,0 +1,292 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/deb"
+)
+
+var (
+	DefaultScanProgressInterval = 5 * time.Second
+)
+
+// scanJob is a single package file which is new or modified since the last
+// scan
+type scanJob struct {
+	File string
+	Url  string
+	MP   *feature.CMountPoint
+	// IndexSHA256 is the digest listed in the Packages indices
+	IndexSHA256 string
+}
+
+// scanResult is the outcome of processing a scanJob
+type scanResult struct {
+	DD   *dpkgDeb
+	Page feature.Page
+	Err  error
+}
+
+func isDebFile(file string) (ok bool) {
+	ok = strings.HasSuffix(file, ".deb") || strings.HasSuffix(file, ".udeb")
+	return
+}
+
+// scanMountPoints adds any new or modified packages and evicts any which no
+// longer exist. Errors reading or indexing individual packages are logged and
+// those packages skipped, only the errors listing an entire mount point are
+// returned, joined together
+func (f *CFeature) scanMountPoints() (err error) {
+	f.scanning.Lock()
+	defer f.scanning.Unlock()
+
+	start := time.Now()
+	jobs, seen, err := f.listScanJobs()
+	results := f.processScanJobs(jobs)
+
+	var added, updated, removed int
+
+	// results are applied in the same order as the jobs were listed
+	for idx, result := range results {
+		if result.Err != nil {
+			log.ErrorF("error scanning dpkg-deb package: %v", result.Err)
+			continue
+		}
+
+		if previous := f.store.Put(result.DD); previous != nil {
+			f.unindexDpkgDeb(previous)
+			updated += 1
+		} else {
+			added += 1
+		}
+
+		if result.Page != nil {
+			if ee := f.search.AddToSearchIndex(nil, result.Page); ee != nil {
+				log.ErrorF("error indexing dpkg-deb page: %v - %v", jobs[idx].Url, ee)
+				continue
+			}
+		}
+		if issues := result.DD.IntegrityIssues(); len(issues) > 0 {
+			log.WarnF("dpkg-deb integrity verification failed: %v - %v", jobs[idx].Url, strings.Join(issues, "; "))
+		}
+		log.DebugF("cached and indexed dpkg-deb: %v", jobs[idx].Url)
+	}
+
+	evicted := f.store.Retain(func(dd *dpkgDeb) (keep bool) {
+		_, keep = seen[dd.Url]
+		return
+	})
+	for _, dd := range evicted {
+		f.unindexDpkgDeb(dd)
+		removed += 1
+	}
+
+	// pages made from the store generation, and package pages, also list
+	// installability issues, source packages and distributions
+	healthChanged := f.updateHealth()
+	sourcesChanged := f.scanSources()
+	distsChanged := f.updateDists()
+	generation := f.store.Generation()
+	f.pages.Retain(func(url string, source interface{}) (valid bool) {
+		switch src := source.(type) {
+		case debPageSource:
+			dd, ok := f.store.Get(src.dd.Url)
+			valid = ok && dd == src.dd && !healthChanged && !sourcesChanged && !distsChanged &&
+				f.relationIndex(dd.MP).signature(dd) == src.relations
+		case *dpkgDeb:
+			// sub-pages, such as the changelog, are cached under their own
+			// urls and remain valid while the owning package is unchanged
+			dd, ok := f.store.Get(src.Url)
+			valid = ok && dd == src
+		case uint64:
+			valid = src == generation && !healthChanged && !sourcesChanged && !distsChanged
+		}
+		return
+	})
+
+	if f.cache != nil {
+		keep := make(map[string]struct{})
+		for _, dd := range f.store.List() {
+			keep[filepath.Join(dd.MP.Path, dd.File)] = struct{}{}
+		}
+		if ee := f.cache.Save(keep); ee != nil {
+			log.ErrorF("error saving dpkg-deb cache: %v", ee)
+		}
+	}
+
+	if added+updated+removed > 0 {
+		log.InfoF("dpkg-deb packages: %d added, %d updated, %d removed in %v", added, updated, removed, time.Now().Sub(start))
+	}
+	stats := f.pages.Stats()
+	log.DebugF("dpkg-deb page cache: %d entries, %d hits, %d misses, %d invalidations, %d evictions", stats.Entries, stats.Hits, stats.Misses, stats.Invalidations, stats.Evictions)
+	return
+}
+
+// listScanJobs returns the list of new or modified package files, in mount
+// point and file listing order, along with the set of all package urls found.
+// Packages with a changed Packages index entry are also considered modified.
+// The packages of any mount point which cannot be listed are kept as seen and
+// the listing errors returned joined together
+func (f *CFeature) listScanJobs() (jobs []*scanJob, seen map[string]struct{}, err error) {
+	seen = make(map[string]struct{})
+	var errs []error
+	for _, mp := range f.mount {
+		files, ee := mp.ROFS.ListAllFiles(".")
+		if ee != nil {
+			errs = append(errs, fmt.Errorf("error listing dpkg-deb mount point: %v - %w", mp.Path, ee))
+			for _, dd := range f.store.Query(packageQuery{MP: mp}) {
+				seen[dd.Url] = struct{}{}
+			}
+			continue
+		}
+		indexed := f.indexDigests(mp)
+		for _, file := range files {
+			if !isDebFile(file) {
+				continue
+			}
+
+			_, url := f.makeDebNameUrl(mp.Mount, file)
+			seen[url] = struct{}{}
+
+			info, err := os.Stat(filepath.Join(mp.Path, file))
+			if err != nil {
+				// removed since listing, evicted on the next scan
+				continue
+			}
+
+			digest := indexed[deb.CleanName(file)]
+			if existing, found := f.store.Get(url); found && existing.Size == info.Size() && existing.ModTime.Equal(info.ModTime()) && existing.IndexSHA256 == digest {
+				continue
+			}
+
+			jobs = append(jobs, &scanJob{File: file, Url: url, MP: mp, IndexSHA256: digest})
+		}
+	}
+	err = errors.Join(errs...)
+	return
+}
+
+// processScanJobs reads and renders each package using a bounded pool of
+// workers, the results are in the same order as the jobs given
+func (f *CFeature) processScanJobs(jobs []*scanJob) (results []*scanResult) {
+	results = make([]*scanResult, len(jobs))
+	if len(jobs) == 0 {
+		return
+	}
+
+	workers := f.scanWorkers
+	if workers <= 0 {
+		workers = DefaultScanWorkers
+	}
+	if workers > len(jobs) {
+		workers = len(jobs)
+	}
+
+	var completed atomic.Int64
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(DefaultScanProgressInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				log.InfoF("dpkg-deb scan progress: %d/%d packages", completed.Load(), len(jobs))
+			}
+		}
+	}()
+
+	queue := make(chan int)
+	wg := &sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range queue {
+				results[idx] = f.processScanJob(jobs[idx])
+				completed.Add(1)
+			}
+		}()
+	}
+	for idx := range jobs {
+		queue <- idx
+	}
+	close(queue)
+	wg.Wait()
+	return
+}
+
+func (f *CFeature) processScanJob(job *scanJob) (result *scanResult) {
+	result = &scanResult{}
+	if result.DD, result.Err = f.makeDpkgDeb(job.File, job.MP); result.Err != nil {
+		result.Err = fmt.Errorf("error caching dpkg-deb outputs: %v - %w", job.File, result.Err)
+		return
+	}
+	result.DD.IndexSHA256 = job.IndexSHA256
+	result.DD.Lint = f.lintDpkgDeb(result.DD)
+	if p, err := f.makeDebPage(nil, result.DD); err == nil {
+		result.Page = p
+	} else {
+		log.ErrorF("error making dpkg-deb page: %v - %v", job.Url, err)
+	}
+	return
+}
+
+// startupScan performs the initial scan and then, if enabled, starts the
+// periodic rescanning of the mount points, regardless of the initial scan
+// failing to list any mount points
+func (f *CFeature) startupScan(stop chan struct{}) (err error) {
+	err = f.scanMountPoints()
+	log.InfoF("dpkg-deb indexed %d packages", f.store.Len())
+	if stop != nil {
+		go f.rescanMountPoints(stop)
+	}
+	return
+}
+
+func (f *CFeature) rescanMountPoints(stop chan struct{}) {
+	ticker := time.NewTicker(f.rescanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := f.scanMountPoints(); err != nil {
+				log.ErrorF("error rescanning dpkg-deb mount points: %v", err)
+			}
+		}
+	}
+}
+
+func (f *CFeature) unindexDpkgDeb(dd *dpkgDeb) {
+	if p, err := f.makeDebPage(nil, dd); err == nil {
+		f.search.RemoveFromSearchIndex(nil, p)
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-enjin/be/pkg/context"
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/forms"
+	"github.com/go-enjin/be/types/page"
+)
+
+// searchUrl returns the url of the package search page of the mount point
+func searchUrl(mp *feature.CMountPoint) (url string) {
+	url = reservedUrl(mp, "search")
+	return
+}
+
+// requestMountPoint returns the mount point the request path is within, if any
+func (f *CFeature) requestMountPoint(r *http.Request) (mp *feature.CMountPoint) {
+	path := forms.CleanRequestPath(r.URL.Path)
+	for _, mp = range f.mount {
+		if path == mp.Mount || strings.HasPrefix(path, mp.Mount+"/") {
+			return
+		}
+	}
+	mp = nil
+	return
+}
+
+// FilterPageContext directs the site search form of the pages within a mount
+// point to the package search of that mount point, so that searches started
+// from one mount point only find the packages within it
+func (f *CFeature) FilterPageContext(themeCtx, _ context.Context, r *http.Request) (themeOut context.Context) {
+	themeOut = themeCtx
+	if mp := f.requestMountPoint(r); mp != nil {
+		themeOut.SetSpecific("SiteSearchable", true)
+		themeOut.SetSpecific("SiteSearchPath", searchUrl(mp))
+	}
+	return
+}
+
+// makeSearchPage returns the page listing the packages of the mount point
+// with names or synopses containing the "query" request parameter. Search
+// pages depend on the request and so are not cached
+func (f *CFeature) makeSearchPage(r *http.Request, mp *feature.CMountPoint) (p feature.Page, err error) {
+	url := searchUrl(mp)
+	query := strings.TrimSpace(r.URL.Query().Get("query"))
+
+	var summary string
+	var section []interface{}
+	if query == "" {
+		summary = "Search packages"
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": "Enter a package name, or part of a package summary, in the search field.",
+		})
+	} else {
+		groups := groupPackages(f.store.Query(packageQuery{MP: mp, Search: query}))
+		summary = fmt.Sprintf("%d packages matching &quot;%s&quot;", len(groups), html.EscapeString(query))
+		if len(groups) == 0 {
+			section = append(section, map[string]interface{}{
+				"type": "p",
+				"text": "No packages found.",
+			})
+		} else {
+			section = append(section, makePackageTable(mp, groups))
+		}
+	}
+	section = append(section, map[string]interface{}{
+		"type": "p",
+		"text": []interface{}{
+			"Browse the ",
+			map[string]interface{}{"type": "a", "href": mp.Mount, "text": "index of all packages"},
+			" available from " + mp.Mount + ".",
+		},
+	})
+
+	var data string
+	if data, err = MarshalNjn(section); err != nil {
+		err = fmt.Errorf("error encoding search page: %v - %v", url, err)
+		return
+	}
+
+	source := fmt.Sprintf(
+		gContentPageTemplate,
+		"Search", "Search the Debian packages available from "+mp.Mount, url,
+		"Search",
+		"package-search",
+		EscapeJson(summary),
+		data,
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), url, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new search page: %v - %v", url, err)
+		return
+	}
+	p.SetSlugUrl(url)
+	p.Context().SetSpecific("SiteSearchQuery", query)
+	return
+}
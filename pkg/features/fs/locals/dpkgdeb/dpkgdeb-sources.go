@@ -0,0 +1,591 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/fvbommel/sortorder"
+
+	"github.com/go-enjin/golang-org-x-text/language"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/log"
+	"github.com/go-enjin/be/types/page"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/checksums"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/dsc"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/version"
+)
+
+// gSourceFileStatuses are the outcomes of verifying a source package file
+const (
+	sourceFileVerified  = "verified"
+	sourceFileMissing   = "missing"
+	sourceFileSize      = "size mismatch"
+	sourceFileChecksum  = "checksum mismatch"
+	sourceFileUnchecked = "no checksum"
+)
+
+// gSourceHiddenFields are the .dsc fields not listed with the source package
+// fields, the files are listed separately
+var gSourceHiddenFields = []string{
+	"Files",
+	"Checksums-Sha1",
+	"Checksums-Sha256",
+	"Checksums-Sha512",
+	"Package-List",
+}
+
+// dpkgDsc is a source package within a mount point
+type dpkgDsc struct {
+	Name    string
+	Version string
+	Url     string
+	// File is the path of the .dsc within the mount point
+	File string
+	MP   *feature.CMountPoint
+
+	Text      string
+	Source    *dsc.Source
+	Signature *dsc.Signature
+	// Files are the verified files listed in the .dsc
+	Files []*dscFile
+
+	// inputs is the size and modification time of the .dsc and the files it
+	// lists, a changed value requires the source package to be read again
+	inputs string
+}
+
+// dscFile is a file listed in a .dsc and the outcome of verifying it
+type dscFile struct {
+	*dsc.File
+	Status string
+}
+
+// Issues returns the problems found verifying the signature and files of the
+// source package
+func (ds *dpkgDsc) Issues() (issues []string) {
+	switch ds.Signature.Status {
+	case dsc.Invalid:
+		issues = append(issues, "invalid signature: "+ds.Signature.Error)
+	case dsc.UnknownKey:
+		issues = append(issues, "signed by a key not within the keyring")
+	}
+	for _, file := range ds.Files {
+		switch file.Status {
+		case sourceFileMissing, sourceFileSize, sourceFileChecksum:
+			issues = append(issues, file.Name+": "+file.Status)
+		}
+	}
+	return
+}
+
+// sourceInputs returns the size and modification time of the .dsc and the
+// named files beside it
+func sourceInputs(fullpath string, names []string) (inputs string) {
+	var stats []string
+	for _, path := range append([]string{fullpath}, names...) {
+		if path != fullpath {
+			path = filepath.Join(filepath.Dir(fullpath), path)
+		}
+		if info, err := os.Stat(path); err == nil {
+			stats = append(stats, fmt.Sprintf("%s:%d:%d", filepath.Base(path), info.Size(), info.ModTime().UnixNano()))
+		} else {
+			stats = append(stats, filepath.Base(path)+":-")
+		}
+	}
+	inputs = strings.Join(stats, "\n")
+	return
+}
+
+// verifySourceFile returns the status of the listed file beside the .dsc
+func verifySourceFile(dir string, file *dsc.File) (status string) {
+	path := filepath.Join(dir, file.Name)
+	info, err := os.Stat(path)
+	switch {
+	case err != nil:
+		status = sourceFileMissing
+		return
+	case info.Size() != file.Size:
+		status = sourceFileSize
+		return
+	case file.SHA256 == "" && file.MD5 == "":
+		status = sourceFileUnchecked
+		return
+	}
+	var sums *checksums.Sums
+	if sums, err = checksums.HashFile(path); err != nil {
+		log.ErrorF("error hashing source package file: %v - %v", path, err)
+		status = sourceFileMissing
+		return
+	}
+	if (file.SHA256 != "" && file.SHA256 != sums.SHA256) || (file.MD5 != "" && file.MD5 != sums.MD5) {
+		status = sourceFileChecksum
+		return
+	}
+	status = sourceFileVerified
+	return
+}
+
+// readDpkgDsc parses and verifies the .dsc file within the mount point
+func (f *CFeature) readDpkgDsc(file string, mp *feature.CMountPoint) (ds *dpkgDsc, err error) {
+	fullpath := filepath.Join(mp.Path, file)
+	var data []byte
+	if data, err = os.ReadFile(fullpath); err != nil {
+		return
+	}
+	var src *dsc.Source
+	if src, err = dsc.Parse(data); err != nil {
+		err = fmt.Errorf("error parsing source package: %v - %w", file, err)
+		return
+	}
+
+	var keyring openpgp.KeyRing
+	if f.sourceKeyring != nil {
+		keyring = f.sourceKeyring
+	}
+
+	_, url := f.makeDebNameUrl(mp.Mount, file)
+	ds = &dpkgDsc{
+		Name:      src.Name(),
+		Version:   src.Version(),
+		Url:       url,
+		File:      file,
+		MP:        mp,
+		Text:      string(data),
+		Source:    src,
+		Signature: dsc.Verify(data, keyring),
+	}
+	var names []string
+	for _, listed := range src.Files {
+		names = append(names, listed.Name)
+		ds.Files = append(ds.Files, &dscFile{File: listed, Status: verifySourceFile(filepath.Dir(fullpath), listed)})
+	}
+	ds.inputs = sourceInputs(fullpath, names)
+	return
+}
+
+// scanSources adds any new or modified source packages and evicts any which
+// no longer exist, returning true if any source package changed
+func (f *CFeature) scanSources() (changed bool) {
+	f.sourcesLock.RLock()
+	previous := f.sources
+	f.sourcesLock.RUnlock()
+
+	sources := make(map[string]*dpkgDsc)
+	for _, mp := range f.mount {
+		files, _ := mp.ROFS.ListAllFiles(".")
+		for _, file := range files {
+			if !strings.HasSuffix(file, ".dsc") {
+				continue
+			}
+			_, url := f.makeDebNameUrl(mp.Mount, file)
+			fullpath := filepath.Join(mp.Path, file)
+
+			if existing, found := previous[url]; found && existing.File == file {
+				var names []string
+				for _, listed := range existing.Files {
+					names = append(names, listed.Name)
+				}
+				if sourceInputs(fullpath, names) == existing.inputs {
+					sources[url] = existing
+					continue
+				}
+			}
+
+			ds, err := f.readDpkgDsc(file, mp)
+			if err != nil {
+				log.ErrorF("error reading dpkg-dsc: %v", err)
+				continue
+			}
+			if issues := ds.Issues(); len(issues) > 0 {
+				log.WarnF("dpkg-dsc verification failed: %v - %v", url, strings.Join(issues, "; "))
+			}
+			sources[url] = ds
+			changed = true
+			log.DebugF("cached dpkg-dsc: %v", url)
+		}
+	}
+	for url := range previous {
+		if _, present := sources[url]; !present {
+			changed = true
+		}
+	}
+
+	if changed {
+		f.sourcesLock.Lock()
+		f.sources = sources
+		f.sourcesLock.Unlock()
+	}
+	return
+}
+
+// getSource returns the source package with the given url
+func (f *CFeature) getSource(url string) (ds *dpkgDsc, ok bool) {
+	f.sourcesLock.RLock()
+	defer f.sourcesLock.RUnlock()
+	ds, ok = f.sources[url]
+	return
+}
+
+// listSources returns the source packages of the mount point, by name and
+// newest version first
+func (f *CFeature) listSources(mp *feature.CMountPoint) (list []*dpkgDsc) {
+	f.sourcesLock.RLock()
+	for _, ds := range f.sources {
+		if ds.MP == mp {
+			list = append(list, ds)
+		}
+	}
+	f.sourcesLock.RUnlock()
+	sort.Slice(list, func(i, j int) (less bool) {
+		if list[i].Name != list[j].Name {
+			less = sortorder.NaturalLess(list[i].Name, list[j].Name)
+			return
+		}
+		less = version.Compare(list[i].Version, list[j].Version) > 0
+		return
+	})
+	return
+}
+
+// sourceOf returns the source package name and version of the binary package
+func sourceOf(dd *dpkgDeb) (name, version string) {
+	name, version = dd.Name, dd.Version
+	if value := dd.Control.Value("Source"); value != "" {
+		var rest string
+		name, rest, _ = strings.Cut(value, " ")
+		if v := strings.Trim(strings.TrimSpace(rest), "()"); v != "" {
+			version = v
+		}
+	}
+	return
+}
+
+// sourceBinaries returns the binary packages of the mount point built from
+// the source package
+func (f *CFeature) sourceBinaries(ds *dpkgDsc) (list []*dpkgDeb) {
+	for _, dd := range f.store.Query(packageQuery{MP: ds.MP}) {
+		if name, version := sourceOf(dd); name == ds.Name && version == ds.Version {
+			list = append(list, dd)
+		}
+	}
+	return
+}
+
+// binarySource returns the source package the binary package was built from,
+// if it is within the same mount point
+func (f *CFeature) binarySource(dd *dpkgDeb) (ds *dpkgDsc, ok bool) {
+	name, version := sourceOf(dd)
+	f.sourcesLock.RLock()
+	defer f.sourcesLock.RUnlock()
+	for _, candidate := range f.sources {
+		if candidate.MP == dd.MP && candidate.Name == name && candidate.Version == version {
+			ds, ok = candidate, true
+			return
+		}
+	}
+	return
+}
+
+// makeSourceNotice returns the JSON encoded njn paragraph linking to the
+// source package the binary package was built from, if there is one
+func (f *CFeature) makeSourceNotice(dd *dpkgDeb) (output string, err error) {
+	ds, ok := f.binarySource(dd)
+	if !ok {
+		return
+	}
+	output, err = MarshalNjn(map[string]interface{}{
+		"type": "p",
+		"text": []interface{}{
+			"Built from source package&nbsp;",
+			map[string]interface{}{"type": "a", "href": ds.Url, "text": []interface{}{html.EscapeString(ds.Name + " " + ds.Version)}},
+		},
+	})
+	return
+}
+
+// sourceFileUrl returns the public url of the named file beside the .dsc, if
+// the mount point has a download url configured
+func (f *CFeature) sourceFileUrl(ds *dpkgDsc, name string) (url string) {
+	url = f.mountDownloadUrl(ds.MP, filepath.Join(filepath.Dir(ds.File), name))
+	return
+}
+
+// makeSignatureNotice returns the JSON encoded njn paragraph describing the
+// signature of the source package
+func makeSignatureNotice(ds *dpkgDsc) (output string, err error) {
+	var text []interface{}
+	mark := func(message string) {
+		text = append(text, map[string]interface{}{
+			"type": "mark",
+			"text": []interface{}{
+				map[string]interface{}{"type": "strong", "text": []interface{}{html.EscapeString(message)}},
+			},
+		})
+	}
+	switch sig := ds.Signature; sig.Status {
+	case dsc.Valid:
+		text = append(text, html.EscapeString(fmt.Sprintf("Signed by %s (%s)", sig.Signer, sig.Fingerprint)))
+	case dsc.Unverified:
+		text = append(text, "Signed, the signature was not verified as no keyring is configured")
+	case dsc.UnknownKey:
+		mark("Signed by a key not within the keyring")
+	case dsc.Invalid:
+		mark("Invalid signature: " + sig.Error)
+	default:
+		mark("Not signed")
+	}
+	output, err = MarshalNjn(map[string]interface{}{"type": "p", "text": text})
+	return
+}
+
+// makeSourceBinariesBlock returns the JSON encoded njn fields listing the
+// binary packages built from the source package
+func (f *CFeature) makeSourceBinariesBlock(ds *dpkgDsc) (output string, err error) {
+	built := f.sourceBinaries(ds)
+
+	var missing []string
+	for _, name := range ds.Source.Binaries() {
+		var found bool
+		for _, dd := range built {
+			if found = dd.Name == name; found {
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, name)
+		}
+	}
+
+	var section []interface{}
+	if len(built) == 0 {
+		section = append(section, map[string]interface{}{"type": "p", "text": "No binary packages built from this source are within this archive."})
+	} else {
+		var rows []interface{}
+		for _, dd := range built {
+			rows = append(rows, map[string]interface{}{
+				"type": "tr",
+				"data": []interface{}{
+					map[string]interface{}{"type": "td", "text": []interface{}{
+						map[string]interface{}{"type": "a", "href": dd.Url, "text": []interface{}{html.EscapeString(dd.Name)}},
+					}},
+					map[string]interface{}{"type": "td", "text": html.EscapeString(dd.Version)},
+					map[string]interface{}{"type": "td", "text": html.EscapeString(dd.Architecture)},
+					map[string]interface{}{"type": "td", "text": html.EscapeString(dd.Component)},
+				},
+			})
+		}
+		section = append(section, map[string]interface{}{
+			"type": "table",
+			"head": []interface{}{
+				map[string]interface{}{"type": "th", "text": "Package"},
+				map[string]interface{}{"type": "th", "text": "Version"},
+				map[string]interface{}{"type": "th", "text": "Architecture"},
+				map[string]interface{}{"type": "th", "text": "Component"},
+			},
+			"body": rows,
+		})
+	}
+	if len(missing) > 0 {
+		section = append(section, map[string]interface{}{
+			"type": "p",
+			"text": html.EscapeString("Not within this archive: " + strings.Join(missing, ", ")),
+		})
+	}
+	output, err = MarshalNjn(section)
+	return
+}
+
+// makeSourceFilesBlock returns the JSON encoded njn fields listing the files
+// of the source package with their checksums and verification status
+func (f *CFeature) makeSourceFilesBlock(ds *dpkgDsc) (output string, err error) {
+	var rows []interface{}
+	for _, file := range ds.Files {
+		name := []interface{}{html.EscapeString(file.Name)}
+		if url := f.sourceFileUrl(ds, file.Name); url != "" && file.Status != sourceFileMissing {
+			name = []interface{}{map[string]interface{}{"type": "a", "href": url, "text": name}}
+		}
+		// the strongest checksum listed, the .dsc text has them all
+		var checksum []interface{}
+		for _, sum := range [][2]string{{"SHA256", file.SHA256}, {"SHA1", file.SHA1}, {"MD5", file.MD5}} {
+			if sum[1] != "" {
+				checksum = []interface{}{
+					map[string]interface{}{"type": "code", "code": []interface{}{sum[0] + ": " + html.EscapeString(sum[1])}},
+				}
+				break
+			}
+		}
+		status := []interface{}{html.EscapeString(file.Status)}
+		if file.Status != sourceFileVerified {
+			status = []interface{}{map[string]interface{}{"type": "mark", "text": status}}
+		}
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": name},
+				map[string]interface{}{"type": "td", "text": formatSize(file.Size)},
+				map[string]interface{}{"type": "td", "text": checksum},
+				map[string]interface{}{"type": "td", "text": status},
+			},
+		})
+	}
+
+	var section []interface{}
+	if len(rows) == 0 {
+		section = append(section, map[string]interface{}{"type": "p", "text": "No files are listed."})
+	} else {
+		section = append(section, map[string]interface{}{
+			"type": "table",
+			"head": []interface{}{
+				map[string]interface{}{"type": "th", "text": "File"},
+				map[string]interface{}{"type": "th", "text": "Size"},
+				map[string]interface{}{"type": "th", "text": "Checksum"},
+				map[string]interface{}{"type": "th", "text": "Status"},
+			},
+			"body": rows,
+		})
+	}
+	output, err = MarshalNjn(section)
+	return
+}
+
+// makeSourceIndexSection returns the njn fields listing the source packages
+// of the mount point, for the package index page
+func (f *CFeature) makeSourceIndexSection(mp *feature.CMountPoint) (section []interface{}) {
+	list := f.listSources(mp)
+	if len(list) == 0 {
+		return
+	}
+	var rows []interface{}
+	for _, ds := range list {
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "a", "href": ds.Url, "text": []interface{}{html.EscapeString(ds.Name)}},
+				}},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(ds.Version)},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(strings.Join(ds.Source.Binaries(), ", "))},
+				map[string]interface{}{"type": "td", "text": html.EscapeString(string(ds.Signature.Status))},
+			},
+		})
+	}
+	section = append(section,
+		map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{
+				map[string]interface{}{"type": "strong", "text": []interface{}{fmt.Sprintf("%d source packages", len(list))}},
+			},
+		},
+		map[string]interface{}{
+			"type": "table",
+			"head": []interface{}{
+				map[string]interface{}{"type": "th", "text": "Source"},
+				map[string]interface{}{"type": "th", "text": "Version"},
+				map[string]interface{}{"type": "th", "text": "Binaries"},
+				map[string]interface{}{"type": "th", "text": "Signature"},
+			},
+			"body": rows,
+		},
+	)
+	return
+}
+
+// cachedSourcePage returns a copy of the rendered page for the given source
+// package and language, making and caching it when the store changes
+func (f *CFeature) cachedSourcePage(r *http.Request, tag language.Tag, ds *dpkgDsc) (p feature.Page, err error) {
+	generation := f.store.Generation()
+	if cached, ok := f.pages.Get(ds.Url, tag, generation); ok {
+		p = cached
+		return
+	}
+	if p, err = f.makeSourcePage(r, ds); err != nil {
+		return
+	}
+	f.pages.Put(ds.Url, tag, generation, p)
+	p = p.Copy()
+	return
+}
+
+func (f *CFeature) makeSourcePage(r *http.Request, ds *dpkgDsc) (p feature.Page, err error) {
+	fullpath := filepath.Join(ds.MP.Path, ds.File)
+	name := filepath.Base(ds.File)
+
+	var signatureNotice string
+	if signatureNotice, err = makeSignatureNotice(ds); err != nil {
+		err = fmt.Errorf("error encoding signature notice: %v - %v", fullpath, err)
+		return
+	}
+
+	var binariesBlock string
+	if binariesBlock, err = f.makeSourceBinariesBlock(ds); err != nil {
+		err = fmt.Errorf("error encoding source binaries: %v - %v", fullpath, err)
+		return
+	}
+
+	var filesBlock string
+	if filesBlock, err = f.makeSourceFilesBlock(ds); err != nil {
+		err = fmt.Errorf("error encoding source files: %v - %v", fullpath, err)
+		return
+	}
+
+	var dscBlock string
+	if dscBlock, err = MarshalNjn([]interface{}{
+		map[string]interface{}{
+			"type":      "code",
+			"decorated": "true",
+			"code":      strings.Split(strings.TrimRight(ds.Text, "\n"), "\n"),
+		},
+	}); err != nil {
+		err = fmt.Errorf("error encoding dsc: %v - %v", fullpath, err)
+		return
+	}
+
+	var fields string
+	if fields, err = MakePackageFields(ds.Source.Control, gSourceHiddenFields...); err != nil {
+		err = fmt.Errorf("error encoding source fields: %v - %v", fullpath, err)
+		return
+	}
+
+	source := fmt.Sprintf(
+		gSourcePageTemplate,
+		name, "Debian source package details for "+name, ds.Url,
+		name,
+		fields,
+		EscapeJson(html.EscapeString(ds.Name+" "+ds.Version+" source package")), signatureNotice,
+		binariesBlock,
+		filesBlock,
+		dscBlock,
+	)
+
+	created := time.Now().Unix()
+	t := f.Enjin.MustGetTheme()
+	if p, err = page.New(f.Tag().Kebab(), fullpath, source, created, created, t, f.Enjin.Context(r)); err != nil {
+		err = fmt.Errorf("error making new source page: %v - %v", fullpath, err)
+		return
+	}
+	p.SetSlugUrl(ds.Url)
+	return
+}
@@ -0,0 +1,205 @@
+// Copyright (c) 2023  The Go-Enjin Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dpkgdeb
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/go-enjin/be/pkg/feature"
+	"github.com/go-enjin/be/pkg/maps"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/version"
+)
+
+// packageQuery selects packages from a packageStore, empty fields match all
+// packages
+type packageQuery struct {
+	Name         string
+	Version      string
+	Architecture string
+	Component    string
+	Section      string
+	// MP is the mount point the package was found within
+	MP *feature.CMountPoint
+	// Search is a case-insensitive substring of the package name or synopsis
+	Search string
+}
+
+func (q packageQuery) matches(dd *dpkgDeb) (ok bool) {
+	ok = (q.Name == "" || q.Name == dd.Name) &&
+		(q.Version == "" || q.Version == dd.Version) &&
+		(q.Architecture == "" || q.Architecture == dd.Architecture) &&
+		(q.Component == "" || q.Component == dd.Component) &&
+		(q.Section == "" || q.Section == dd.Control.Value("Section")) &&
+		(q.MP == nil || q.MP == dd.MP)
+	if ok && q.Search != "" {
+		search := strings.ToLower(q.Search)
+		ok = strings.Contains(strings.ToLower(dd.Name), search) ||
+			strings.Contains(strings.ToLower(dd.Control.Synopsis()), search)
+	}
+	return
+}
+
+// packageStore is the concurrency-safe collection of all known packages,
+// indexed by url and package name. Stored dpkgDeb instances must not be
+// modified, replace them with Put instead. The generation is incremented on
+// every change
+type packageStore struct {
+	byUrl      map[string]*dpkgDeb
+	byName     map[string]map[string]*dpkgDeb
+	generation uint64
+
+	sync.RWMutex
+}
+
+func newPackageStore() (s *packageStore) {
+	s = &packageStore{
+		byUrl:  make(map[string]*dpkgDeb),
+		byName: make(map[string]map[string]*dpkgDeb),
+	}
+	return
+}
+
+// Len returns the number of packages stored
+func (s *packageStore) Len() (count int) {
+	s.RLock()
+	defer s.RUnlock()
+	count = len(s.byUrl)
+	return
+}
+
+// Generation returns the current change counter
+func (s *packageStore) Generation() (generation uint64) {
+	s.RLock()
+	defer s.RUnlock()
+	generation = s.generation
+	return
+}
+
+// Get returns the package with the given url
+func (s *packageStore) Get(url string) (dd *dpkgDeb, ok bool) {
+	s.RLock()
+	defer s.RUnlock()
+	dd, ok = s.byUrl[url]
+	return
+}
+
+// Put adds or replaces the package, returning any previous package stored
+// with the same url
+func (s *packageStore) Put(dd *dpkgDeb) (previous *dpkgDeb) {
+	s.Lock()
+	defer s.Unlock()
+	if previous = s.byUrl[dd.Url]; previous != nil {
+		s.unindex(previous)
+	}
+	s.byUrl[dd.Url] = dd
+	if _, present := s.byName[dd.Name]; !present {
+		s.byName[dd.Name] = make(map[string]*dpkgDeb)
+	}
+	s.byName[dd.Name][dd.Url] = dd
+	s.generation += 1
+	return
+}
+
+// Delete removes and returns the package with the given url
+func (s *packageStore) Delete(url string) (removed *dpkgDeb) {
+	s.Lock()
+	defer s.Unlock()
+	if removed = s.byUrl[url]; removed != nil {
+		s.unindex(removed)
+		delete(s.byUrl, url)
+		s.generation += 1
+	}
+	return
+}
+
+// Retain removes and returns all packages for which keep returns false
+func (s *packageStore) Retain(keep func(dd *dpkgDeb) bool) (evicted []*dpkgDeb) {
+	s.Lock()
+	defer s.Unlock()
+	for _, url := range maps.SortedKeys(s.byUrl) {
+		if dd := s.byUrl[url]; !keep(dd) {
+			s.unindex(dd)
+			delete(s.byUrl, url)
+			evicted = append(evicted, dd)
+			s.generation += 1
+		}
+	}
+	return
+}
+
+func (s *packageStore) unindex(dd *dpkgDeb) {
+	if named, present := s.byName[dd.Name]; present {
+		delete(named, dd.Url)
+		if len(named) == 0 {
+			delete(s.byName, dd.Name)
+		}
+	}
+}
+
+// Names returns the sorted list of unique package names
+func (s *packageStore) Names() (names []string) {
+	s.RLock()
+	defer s.RUnlock()
+	names = maps.SortedKeys(s.byName)
+	return
+}
+
+// List returns all packages, see sortPackages
+func (s *packageStore) List() (list []*dpkgDeb) {
+	list = s.Query(packageQuery{})
+	return
+}
+
+// Query returns all packages matching the given query, see sortPackages
+func (s *packageStore) Query(q packageQuery) (list []*dpkgDeb) {
+	s.RLock()
+	defer s.RUnlock()
+
+	var candidates map[string]*dpkgDeb
+	if q.Name != "" {
+		candidates = s.byName[q.Name]
+	} else {
+		candidates = s.byUrl
+	}
+
+	for _, dd := range candidates {
+		if q.matches(dd) {
+			list = append(list, dd)
+		}
+	}
+	sortPackages(list)
+	return
+}
+
+// sortPackages sorts the list by name, newest version first, component,
+// architecture and url
+func sortPackages(list []*dpkgDeb) {
+	sort.Slice(list, func(i, j int) (less bool) {
+		a, b := list[i], list[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		} else if cmp := version.Compare(a.Version, b.Version); cmp != 0 {
+			return cmp > 0
+		} else if a.Component != b.Component {
+			return a.Component < b.Component
+		} else if a.Architecture != b.Architecture {
+			return a.Architecture < b.Architecture
+		}
+		return a.Url < b.Url
+	})
+}
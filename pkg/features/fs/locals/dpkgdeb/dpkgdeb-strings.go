@@ -15,75 +15,62 @@
 package dpkgdeb
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
-	"regexp"
+	"html"
+	"net/url"
+	"slices"
 	"strings"
 
-	"github.com/go-enjin/be/pkg/log"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/control"
 )
 
-func EscapeQuotes(input string) (output string) {
-	output = strings.ReplaceAll(input, `"`, `\"`)
-	output = strings.ReplaceAll(output, "\n", `\n`)
+// EscapeJson returns the input encoded for use within a double quoted JSON
+// string, such as the template placeholders of page sources
+func EscapeJson(input string) (output string) {
+	data, _ := json.Marshal(input)
+	output = string(data[1 : len(data)-1])
 	return
 }
 
-var (
-	RxDpkgDebInfoLine = regexp.MustCompile(`^\s*([-_a-zA-Z0-9]+?):\s*(.+?)\s*$`)
-	RxDpkgDebInfoDesc = regexp.MustCompile(`(?ms)^\s*Description:\s*(.+?)$(.+?)\z`)
-	rxNameAndEmail    = regexp.MustCompile(`^\s*(.+?)\s*<([^>]+?)>\s*$`)
-)
-
-func ParseDpkgDebInfoOutput(output string) (parsed map[string]string, lines []string, order []string) {
-	parsed = make(map[string]string)
-	lines = strings.Split(output, "\n")
-	for _, line := range lines {
-		if RxDpkgDebInfoLine.MatchString(line) {
-			m := RxDpkgDebInfoLine.FindAllStringSubmatch(line, 1)
-			parsed[m[0][1]] = m[0][2]
-			order = append(order, m[0][1])
-		}
+// MarshalNjn returns the indented JSON encoding of the given njn fields.
+// Page bodies are parsed line by line and so no line may exceed the
+// bufio.MaxScanTokenSize
+func MarshalNjn(v interface{}) (output string, err error) {
+	var data []byte
+	if data, err = json.MarshalIndent(v, "", "\t"); err != nil {
+		return
 	}
-	if v, ok := parsed["Maintainer"]; ok {
-		if rxNameAndEmail.MatchString(v) {
-			m := rxNameAndEmail.FindAllStringSubmatch(v, 1)
-			parsed["MaintainerName"] = m[0][1]
-			parsed["MaintainerMail"] = m[0][2]
-		} else {
-			log.ErrorF("error parsing name and email: %v", v)
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		if len(line) >= bufio.MaxScanTokenSize {
+			err = fmt.Errorf("njn line exceeds %d bytes", bufio.MaxScanTokenSize)
+			return
 		}
 	}
-	if RxDpkgDebInfoDesc.MatchString(output) {
-		m := RxDpkgDebInfoDesc.FindAllStringSubmatch(output, 1)
-		parsed["Description"] = m[0][1]
-		parsed["LongDescription"] = m[0][2]
-	} else {
-		log.ErrorF("error parsing long description from dpkg-deb --info output:\n[begin output]\n%v[end output]", output)
-	}
+	output = string(data)
 	return
 }
 
-func DecomposeDpkgDebInfo(parsed map[string]string) (name, summary, description, section, version, homepage string) {
-	for key, value := range parsed {
-		switch key {
-		case "Package":
-			name = value
-		case "Description":
-			summary = value
-		case "LongDescription":
-			description = value
-		case "Section":
-			section = value
-		case "Version":
-			version = value
-		case "Homepage":
-			homepage = value
-		}
+// makeDetails returns the njn details field with the given summary and text
+// fields, optionally open by default. Unlike inline text, the summary is a
+// plain string escaped by the theme template and so must not be html escaped
+func makeDetails(summary string, open bool, text ...interface{}) (field map[string]interface{}) {
+	field = map[string]interface{}{
+		"type":    "details",
+		"summary": summary,
+		"text":    text,
+	}
+	if open {
+		field["attributes"] = map[string]interface{}{"open": "open"}
 	}
 	return
 }
 
-func MakeLongDescriptionParagraphs(input string) (output string) {
+// MakeLongDescriptionParagraphs returns the comma separated, JSON encoded njn
+// paragraphs of the given extended package description
+func MakeLongDescriptionParagraphs(input string) (output string, err error) {
 	var paragraphs []string
 	var current string
 	for _, line := range strings.Split(input, "\n") {
@@ -100,59 +87,86 @@ func MakeLongDescriptionParagraphs(input string) (output string) {
 	if current != "" {
 		paragraphs = append(paragraphs, current)
 	}
+	encoded := make([]string, len(paragraphs))
 	for idx, paragraph := range paragraphs {
-		if idx > 0 {
-			output += ","
+		if encoded[idx], err = MarshalNjn(map[string]interface{}{
+			"type": "p",
+			"text": []interface{}{html.EscapeString(paragraph)},
+		}); err != nil {
+			return
 		}
-		output += fmt.Sprintf(`{"type":"p","text":["%v"]}`, EscapeQuotes(paragraph))
+	}
+	output = strings.Join(encoded, ",")
+	return
+}
+
+// isWebUrl reports whether the given value is an absolute http or https url
+// and so safe to use as a link target
+func isWebUrl(value string) (ok bool) {
+	if u, err := url.Parse(value); err == nil && u.Host != "" {
+		ok = u.Scheme == "http" || u.Scheme == "https"
 	}
 	return
 }
 
-func MakePackageFields(parsed map[string]string, order []string) (output string) {
-	var rows []string
-
-	for _, key := range order {
-		if v, ok := parsed[key]; ok {
-			var value string
-			ev := EscapeQuotes(v)
-			switch key {
-			case "Homepage":
-				value = fmt.Sprintf(
-					`{"type":"a","href":"%v","text":["%v"],"target":"_blank"}`,
-					ev, ev,
-				)
-			case "Maintainer":
-				if mail, ok := parsed["MaintainerMail"]; ok {
-					escMail := EscapeQuotes(mail)
-					if name, ok := parsed["MaintainerName"]; ok {
-						value = fmt.Sprintf(
-							`{"type":"a","href":"mailto:%v","text":["%v"]}`,
-							escMail,
-							EscapeQuotes(name),
-						)
-					} else {
-						value = fmt.Sprintf(
-							`{"type":"a","href":"mailto:%v","text":["%v"]}`,
-							escMail,
-							escMail,
-						)
-					}
-				} else {
-					value = `"(missing)"`
+// MakePackageFields returns the JSON encoded njn table of the control file
+// fields, omitting the skipped field names
+func MakePackageFields(ctrl *control.Paragraph, skip ...string) (output string, err error) {
+	var rows []interface{}
+
+	for _, field := range ctrl.Fields {
+		if slices.Contains(skip, field.Name) {
+			continue
+		}
+		var value interface{}
+		switch field.Name {
+		case "Homepage", "Vcs-Browser":
+			if link := strings.TrimSpace(field.Value); isWebUrl(link) {
+				value = map[string]interface{}{
+					"type":   "a",
+					"href":   link,
+					"text":   []interface{}{html.EscapeString(link)},
+					"target": "_blank",
 				}
-			case "MaintainerName", "MaintainerMail", "Installed-Size":
-				continue
-			default:
-				value = `"` + ev + `"`
+			} else {
+				value = html.EscapeString(link)
 			}
-
-			row := `{"type": "tr","data": [{ "type": "td", "text": [{"type":"b","text":["%v"]}] },{ "type": "td", "text": [%v] }]}`
-			rows = append(rows, fmt.Sprintf(row, key, value))
+		case "Maintainer":
+			if name, mail := control.ParseContact(control.Fold(field.Value)); mail != "" {
+				if name == "" {
+					name = mail
+				}
+				value = map[string]interface{}{
+					"type": "a",
+					"href": "mailto:" + mail,
+					"text": []interface{}{html.EscapeString(name)},
+				}
+			} else if name != "" {
+				value = html.EscapeString(name)
+			} else {
+				value = "(missing)"
+			}
+		case "Description":
+			synopsis, _, _ := strings.Cut(field.Value, "\n")
+			value = html.EscapeString(synopsis)
+		case "Installed-Size":
+			continue
+		default:
+			value = html.EscapeString(control.Fold(field.Value))
 		}
+
+		rows = append(rows, map[string]interface{}{
+			"type": "tr",
+			"data": []interface{}{
+				map[string]interface{}{"type": "td", "text": []interface{}{
+					map[string]interface{}{"type": "b", "text": []interface{}{html.EscapeString(field.Name)}},
+				}},
+				map[string]interface{}{"type": "td", "text": []interface{}{value}},
+			},
+		})
 	}
 
-	output = fmt.Sprintf(`{"type":"table","body":[%v]}`, strings.Join(rows, ","))
+	output, err = MarshalNjn(map[string]interface{}{"type": "table", "body": rows})
 	return
 }
 
@@ -162,7 +176,9 @@ func MakePackageFields(parsed map[string]string, order []string) (output string)
 //   - pageHeader
 //   - fields
 //   - summary, description
-//   - infoBlock, contentsBlock
+//   - lint, relationships, reverse-dependencies, changelog, contents, control
+//     (JSON encoded lists of njn fields)
+//   - infoBlock
 const gPageTemplate = `+++
 "title" = "%v"
 "description" = "%v"
@@ -230,7 +246,103 @@ const gPageTemplate = `+++
 
                 {
                     "type": "content",
-                    "tag": "dpkg-deb--info--contents",
+                    "tag": "lint",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Lint"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "relationships",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Relationships"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "reverse-dependencies",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Reverse dependencies"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "changelog",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Changelog"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "contents",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Contents"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "control-files",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Control archive"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "dpkg-deb--info",
                     "profile": "outer--inner",
                     "padding": "both",
                     "margins": "both",
@@ -238,12 +350,12 @@ const gPageTemplate = `+++
                     "jump-link": "true",
                     "content": {
                         "header": [
-                            "dpkg-deb --info --contents"
+                            "dpkg-deb --info"
                         ],
                         "section": [
                             {
                                 "type": "code",
-                                "code": [%v,%v]
+                                "code": [%v]
                             }
                         ]
                     }
@@ -253,4 +365,285 @@ const gPageTemplate = `+++
         }
     }
 
+]`
+
+// gContentPageTemplate requires the following Sprintf arguments:
+//
+//   - pageTitle, pageDesc, pageUrl
+//   - pageHeader
+//   - contentTag, contentHeader
+//   - section (JSON encoded list of njn fields)
+const gContentPageTemplate = `+++
+"title" = "%v"
+"description" = "%v"
+"url" = "%v"
+"format" = "njn"
+"language" = "en"
++++
+[
+	{
+        "type": "header",
+        "tag": "main-header",
+        "profile": "outer--inner",
+        "padding": "top",
+        "margins": "bottom",
+        "content": {
+            "header": [
+                "%v"
+            ]
+        }
+    },
+
+    {
+        "type": "content",
+        "tag": "%v",
+        "profile": "outer--inner",
+        "padding": "both",
+        "margins": "both",
+        "jump-top": "true",
+        "jump-link": "true",
+        "content": {
+            "header": [
+                "%v"
+            ],
+            "section": %v
+        }
+    }
+
+]`
+
+// gPackagePageTemplate requires the following Sprintf arguments:
+//
+//   - pageTitle, pageDesc, pageUrl
+//   - pageHeader
+//   - fields
+//   - summary, description
+//   - downloads, history (JSON encoded lists of njn fields)
+const gPackagePageTemplate = `+++
+"title" = "%v"
+"description" = "%v"
+"url" = "%v"
+"format" = "njn"
+"language" = "en"
++++
+[
+	{
+        "type": "header",
+        "tag": "main-header",
+        "profile": "outer--inner",
+        "padding": "top",
+        "margins": "bottom",
+        "content": {
+            "header": [
+                "%v"
+            ]
+        }
+    },
+
+    {
+        "tag": "main-sidebar",
+        "type": "sidebar",
+        "profile": "full--outer",
+        "padding": "none",
+        "margins": "bottom",
+        "side": "right",
+        "sticky": "true",
+        "stack": "top",
+        "jump-top": "true",
+        "jump-link": "true",
+        "content": {
+
+            "aside": [
+
+                 {
+                    "tag": "deb-fields",
+                    "type": "content",
+                    "profile": "full--full",
+                    "content": {
+                        "section": [%v]
+                    }
+                }
+
+            ],
+
+            "blocks": [
+
+                {
+                    "type": "content",
+                    "tag": "package-summary",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "%v"
+                        ],
+                        "section": [%v]
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "latest-downloads",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Latest downloads"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "version-history",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Version history"
+                        ],
+                        "section": %v
+                    }
+                }
+
+            ]
+        }
+    }
+
+]`
+
+// gSourcePageTemplate requires the following Sprintf arguments:
+//
+//   - pageTitle, pageDesc, pageUrl
+//   - pageHeader
+//   - fields
+//   - summary, signature (JSON encoded njn field)
+//   - binaries, files, dsc (JSON encoded lists of njn fields)
+const gSourcePageTemplate = `+++
+"title" = "%v"
+"description" = "%v"
+"url" = "%v"
+"format" = "njn"
+"language" = "en"
++++
+[
+	{
+        "type": "header",
+        "tag": "main-header",
+        "profile": "outer--inner",
+        "padding": "top",
+        "margins": "bottom",
+        "content": {
+            "header": [
+                "%v"
+            ]
+        }
+    },
+
+    {
+        "tag": "main-sidebar",
+        "type": "sidebar",
+        "profile": "full--outer",
+        "padding": "none",
+        "margins": "bottom",
+        "side": "right",
+        "sticky": "true",
+        "stack": "top",
+        "jump-top": "true",
+        "jump-link": "true",
+        "content": {
+
+            "aside": [
+
+                 {
+                    "tag": "dsc-fields",
+                    "type": "content",
+                    "profile": "full--full",
+                    "content": {
+                        "section": [%v]
+                    }
+                }
+
+            ],
+
+            "blocks": [
+
+                {
+                    "type": "content",
+                    "tag": "source-summary",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "%v"
+                        ],
+                        "section": [%v]
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "binary-packages",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Binary packages"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "source-files",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Source files"
+                        ],
+                        "section": %v
+                    }
+                },
+
+                {
+                    "type": "content",
+                    "tag": "source-control",
+                    "profile": "outer--inner",
+                    "padding": "both",
+                    "margins": "both",
+                    "jump-top": "true",
+                    "jump-link": "true",
+                    "content": {
+                        "header": [
+                            "Source control file"
+                        ],
+                        "section": %v
+                    }
+                }
+
+            ]
+        }
+    }
+
 ]`
\ No newline at end of file
@@ -17,9 +17,13 @@ package dpkgdeb
 import (
 	"fmt"
 	"net/http"
+	"os"
+	"runtime"
 	"sort"
-	"strings"
+	"sync"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
 	"github.com/fvbommel/sortorder"
 	"github.com/urfave/cli/v2"
 
@@ -31,8 +35,12 @@ import (
 	uses_actions "github.com/go-enjin/be/pkg/feature/uses-actions"
 	"github.com/go-enjin/be/pkg/forms"
 	"github.com/go-enjin/be/pkg/fs"
+	"github.com/go-enjin/be/pkg/lang"
 	"github.com/go-enjin/be/pkg/log"
 	"github.com/go-enjin/be/pkg/maps"
+
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/dsc"
+	"github.com/go-enjin/starter-apt-enjin/pkg/debian/lint"
 )
 
 var (
@@ -42,6 +50,13 @@ var (
 
 var (
 	DefaultCacheControl = "max-age=604800, must-revalidate"
+	DefaultScanWorkers  = runtime.NumCPU()
+	// DefaultFileSizeLimit is the largest package file served by the files
+	// route, unless changed with SetFileSizeLimit
+	DefaultFileSizeLimit int64 = 50 * 1024 * 1024
+	// DefaultInlineTextLimit is the largest text file shown as a page by the
+	// files route, larger files are downloaded
+	DefaultInlineTextLimit int64 = 256 * 1024
 )
 
 const (
@@ -54,11 +69,65 @@ type Feature interface {
 	feature.PageProvider
 	feature.UseMiddleware
 	feature.UserActionsProvider
+	feature.FuncMapProvider
+	feature.MenuProvider
+	feature.PageContextModifier
+
+	// PageCacheStats returns the current rendered page cache counters
+	PageCacheStats() (stats PageCacheStats)
 }
 
 type MakeFeature interface {
 	MountPath(mount, path string) MakeFeature
+	// SetDownloadUrl specifies the public url the package files within the
+	// given mounted path are served from, used for download links and the
+	// latest.deb redirects
+	SetDownloadUrl(path, url string) MakeFeature
 	SetCacheControl(values string) MakeFeature
+	// SetDpkgDebFallback enables running `dpkg-deb` when the native package
+	// reader fails to parse a .deb file
+	SetDpkgDebFallback(enabled bool) MakeFeature
+	// SetRescanInterval specifies how often to rescan the mount points for
+	// added, modified or removed packages, a zero duration disables rescans
+	SetRescanInterval(interval time.Duration) MakeFeature
+	// SetScanWorkers specifies the number of packages to read concurrently
+	// while scanning the mount points, defaults to the number of CPUs
+	SetScanWorkers(count int) MakeFeature
+	// SetBackgroundIndexing enables serving requests before the initial scan
+	// of the mount points has completed
+	SetBackgroundIndexing(enabled bool) MakeFeature
+	// SetCachePath specifies a directory to persist parsed package content,
+	// unchanged packages are loaded from the cache instead of being parsed
+	SetCachePath(path string) MakeFeature
+	// SetPageCacheSize specifies the maximum number of rendered pages to keep
+	// in memory, zero is unlimited
+	SetPageCacheSize(entries int) MakeFeature
+	// SetApiPath specifies the url path to serve the JSON package API from,
+	// an empty path disables the API
+	SetApiPath(path string) MakeFeature
+	// SetFileSizeLimit specifies the largest package file served by the
+	// <package>.deb/files/<path> route, zero removes the limit
+	SetFileSizeLimit(size int64) MakeFeature
+	// SetLintRules replaces the lint rules checked for each package, which
+	// default to lint.DefaultRules, no rules disables linting
+	SetLintRules(rules ...lint.Rule) MakeFeature
+	// AddLintRules appends custom lint rules to those checked for each
+	// package
+	AddLintRules(rules ...lint.Rule) MakeFeature
+	// AddBasePackages specifies upstream Packages index files, or glob
+	// patterns, of the base system the named codename within the given mounted
+	// path is installed on. The files may be gzip or xz compressed. Without a
+	// base system, installability checks assume dependencies on packages
+	// outside the archive are met
+	AddBasePackages(path, codename string, patterns ...string) MakeFeature
+	// SetSourceKeyring specifies the armored, or binary, OpenPGP keyring file
+	// used to verify the signatures of .dsc source packages, without one the
+	// signatures are not verified
+	SetSourceKeyring(path string) MakeFeature
+	// SetMenu specifies the name of the site menu to provide, such as
+	// "main-menu", linking to the package index of each mount point. An empty
+	// name, the default, provides no menu
+	SetMenu(name string) MakeFeature
 
 	Make() Feature
 }
@@ -69,11 +138,52 @@ type CFeature struct {
 
 	search bleve.Feature
 
-	setup map[string]string
-	mount []*feature.CMountPoint
-	infos map[string]*dpkgDeb
+	setup     map[string]string
+	mount     []*feature.CMountPoint
+	downloads map[string]string
+	store     *packageStore
 
 	cacheControl string
+	dpkgFallback bool
+
+	rescanInterval     time.Duration
+	scanWorkers        int
+	backgroundIndexing bool
+	scanning           sync.Mutex
+	stop               chan struct{}
+	// indexed are the Packages index digests of each mount, guarded by the
+	// scanning lock
+	indexed map[string]*packageIndexDigests
+	// parsed are the Packages indices of all mounts, keyed by path
+	parsed     map[string]*parsedIndex
+	parsedLock sync.Mutex
+
+	cachePath string
+	cache     *diskCache
+	pages     *pageCache
+
+	apiPath string
+
+	fileSizeLimit int64
+
+	lintRules []lint.Rule
+
+	basePackages map[string]map[string][]string
+	health       map[string]*healthReport
+	healthLock   sync.RWMutex
+
+	sourceKeyringPath string
+	sourceKeyring     openpgp.EntityList
+	sources           map[string]*dpkgDsc
+	sourcesLock       sync.RWMutex
+
+	dists     map[string]*distsReport
+	distsLock sync.RWMutex
+
+	relations     map[string]*relationIndex
+	relationsLock sync.Mutex
+
+	menuName string
 }
 
 func New() MakeFeature {
@@ -93,7 +203,18 @@ func NewTagged(tag feature.Tag) MakeFeature {
 func (f *CFeature) Init(this interface{}) {
 	f.CFeature.Init(this)
 	f.setup = make(map[string]string)
-	f.infos = make(map[string]*dpkgDeb)
+	f.downloads = make(map[string]string)
+	f.store = newPackageStore()
+	f.pages = newPageCache(0)
+	f.fileSizeLimit = DefaultFileSizeLimit
+	f.lintRules = lint.DefaultRules()
+	f.basePackages = make(map[string]map[string][]string)
+	f.health = make(map[string]*healthReport)
+	f.sources = make(map[string]*dpkgDsc)
+	f.dists = make(map[string]*distsReport)
+	f.indexed = make(map[string]*packageIndexDigests)
+	f.parsed = make(map[string]*parsedIndex)
+	f.relations = make(map[string]*relationIndex)
 }
 
 func (f *CFeature) MountPath(mount, path string) MakeFeature {
@@ -101,16 +222,93 @@ func (f *CFeature) MountPath(mount, path string) MakeFeature {
 	return f
 }
 
+func (f *CFeature) SetDownloadUrl(path, url string) MakeFeature {
+	f.downloads[path] = url
+	return f
+}
+
 func (f *CFeature) SetCacheControl(values string) MakeFeature {
 	f.cacheControl = values
 	return f
 }
 
+func (f *CFeature) SetDpkgDebFallback(enabled bool) MakeFeature {
+	f.dpkgFallback = enabled
+	return f
+}
+
+func (f *CFeature) SetRescanInterval(interval time.Duration) MakeFeature {
+	f.rescanInterval = interval
+	return f
+}
+
+func (f *CFeature) SetScanWorkers(count int) MakeFeature {
+	f.scanWorkers = count
+	return f
+}
+
+func (f *CFeature) SetBackgroundIndexing(enabled bool) MakeFeature {
+	f.backgroundIndexing = enabled
+	return f
+}
+
+func (f *CFeature) SetCachePath(path string) MakeFeature {
+	f.cachePath = path
+	return f
+}
+
+func (f *CFeature) SetPageCacheSize(entries int) MakeFeature {
+	f.pages = newPageCache(entries)
+	return f
+}
+
+func (f *CFeature) SetApiPath(path string) MakeFeature {
+	if path != "" {
+		path = forms.CleanRequestPath(path)
+	}
+	f.apiPath = path
+	return f
+}
+
+func (f *CFeature) SetFileSizeLimit(size int64) MakeFeature {
+	f.fileSizeLimit = size
+	return f
+}
+
+func (f *CFeature) SetLintRules(rules ...lint.Rule) MakeFeature {
+	f.lintRules = rules
+	return f
+}
+
+func (f *CFeature) AddLintRules(rules ...lint.Rule) MakeFeature {
+	f.lintRules = append(f.lintRules, rules...)
+	return f
+}
+
+func (f *CFeature) AddBasePackages(path, codename string, patterns ...string) MakeFeature {
+	if _, present := f.basePackages[path]; !present {
+		f.basePackages[path] = make(map[string][]string)
+	}
+	f.basePackages[path][codename] = append(f.basePackages[path][codename], patterns...)
+	return f
+}
+
+func (f *CFeature) SetSourceKeyring(path string) MakeFeature {
+	f.sourceKeyringPath = path
+	return f
+}
+
+func (f *CFeature) SetMenu(name string) MakeFeature {
+	f.menuName = name
+	return f
+}
+
 func (f *CFeature) Make() Feature {
 	return f
 }
 
-func (f *CFeature) Build(_ feature.Buildable) (err error) {
+func (f *CFeature) Build(b feature.Buildable) (err error) {
+	err = f.registerHighlighter(b)
 	return
 }
 
@@ -128,6 +326,27 @@ func (f *CFeature) Setup(enjin feature.Internals) {
 	}
 
 	var err error
+	if f.cachePath != "" {
+		if f.cache, err = newDiskCache(f.cachePath); err != nil {
+			log.FatalF("error preparing dpkg-deb cache: %v", err)
+			return
+		}
+		log.DebugF("using dpkg-deb cache path: %v", f.cachePath)
+	}
+
+	if f.sourceKeyringPath != "" {
+		var data []byte
+		if data, err = os.ReadFile(f.sourceKeyringPath); err != nil {
+			log.FatalF("error reading source keyring: %v", err)
+			return
+		}
+		if f.sourceKeyring, err = dsc.ReadKeyRing(data); err != nil {
+			log.FatalF("error loading source keyring: %v - %v", f.sourceKeyringPath, err)
+			return
+		}
+		log.DebugF("using source keyring: %v", f.sourceKeyringPath)
+	}
+
 	for _, path := range maps.SortedKeys(f.setup) {
 
 		var lfs fs.FileSystem
@@ -153,27 +372,33 @@ func (f *CFeature) Startup(ctx *cli.Context) (err error) {
 		return
 	}
 
-	for _, mp := range f.mount {
-		files, _ := mp.ROFS.ListAllFiles(".")
-		for _, file := range files {
-			if strings.HasSuffix(file, ".deb") || strings.HasSuffix(file, ".udeb") {
-				_, url := f.makeDebNameUrl(mp.Mount, file)
-				if f.infos[url], err = f.makeDpkgDeb(file, mp); err != nil {
-					err = fmt.Errorf("error caching dpkg-deb outputs: %v - %w", file, err)
-					return
-				}
-				if p, ee := f.makeDebPage(nil, f.infos[url]); ee == nil {
-					if err = f.search.AddToSearchIndex(nil, p); err != nil {
-						err = fmt.Errorf("error indexing dpkg-deb page: %v - %w", url, err)
-						return
-					}
-				}
-				log.DebugF("cached and indexed dpkg-deb: %v", url)
+	if f.rescanInterval > 0 {
+		f.stop = make(chan struct{})
+	}
+
+	if f.backgroundIndexing {
+		go func(stop chan struct{}) {
+			if ee := f.startupScan(stop); ee != nil {
+				log.ErrorF("error scanning dpkg-deb mount points: %v", ee)
 			}
-		}
+		}(f.stop)
+		return
 	}
 
-	// err = fmt.Errorf("testing")
+	err = f.startupScan(f.stop)
+	return
+}
+
+func (f *CFeature) Shutdown() {
+	if f.stop != nil {
+		close(f.stop)
+		f.stop = nil
+	}
+	f.CFeature.Shutdown()
+}
+
+func (f *CFeature) PageCacheStats() (stats PageCacheStats) {
+	stats = f.pages.Stats()
 	return
 }
 
@@ -187,6 +412,9 @@ func (f *CFeature) Use(s feature.System) feature.MiddlewareFn {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			path := forms.CleanRequestPath(r.URL.Path)
+			if f.ServeApi(path, w, r) {
+				return
+			}
 			if err := f.ServePath(path, s, w, r); err == nil {
 				return
 			} else if err.Error() != "path not found" {
@@ -199,33 +427,55 @@ func (f *CFeature) Use(s feature.System) feature.MiddlewareFn {
 
 func (f *CFeature) ServePath(path string, _ feature.System, w http.ResponseWriter, r *http.Request) (err error) {
 	// log.DebugF("checking path: %v", path)
-	if dd, ok := f.infos[path]; ok {
-
-		var p feature.Page
-		if p, err = f.makeDebPage(r, dd); err != nil {
-			err = fmt.Errorf("error making deb page: %v - %w", path, err)
-			return
-		}
+	rt, ok := f.parseRoute(path)
+	if !ok {
+		err = fmt.Errorf("path not found")
+		return
+	}
 
-		pg := p.Copy()
-		var cacheControl string
-		if f.cacheControl == "" {
-			cacheControl = DefaultCacheControl
+	if dd, found := f.latestRedirect(rt); found {
+		if download := f.downloadUrl(dd); download != "" {
+			f.Enjin.ServeRedirect(download, w, r)
 		} else {
-			cacheControl = f.cacheControl
-		}
-		cacheControl = pg.Context().String("CacheControl", cacheControl)
-		pg.Context().SetSpecific("CacheControl", cacheControl)
-		if err = f.Enjin.ServePage(pg, w, r); err != nil {
-			err = fmt.Errorf("serve local %v debinfo: %v - error: %w", f.setup[path], path, err)
-			return
+			f.Enjin.ServeRedirect(dd.Url, w, r)
 		}
+		log.DebugF("redirected local %v debinfo: %v", rt.MP.Mount, path)
+		return
+	}
 
-		log.DebugF("served local %v debinfo: [%v] %v", f.setup[path], pg.Language(), path)
+	if f.serveFile(rt, w, r) || f.serveGraph(rt, w, r) {
 		return
 	}
 
-	err = fmt.Errorf("path not found")
+	var pg feature.Page
+	if pg, ok, err = f.routePage(r, lang.GetTag(r), rt); err != nil {
+		return
+	} else if !ok {
+		err = fmt.Errorf("path not found")
+		return
+	}
+
+	// the index and package pages change with every scan, only package file
+	// pages use the cacheControl
+	if rt.DD != nil {
+		cacheControl := pg.Context().String("CacheControl", f.getCacheControl())
+		pg.Context().SetSpecific("CacheControl", cacheControl)
+	}
+	if err = f.Enjin.ServePage(pg, w, r); err != nil {
+		err = fmt.Errorf("serve local %v debinfo: %v - error: %w", rt.MP.Mount, path, err)
+		return
+	}
+
+	log.DebugF("served local %v debinfo: [%v] %v", rt.MP.Mount, pg.Language(), path)
+	return
+}
+
+// getCacheControl returns the Cache-Control header value for package file
+// pages
+func (f *CFeature) getCacheControl() (cacheControl string) {
+	if cacheControl = f.cacheControl; cacheControl == "" {
+		cacheControl = DefaultCacheControl
+	}
 	return
 }
 
@@ -240,11 +490,10 @@ func (f *CFeature) FindTranslations(path string) (found []feature.Page) {
 }
 
 func (f *CFeature) FindPage(r *http.Request, tag language.Tag, url string) (p feature.Page) {
-	var err error
-	if dd, ok := f.infos[url]; ok {
-		if p, err = f.makeDebPage(r, dd); err != nil {
-			err = fmt.Errorf("error making deb page: %v - %w", url, err)
-			return
+	if rt, ok := f.parseRoute(url); ok {
+		var err error
+		if p, _, err = f.routePage(r, tag, rt); err != nil {
+			log.ErrorF("error finding page: %v - %v", url, err)
 		}
 	}
 	return
@@ -0,0 +1,204 @@
+/*
+ * Copyright (c) 2023  The Go-Enjin Authors
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+/*
+ * Syntax highlighting of the decorated code blocks of dpkg-deb pages, such as
+ * maintainer scripts, which are rendered with one list item per line and a
+ * "language-*" class. Each line is tokenized in turn so that quoted strings
+ * may continue onto following lines.
+ */
+(function () {
+    "use strict";
+
+    function words(list) {
+        var set = {};
+        list.split(" ").forEach(function (word) {
+            set[word] = true;
+        });
+        return set;
+    }
+
+    var languages = {
+        bash: {
+            keywords: words("if then else elif fi case esac for select while until do done in function " +
+                "return exit break continue local export readonly declare set unset shift trap eval exec"),
+            quotes: {"'": false, "\"": true, "`": true},
+            variable: /\$(\{[^}]*\}|[A-Za-z_]\w*|[0-9@*#?$!-])/y,
+            word: /[A-Za-z_][\w.-]*/y,
+            comment: function (line, pos) {
+                return pos === 0 || /[\s;|&(]/.test(line[pos - 1]);
+            }
+        },
+        perl: {
+            keywords: words("my our local sub if elsif else unless while until for foreach do last next redo " +
+                "return use no require package BEGIN END die exit and or not eq ne lt gt le ge cmp"),
+            quotes: {"'": true, "\"": true, "`": true},
+            variable: /[$@%]#?(\{\w+\}|\w+(::\w+)*|[_0-9!@&\/\\])/y,
+            word: /[A-Za-z_]\w*(::\w+)*/y,
+            comment: function () {
+                return true;
+            }
+        },
+        python: {
+            keywords: words("and as assert async await break class continue def del elif else except finally " +
+                "for from global if import in is lambda nonlocal not or pass raise return try while with yield " +
+                "None True False"),
+            quotes: {"'": true, "\"": true},
+            triple: true,
+            word: /[A-Za-z_]\w*/y,
+            comment: function () {
+                return true;
+            }
+        }
+    };
+
+    function escape(text) {
+        return text.replace(/&/g, "&amp;").replace(/</g, "&lt;").replace(/>/g, "&gt;");
+    }
+
+    function span(kind, text) {
+        return "<span class=\"hl-" + kind + "\">" + escape(text) + "</span>";
+    }
+
+    function match(rx, line, pos) {
+        rx.lastIndex = pos;
+        var m = rx.exec(line);
+        return m ? m[0] : "";
+    }
+
+    // closeQuote returns the position after the closing quote, or -1 when the
+    // string continues past the end of the line
+    function closeQuote(line, pos, quote, escapes) {
+        for (var idx = pos; idx < line.length; idx++) {
+            if (escapes && line[idx] === "\\") {
+                idx++;
+            } else if (line.startsWith(quote, idx)) {
+                return idx + quote.length;
+            }
+        }
+        return -1;
+    }
+
+    // highlightLine returns the highlighted html of the line and the quote of
+    // any string left open at the end of it
+    function highlightLine(lang, line, open) {
+        var html = "", plain = "", pos = 0;
+
+        function flush() {
+            html += escape(plain);
+            plain = "";
+        }
+
+        function quoted(start, quote) {
+            var end = closeQuote(line, start, quote, lang.quotes[quote[0]]);
+            flush();
+            if (end < 0) {
+                html += span("string", line.slice(pos));
+                pos = line.length;
+                return quote;
+            }
+            html += span("string", line.slice(pos, end));
+            pos = end;
+            return null;
+        }
+
+        if (open) {
+            open = quoted(0, open);
+        }
+
+        while (pos < line.length && !open) {
+            var ch = line[pos], text;
+            if (lang.variable && (text = match(lang.variable, line, pos))) {
+                flush();
+                html += span("variable", text);
+                pos += text.length;
+            } else if (lang.triple && (line.startsWith("\"\"\"", pos) || line.startsWith("'''", pos))) {
+                open = quoted(pos + 3, line.substr(pos, 3));
+            } else if (lang.quotes.hasOwnProperty(ch)) {
+                open = quoted(pos + 1, ch);
+                if (open && open.length === 1 && lang.triple) {
+                    // only triple quoted python strings span lines
+                    open = null;
+                }
+            } else if (ch === "#" && lang.comment(line, pos)) {
+                flush();
+                html += span("comment", line.slice(pos));
+                pos = line.length;
+            } else if ((text = match(lang.word, line, pos))) {
+                if (lang.keywords[text] === true && !/[\w.-]/.test(line[pos - 1] || "")) {
+                    flush();
+                    html += span("keyword", text);
+                } else {
+                    plain += text;
+                }
+                pos += text.length;
+            } else if ((text = match(/\d+(\.\d+)?\b/y, line, pos))) {
+                flush();
+                html += span("number", text);
+                pos += text.length;
+            } else {
+                plain += ch;
+                pos++;
+            }
+        }
+        flush();
+        return {html: html, open: open};
+    }
+
+    // highlightControl marks the field names and substitution variables of
+    // debconf templates and other deb822 control files
+    function highlightControl(line) {
+        var m = /^([A-Za-z][\w-]*)(:)(.*)$/.exec(line);
+        var html = m ? span("field", m[1]) + m[2] : "";
+        var rest = m ? m[3] : line;
+        return html + escape(rest).replace(/\$\{[^}]*\}/g, function (text) {
+            return "<span class=\"hl-variable\">" + text + "</span>";
+        });
+    }
+
+    function highlightBlock(code) {
+        var name = "";
+        code.classList.forEach(function (cls) {
+            if (cls.startsWith("language-")) {
+                name = cls.slice("language-".length);
+            }
+        });
+        var lang = languages[name];
+        if (!lang && name !== "debcontrol") {
+            return;
+        }
+        var open = null;
+        code.querySelectorAll(":scope > ol > li").forEach(function (item) {
+            if (lang) {
+                var result = highlightLine(lang, item.textContent, open);
+                item.innerHTML = result.html;
+                open = result.open;
+            } else {
+                item.innerHTML = highlightControl(item.textContent);
+            }
+        });
+    }
+
+    function highlightAll() {
+        document.querySelectorAll("code.decorated[class*=\"language-\"]").forEach(highlightBlock);
+    }
+
+    if (document.readyState === "loading") {
+        document.addEventListener("DOMContentLoaded", highlightAll);
+    } else {
+        highlightAll();
+    }
+})();